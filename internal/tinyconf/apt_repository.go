@@ -0,0 +1,406 @@
+package tinyconf
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+)
+
+const (
+	defaultAptSourcesDir  = "/etc/apt/sources.list.d"
+	defaultAptKeyringsDir = "/etc/apt/keyrings"
+	// public keys are small, so anything bigger is likely the wrong URL
+	maxAptKeySize = 1 << 20
+)
+
+// aptRepositoryResource manages an apt source and its signing key.
+type aptRepositoryResource struct {
+	// used for the sources and key file names
+	Name          string   `json:"name" validate:"required"`
+	URIs          []string `json:"uris" validate:"required_unless=State absent"`
+	Suites        []string `json:"suites" validate:"required_unless=State absent"`
+	Components    []string `json:"components"`
+	Architectures []string `json:"architectures"`
+	// defaults to deb
+	Types []string `json:"types" validate:"dive,oneof=deb deb-src"`
+	// deb822 or list. defaults to deb822
+	Format *string `json:"format" validate:"omitempty,oneof=deb822 list"`
+	// signing key. a local path or URL
+	Key *string `json:"key"`
+	// expected fingerprint of the signing key
+	KeyFingerprint *string        `json:"key_fingerprint" validate:"required_with=Key"`
+	State          *string        `json:"state" validate:"omitempty,oneof=present absent"`
+	Notify         notifyResource `json:"notify"`
+	// the apt cache is updated before the next install when the repository changes
+	cache cacheInvalidator
+	// for testing
+	sourcesDir  string
+	keyringsDir string
+}
+
+// package managers that can be told the cache is out of date
+type cacheInvalidator interface {
+	InvalidateCache()
+}
+
+func (a *aptRepositoryResource) format() string {
+	if a.Format != nil {
+		return *a.Format
+	}
+
+	return "deb822"
+}
+
+func (a *aptRepositoryResource) sourcesPath() string {
+	dir := a.sourcesDir
+	if dir == "" {
+		dir = defaultAptSourcesDir
+	}
+
+	ext := ".sources"
+	if a.format() == "list" {
+		ext = ".list"
+	}
+
+	return filepath.Join(dir, a.Name+ext)
+}
+
+// armored keys must use .asc for apt to read them
+func (a *aptRepositoryResource) keyPath(armored bool) string {
+	dir := a.keyringsDir
+	if dir == "" {
+		dir = defaultAptKeyringsDir
+	}
+
+	ext := ".gpg"
+	if armored {
+		ext = ".asc"
+	}
+
+	return filepath.Join(dir, a.Name+ext)
+}
+
+func (a *aptRepositoryResource) Run(ctx context.Context) (string, error) {
+	shouldExist := a.State == nil || *a.State == "present"
+
+	var keyPath string
+
+	tasks := []func() (bool, error){
+		func() (bool, error) {
+			if !shouldExist {
+				changed := false
+				for _, path := range []string{a.keyPath(true), a.keyPath(false)} {
+					removed, err := removeFile(path)
+					if err != nil {
+						return changed, err
+					}
+					changed = changed || removed
+				}
+				return changed, nil
+			}
+
+			if a.Key == nil {
+				return false, nil
+			}
+
+			var (
+				changed bool
+				err     error
+			)
+			keyPath, changed, err = a.ensureKey(ctx)
+			return changed, err
+		},
+		func() (bool, error) {
+			path := a.sourcesPath()
+
+			if !shouldExist {
+				return removeFile(path)
+			}
+
+			contents := a.sources(keyPath)
+
+			data, err := os.ReadFile(path)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return false, fmt.Errorf("failed to read %s %w", path, err)
+			}
+
+			if err == nil && string(data) == contents {
+				return false, nil
+			}
+
+			slog.Info("writing apt repository", "name", a.Name, "path", path)
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				return false, fmt.Errorf("failed to create directory for %s %w", path, err)
+			}
+
+			if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+				return false, fmt.Errorf("failed to write %s %w", path, err)
+			}
+
+			return true, nil
+		},
+	}
+
+	// use runTasks in case we add some debugging/logging/etc
+	changed, err := runTasks(tasks)
+	if err != nil {
+		return "", err
+	}
+
+	if changed {
+		if !isNil(a.cache) {
+			a.cache.InvalidateCache()
+		}
+		return a.Notify.Service, nil
+	}
+
+	return "", nil
+}
+
+// returns the contents of the sources file
+func (a *aptRepositoryResource) sources(keyPath string) string {
+	types := a.Types
+	if len(types) == 0 {
+		types = []string{"deb"}
+	}
+
+	var b strings.Builder
+	b.WriteString("# managed by tinyconf\n")
+
+	if a.format() == "list" {
+		var options []string
+		if len(a.Architectures) > 0 {
+			options = append(options, "arch="+strings.Join(a.Architectures, ","))
+		}
+		if keyPath != "" {
+			options = append(options, "signed-by="+keyPath)
+		}
+
+		for _, t := range types {
+			for _, uri := range a.URIs {
+				for _, suite := range a.Suites {
+					fields := []string{t}
+					if len(options) > 0 {
+						fields = append(fields, "["+strings.Join(options, " ")+"]")
+					}
+					fields = append(fields, uri, suite)
+					fields = append(fields, a.Components...)
+					b.WriteString(strings.Join(fields, " ") + "\n")
+				}
+			}
+		}
+
+		return b.String()
+	}
+
+	field := func(name string, values []string) {
+		if len(values) > 0 {
+			fmt.Fprintf(&b, "%s: %s\n", name, strings.Join(values, " "))
+		}
+	}
+
+	field("Types", types)
+	field("URIs", a.URIs)
+	field("Suites", a.Suites)
+	field("Components", a.Components)
+	field("Architectures", a.Architectures)
+	if keyPath != "" {
+		field("Signed-By", []string{keyPath})
+	}
+
+	return b.String()
+}
+
+// installs the signing key, returning its path
+func (a *aptRepositoryResource) ensureKey(ctx context.Context) (string, bool, error) {
+	// skip downloading if the installed key already matches
+	if a.KeyFingerprint != nil {
+		for _, armored := range []bool{true, false} {
+			path := a.keyPath(armored)
+			data, err := os.ReadFile(path)
+			if err != nil {
+				continue
+			}
+
+			// an installed keyring with extra keys is replaced
+			if key, err := matchingKey(data, *a.KeyFingerprint); err == nil && bytes.Equal(key, data) {
+				return path, false, nil
+			}
+		}
+	}
+
+	data, err := readAptKey(ctx, *a.Key)
+	if err != nil {
+		return "", false, err
+	}
+
+	if a.KeyFingerprint != nil {
+		if data, err = matchingKey(data, *a.KeyFingerprint); err != nil {
+			return "", false, fmt.Errorf("refusing key %s for apt repository %s %w", *a.Key, a.Name, err)
+		}
+	}
+
+	armored := bytes.HasPrefix(bytes.TrimSpace(data), []byte("-----BEGIN PGP"))
+	path := a.keyPath(armored)
+
+	existing, err := os.ReadFile(path)
+	if err == nil && bytes.Equal(existing, data) {
+		return path, false, nil
+	}
+
+	slog.Info("installing apt repository key", "name", a.Name, "path", path)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", false, fmt.Errorf("failed to create directory for %s %w", path, err)
+	}
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return "", false, fmt.Errorf("failed to write %s %w", path, err)
+	}
+
+	// the other format may have been used before
+	if _, err := removeFile(a.keyPath(!armored)); err != nil {
+		return path, true, err
+	}
+
+	return path, true, nil
+}
+
+// reads a key from a local path or URL
+func readAptKey(ctx context.Context, source string) ([]byte, error) {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		data, err := os.ReadFile(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key %s %w", source, err)
+		}
+		return data, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("downloading key", "source", source)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s %w", source, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s unexpected status %s", source, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxAptKeySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s %w", source, err)
+	}
+
+	if len(data) > maxAptKeySize {
+		return nil, fmt.Errorf("key %s is larger than %d bytes", source, maxAptKeySize)
+	}
+
+	return data, nil
+}
+
+// returns the key with the expected fingerprint from an armored or binary keyring.
+// other keys are dropped so they are not trusted for the repository.
+func matchingKey(data []byte, expected string) ([]byte, error) {
+	armored := bytes.HasPrefix(bytes.TrimSpace(data), []byte("-----BEGIN PGP"))
+
+	var reader io.Reader = bytes.NewReader(data)
+	if armored {
+		block, err := armor.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode armored key %w", err)
+		}
+		reader = block.Body
+	}
+
+	entities, err := openpgp.ReadKeyRing(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key %w", err)
+	}
+
+	expected = strings.ToLower(strings.ReplaceAll(expected, " ", ""))
+
+	var found []string
+	for _, entity := range entities {
+		fingerprint := hex.EncodeToString(entity.PrimaryKey.Fingerprint)
+		if fingerprint != expected {
+			found = append(found, strings.ToUpper(fingerprint))
+			continue
+		}
+
+		// keep the key as published when it is the only one
+		if len(entities) == 1 {
+			return data, nil
+		}
+
+		return serializeKey(entity, armored)
+	}
+
+	slices.Sort(found)
+
+	return nil, fmt.Errorf("fingerprint mismatch: expected %s, found %s", strings.ToUpper(expected), strings.Join(found, ", "))
+}
+
+func serializeKey(entity *openpgp.Entity, armored bool) ([]byte, error) {
+	var buf bytes.Buffer
+
+	var w io.WriteCloser = nopWriteCloser{&buf}
+	if armored {
+		var err error
+		if w, err = armor.Encode(&buf, openpgp.PublicKeyType, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := entity.Serialize(w); err != nil {
+		return nil, fmt.Errorf("failed to serialize key %w", err)
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	if armored {
+		buf.WriteByte('\n')
+	}
+
+	return buf.Bytes(), nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// removes a file if it exists
+func removeFile(path string) (bool, error) {
+	if err := os.Remove(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to remove %s %w", path, err)
+	}
+
+	slog.Info("removed file", "path", path)
+
+	return true, nil
+}
@@ -0,0 +1,246 @@
+package tinyconf
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/stretchr/testify/require"
+)
+
+// returns an armored public key and its fingerprint
+func testSigningKey(t *testing.T) ([]byte, string) {
+	t.Helper()
+
+	entity, err := openpgp.NewEntity("test", "", "test@example.com", nil)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.Serialize(w))
+	require.NoError(t, w.Close())
+
+	return buf.Bytes(), strings.ToUpper(hex.EncodeToString(entity.PrimaryKey.Fingerprint))
+}
+
+func newTestAptRepository(t *testing.T) *aptRepositoryResource {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	return &aptRepositoryResource{
+		Name:        "example",
+		URIs:        []string{"https://apt.example.com/debian"},
+		Suites:      []string{"bookworm"},
+		Components:  []string{"main"},
+		sourcesDir:  filepath.Join(dir, "sources.list.d"),
+		keyringsDir: filepath.Join(dir, "keyrings"),
+	}
+}
+
+func TestAptRepositoryResource_Run_Deb822WithKey(t *testing.T) {
+	key, fingerprint := testSigningKey(t)
+	keyFile := filepath.Join(t.TempDir(), "key.asc")
+	require.NoError(t, os.WriteFile(keyFile, key, 0o644))
+
+	cache := &aptPackageManager{}
+
+	a := newTestAptRepository(t)
+	a.Architectures = []string{"amd64"}
+	a.Key = &keyFile
+	a.KeyFingerprint = &fingerprint
+	a.cache = cache
+	a.Notify = notifyResource{Service: "test-service"}
+
+	ctx := t.Context()
+
+	service, err := a.Run(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "test-service", service)
+	require.True(t, cache.cacheStale)
+
+	keyPath := filepath.Join(a.keyringsDir, "example.asc")
+	installed, err := os.ReadFile(keyPath)
+	require.NoError(t, err)
+	require.Equal(t, key, installed)
+
+	data, err := os.ReadFile(filepath.Join(a.sourcesDir, "example.sources"))
+	require.NoError(t, err)
+	require.Equal(t, `# managed by tinyconf
+Types: deb
+URIs: https://apt.example.com/debian
+Suites: bookworm
+Components: main
+Architectures: amd64
+Signed-By: `+keyPath+"\n", string(data))
+
+	cache.cacheStale = false
+
+	service, err = a.Run(ctx)
+	require.NoError(t, err)
+	require.Empty(t, service)
+	require.False(t, cache.cacheStale)
+}
+
+func TestAptRepositoryResource_Run_ListFormat(t *testing.T) {
+	a := newTestAptRepository(t)
+	a.Format = ptr("list")
+	a.Types = []string{"deb", "deb-src"}
+	a.Architectures = []string{"amd64", "arm64"}
+
+	_, err := a.Run(t.Context())
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(a.sourcesDir, "example.list"))
+	require.NoError(t, err)
+	require.Equal(t, `# managed by tinyconf
+deb [arch=amd64,arm64] https://apt.example.com/debian bookworm main
+deb-src [arch=amd64,arm64] https://apt.example.com/debian bookworm main
+`, string(data))
+}
+
+func TestAptRepositoryResource_Run_FingerprintMismatch(t *testing.T) {
+	key, _ := testSigningKey(t)
+	keyFile := filepath.Join(t.TempDir(), "key.asc")
+	require.NoError(t, os.WriteFile(keyFile, key, 0o644))
+
+	a := newTestAptRepository(t)
+	a.Key = &keyFile
+	a.KeyFingerprint = ptr("0000 0000 0000 0000 0000 0000 0000 0000 0000 0000")
+
+	_, err := a.Run(t.Context())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "fingerprint mismatch")
+
+	_, err = os.Stat(a.sourcesDir)
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestAptRepositoryResource_Run_KeyringWithExtraKeys(t *testing.T) {
+	var entities []*openpgp.Entity
+	for _, name := range []string{"trusted", "extra"} {
+		entity, err := openpgp.NewEntity(name, "", name+"@example.com", nil)
+		require.NoError(t, err)
+		entities = append(entities, entity)
+	}
+
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	require.NoError(t, err)
+	for _, entity := range entities {
+		require.NoError(t, entity.Serialize(w))
+	}
+	require.NoError(t, w.Close())
+
+	keyFile := filepath.Join(t.TempDir(), "key.asc")
+	require.NoError(t, os.WriteFile(keyFile, buf.Bytes(), 0o644))
+
+	fingerprint := strings.ToUpper(hex.EncodeToString(entities[0].PrimaryKey.Fingerprint))
+
+	a := newTestAptRepository(t)
+	a.Key = &keyFile
+	a.KeyFingerprint = &fingerprint
+
+	ctx := t.Context()
+
+	service, err := a.Run(ctx)
+	require.NoError(t, err)
+	require.Empty(t, service)
+
+	installed, err := os.ReadFile(filepath.Join(a.keyringsDir, "example.asc"))
+	require.NoError(t, err)
+
+	block, err := armor.Decode(bytes.NewReader(installed))
+	require.NoError(t, err)
+	keyring, err := openpgp.ReadKeyRing(block.Body)
+	require.NoError(t, err)
+
+	// only the key with the expected fingerprint is trusted
+	require.Len(t, keyring, 1)
+	require.Equal(t, entities[0].PrimaryKey.Fingerprint, keyring[0].PrimaryKey.Fingerprint)
+
+	_, changed, err := a.ensureKey(ctx)
+	require.NoError(t, err)
+	require.False(t, changed)
+}
+
+func TestAptRepositoryResource_Run_KeyFromURL(t *testing.T) {
+	key, fingerprint := testSigningKey(t)
+
+	// binary keys are stored as .gpg
+	block, err := armor.Decode(bytes.NewReader(key))
+	require.NoError(t, err)
+	var binary bytes.Buffer
+	_, err = binary.ReadFrom(block.Body)
+	require.NoError(t, err)
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = w.Write(binary.Bytes())
+	}))
+	defer server.Close()
+
+	a := newTestAptRepository(t)
+	a.Key = ptr(server.URL + "/key.gpg")
+	// spaces and case do not matter
+	a.KeyFingerprint = ptr(strings.ToLower(fingerprint[:4] + " " + fingerprint[4:]))
+
+	ctx := t.Context()
+
+	_, err = a.Run(ctx)
+	require.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(a.keyringsDir, "example.gpg"))
+	require.NoError(t, err)
+
+	// the installed key matches, so it is not downloaded again
+	service, err := a.Run(ctx)
+	require.NoError(t, err)
+	require.Empty(t, service)
+	require.Equal(t, 1, requests)
+}
+
+func TestAptRepositoryResource_Run_Absent(t *testing.T) {
+	key, fingerprint := testSigningKey(t)
+	keyFile := filepath.Join(t.TempDir(), "key.asc")
+	require.NoError(t, os.WriteFile(keyFile, key, 0o644))
+
+	a := newTestAptRepository(t)
+	a.Key = &keyFile
+	a.KeyFingerprint = &fingerprint
+
+	ctx := t.Context()
+
+	_, err := a.Run(ctx)
+	require.NoError(t, err)
+
+	a.State = ptr("absent")
+
+	service, err := a.Run(ctx)
+	require.NoError(t, err)
+	require.Empty(t, service)
+
+	_, err = os.Stat(filepath.Join(a.sourcesDir, "example.sources"))
+	require.True(t, os.IsNotExist(err))
+
+	_, err = os.Stat(filepath.Join(a.keyringsDir, "example.asc"))
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestAptPackageManager_UpdateCache_StaleNever(t *testing.T) {
+	a := &aptPackageManager{cacheUpdated: true}
+	a.InvalidateCache()
+
+	// never is still respected
+	require.NoError(t, a.UpdateCache(t.Context(), "never"))
+	require.True(t, a.cacheStale)
+}
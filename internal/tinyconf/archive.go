@@ -0,0 +1,559 @@
+package tinyconf
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/ulikunitz/xz"
+)
+
+// archiveResource extracts an archive into a directory.
+type archiveResource struct {
+	// local path or http(s) url
+	Source      string `json:"source" validate:"required"`
+	Destination string `json:"destination" validate:"required"`
+	// detected from the source name if not set
+	Format *string `json:"format" validate:"omitempty,oneof=tar tar.gz tar.xz zip"`
+	// if this path exists, the archive is not extracted
+	Creates *string `json:"creates"`
+	// expected sha256 of the archive
+	Checksum        *string        `json:"checksum"`
+	StripComponents int            `json:"strip_components" validate:"min=0"`
+	Owner           *string        `json:"owner"`
+	Group           *string        `json:"group"`
+	Notify          notifyResource `json:"notify"`
+}
+
+func (a *archiveResource) Run(ctx context.Context) (string, error) {
+	userID, groupID, err := getUserAndGroup("archive "+a.Destination, a.Owner, a.Group)
+	if err != nil {
+		return "", err
+	}
+
+	format, err := a.format()
+	if err != nil {
+		return "", err
+	}
+
+	destination := filepath.Clean(a.Destination)
+
+	if a.Creates != nil {
+		if _, err := os.Stat(*a.Creates); err == nil {
+			return "", nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("failed to stat %s %w", *a.Creates, err)
+		}
+	}
+
+	// if we know the checksum, we can avoid fetching the archive
+	if a.Creates == nil && a.Checksum != nil && a.markerMatches(destination, *a.Checksum) {
+		return "", nil
+	}
+
+	tasks := []func() (bool, error){
+		func() (bool, error) {
+			info, err := os.Stat(destination)
+			if err == nil {
+				if !info.IsDir() {
+					return false, fmt.Errorf("%s is not a directory", destination)
+				}
+				return false, nil
+			}
+
+			if !errors.Is(err, os.ErrNotExist) {
+				return false, fmt.Errorf("failed to stat %s %w", destination, err)
+			}
+
+			slog.Info("creating directory", "path", destination, "mode", defaultDirMode)
+			if err := os.Mkdir(destination, defaultDirMode); err != nil {
+				return false, err
+			}
+
+			return true, nil
+		},
+		func() (bool, error) {
+			archivePath, cleanup, err := a.fetch(ctx)
+			if err != nil {
+				return false, err
+			}
+			defer cleanup()
+
+			checksum, err := fileChecksum(archivePath)
+			if err != nil {
+				return false, err
+			}
+			sum := hex.EncodeToString(checksum)
+
+			if a.Checksum != nil && !strings.EqualFold(*a.Checksum, sum) {
+				return false, fmt.Errorf("checksum mismatch for %s expected %s got %s", a.Source, *a.Checksum, sum)
+			}
+
+			if a.Creates == nil && a.markerMatches(destination, sum) {
+				return false, nil
+			}
+
+			slog.Info("extracting archive", "source", a.Source, "destination", destination)
+			ex := &extractor{
+				destination: destination,
+				strip:       a.StripComponents,
+				userID:      userID,
+				groupID:     groupID,
+			}
+
+			if format == "zip" {
+				err = ex.zip(archivePath)
+			} else {
+				err = ex.tar(archivePath, format)
+			}
+			if err == nil {
+				err = ex.checkLinks()
+			}
+			if err != nil {
+				return true, fmt.Errorf("failed to extract %s %w", a.Source, err)
+			}
+
+			if a.Creates == nil {
+				if err := os.WriteFile(a.markerPath(destination), []byte(sum+"\n"), 0o644); err != nil {
+					return true, fmt.Errorf("failed to write marker for %s %w", a.Source, err)
+				}
+			}
+
+			return true, nil
+		},
+	}
+
+	changed, err := runTasks(tasks)
+	if err != nil {
+		return "", err
+	}
+
+	if changed {
+		return a.Notify.Service, nil
+	}
+
+	return "", nil
+}
+
+func (a *archiveResource) format() (string, error) {
+	if a.Format != nil {
+		return *a.Format, nil
+	}
+
+	name := strings.ToLower(a.Source)
+	switch {
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return "tar.gz", nil
+	case strings.HasSuffix(name, ".tar.xz"), strings.HasSuffix(name, ".txz"):
+		return "tar.xz", nil
+	case strings.HasSuffix(name, ".tar"):
+		return "tar", nil
+	case strings.HasSuffix(name, ".zip"):
+		return "zip", nil
+	default:
+		return "", fmt.Errorf("unable to determine archive format for %s", a.Source)
+	}
+}
+
+func (a *archiveResource) isURL() bool {
+	return strings.HasPrefix(a.Source, "http://") || strings.HasPrefix(a.Source, "https://")
+}
+
+// the marker records the checksum of the last archive extracted
+func (a *archiveResource) markerPath(destination string) string {
+	name := filepath.Base(a.Source)
+	if a.isURL() {
+		name = path.Base(strings.SplitN(a.Source, "?", 2)[0])
+	}
+
+	return filepath.Join(destination, ".tinyconf-"+name+".sha256")
+}
+
+func (a *archiveResource) markerMatches(destination string, sum string) bool {
+	data, err := os.ReadFile(a.markerPath(destination))
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(strings.TrimSpace(string(data)), sum)
+}
+
+// returns a local path for the archive and a function to cleanup any temporary files
+func (a *archiveResource) fetch(ctx context.Context) (string, func(), error) {
+	if !a.isURL() {
+		return a.Source, func() {}, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.Source, nil)
+	if err != nil {
+		return "", nil, err
+	}
+
+	slog.Info("downloading archive", "source", a.Source)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to download %s %w", a.Source, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, fmt.Errorf("failed to download %s unexpected status %s", a.Source, resp.Status)
+	}
+
+	file, err := os.CreateTemp("", "tinyconf-archive-*")
+	if err != nil {
+		return "", nil, err
+	}
+
+	cleanup := func() {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+	}
+
+	if _, err := io.Copy(file, resp.Body); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to download %s %w", a.Source, err)
+	}
+
+	if err := file.Close(); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+
+	return file.Name(), cleanup, nil
+}
+
+// extractor writes archive entries into destination, refusing anything
+// that would end up outside of it.
+type extractor struct {
+	destination string
+	strip       int
+	userID      int
+	groupID     int
+	// symlinks created so far
+	links []string
+}
+
+// maximum number of symlinks followed when resolving a link, as in linux
+const maxSymlinkHops = 40
+
+// returns the path inside of destination for an archive entry name.
+// empty means the entry should be skipped because of strip.
+func (e *extractor) target(name string) (string, error) {
+	name = strings.TrimPrefix(filepath.ToSlash(name), "./")
+	if name == "" || name == "." {
+		return "", nil
+	}
+
+	if path.IsAbs(name) {
+		return "", fmt.Errorf("refusing to extract absolute path %s", name)
+	}
+
+	parts := strings.Split(strings.Trim(name, "/"), "/")
+	if len(parts) <= e.strip {
+		return "", nil
+	}
+
+	rel := path.Clean(strings.Join(parts[e.strip:], "/"))
+	if rel == "." {
+		return "", nil
+	}
+	if rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", fmt.Errorf("refusing to extract %s outside of destination", name)
+	}
+
+	target := filepath.Join(e.destination, filepath.FromSlash(rel))
+
+	// an earlier entry could have created a symlink we would write through
+	for dir := filepath.Dir(target); dir != e.destination; dir = filepath.Dir(dir) {
+		info, err := os.Lstat(dir)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return "", err
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			return "", fmt.Errorf("refusing to extract %s through symlink %s", name, dir)
+		}
+	}
+
+	return target, nil
+}
+
+func (e *extractor) inside(path string) bool {
+	return path == e.destination || strings.HasPrefix(path, e.destination+string(filepath.Separator))
+}
+
+func (e *extractor) chown(path string) error {
+	if e.userID == -1 && e.groupID == -1 {
+		return nil
+	}
+
+	return os.Lchown(path, e.userID, e.groupID)
+}
+
+func (e *extractor) dir(target string, mode os.FileMode) error {
+	if err := os.MkdirAll(target, mode.Perm()|0o700); err != nil {
+		return err
+	}
+
+	return e.chown(target)
+}
+
+func (e *extractor) file(target string, mode os.FileMode, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(target), defaultDirMode); err != nil {
+		return err
+	}
+
+	// remove first so we never write through an existing symlink
+	if err := os.Remove(target); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	file, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, mode.Perm())
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(file, r); err != nil {
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	// umask may have changed the mode
+	if err := os.Chmod(target, mode.Perm()); err != nil {
+		return err
+	}
+
+	return e.chown(target)
+}
+
+// resolves link relative to dir, following symlinks already in destination.
+// returns false if any step of the resolution leaves destination.
+func (e *extractor) resolvesInside(dir string, link string) bool {
+	if filepath.IsAbs(link) {
+		return false
+	}
+
+	current := dir
+	components := strings.Split(filepath.ToSlash(link), "/")
+	hops := 0
+	for len(components) > 0 {
+		component := components[0]
+		components = components[1:]
+
+		switch component {
+		case "", ".":
+			continue
+		case "..":
+			current = filepath.Dir(current)
+			if !e.inside(current) {
+				return false
+			}
+			continue
+		}
+
+		next := filepath.Join(current, component)
+		if !e.inside(next) {
+			return false
+		}
+
+		info, err := os.Lstat(next)
+		if err != nil || info.Mode()&fs.ModeSymlink == 0 {
+			// anything that does not exist yet is checked again once extraction is done
+			current = next
+			continue
+		}
+
+		hops++
+		if hops > maxSymlinkHops {
+			return false
+		}
+
+		resolved, err := os.Readlink(next)
+		if err != nil || filepath.IsAbs(resolved) {
+			return false
+		}
+
+		// the rest of the link is resolved relative to where the symlink points
+		components = append(strings.Split(filepath.ToSlash(resolved), "/"), components...)
+	}
+
+	return true
+}
+
+// later entries can replace what an earlier symlink points through, so all
+// symlinks are checked again once everything is extracted. links that escape are removed.
+func (e *extractor) checkLinks() error {
+	for _, target := range e.links {
+		link, err := os.Readlink(target)
+		if err != nil {
+			// replaced by a later entry
+			continue
+		}
+
+		if !e.resolvesInside(filepath.Dir(target), link) {
+			if err := os.Remove(target); err != nil {
+				return err
+			}
+			return fmt.Errorf("refusing symlink %s to %s outside of destination", target, link)
+		}
+	}
+
+	return nil
+}
+
+func (e *extractor) symlink(target string, link string) error {
+	if !e.resolvesInside(filepath.Dir(target), link) {
+		return fmt.Errorf("refusing to create symlink %s to %s outside of destination", target, link)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), defaultDirMode); err != nil {
+		return err
+	}
+
+	if err := os.Remove(target); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if err := os.Symlink(link, target); err != nil {
+		return err
+	}
+	e.links = append(e.links, target)
+
+	return e.chown(target)
+}
+
+func (e *extractor) tar(archivePath string, format string) error {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	switch format {
+	case "tar.gz":
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	case "tar.xz":
+		xzr, err := xz.NewReader(file)
+		if err != nil {
+			return err
+		}
+		r = xzr
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		target, err := e.target(hdr.Name)
+		if err != nil {
+			return err
+		}
+		if target == "" {
+			continue
+		}
+
+		mode := os.FileMode(hdr.Mode)
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = e.dir(target, mode)
+		case tar.TypeReg:
+			err = e.file(target, mode, tr)
+		case tar.TypeSymlink:
+			err = e.symlink(target, hdr.Linkname)
+		case tar.TypeLink:
+			var source string
+			source, err = e.target(hdr.Linkname)
+			if err == nil && source == "" {
+				err = fmt.Errorf("hard link %s to %s removed by strip_components", hdr.Name, hdr.Linkname)
+			}
+			if err == nil {
+				_ = os.Remove(target)
+				err = os.Link(source, target)
+			}
+		default:
+			slog.Info("skipping unsupported archive entry", "name", hdr.Name, "type", hdr.Typeflag)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to extract %s %w", hdr.Name, err)
+		}
+	}
+}
+
+func (e *extractor) zip(archivePath string) error {
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		target, err := e.target(f.Name)
+		if err != nil {
+			return err
+		}
+		if target == "" {
+			continue
+		}
+
+		if err := e.zipEntry(f, target); err != nil {
+			return fmt.Errorf("failed to extract %s %w", f.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (e *extractor) zipEntry(f *zip.File, target string) error {
+	mode := f.Mode()
+	if mode.IsDir() {
+		return e.dir(target, mode)
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	if mode&fs.ModeSymlink != 0 {
+		link, err := io.ReadAll(rc)
+		if err != nil {
+			return err
+		}
+		return e.symlink(target, string(link))
+	}
+
+	return e.file(target, mode, rc)
+}
@@ -0,0 +1,344 @@
+package tinyconf
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ulikunitz/xz"
+)
+
+type testArchiveEntry struct {
+	name     string
+	contents string
+	typeflag byte
+	linkname string
+}
+
+func writeTarArchive(t *testing.T, w io.Writer, entries []testArchiveEntry) {
+	t.Helper()
+
+	tw := tar.NewWriter(w)
+	for _, e := range entries {
+		typeflag := e.typeflag
+		if typeflag == 0 {
+			typeflag = tar.TypeReg
+		}
+
+		hdr := &tar.Header{
+			Name:     e.name,
+			Mode:     0o644,
+			Typeflag: typeflag,
+			Linkname: e.linkname,
+			Size:     int64(len(e.contents)),
+		}
+		if typeflag == tar.TypeDir {
+			hdr.Mode = 0o755
+			hdr.Size = 0
+		}
+		if typeflag == tar.TypeSymlink {
+			hdr.Size = 0
+		}
+
+		require.NoError(t, tw.WriteHeader(hdr))
+		if hdr.Size > 0 {
+			_, err := tw.Write([]byte(e.contents))
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, tw.Close())
+}
+
+func createTarGz(t *testing.T, entries []testArchiveEntry) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "test.tar.gz")
+	file, err := os.Create(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	gz := gzip.NewWriter(file)
+	writeTarArchive(t, gz, entries)
+	require.NoError(t, gz.Close())
+
+	return path
+}
+
+func TestArchiveResource_Run_ExtractTarGz(t *testing.T) {
+	archive := createTarGz(t, []testArchiveEntry{
+		{name: "app/", typeflag: tar.TypeDir},
+		{name: "app/bin/run", contents: "#!/bin/sh"},
+		{name: "app/README", contents: "hello"},
+	})
+	destination := filepath.Join(t.TempDir(), "opt")
+
+	a := &archiveResource{
+		Source:      archive,
+		Destination: destination,
+		Notify: notifyResource{
+			Service: "test-service",
+		},
+	}
+
+	ctx := t.Context()
+
+	service, err := a.Run(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "test-service", service)
+
+	data, err := os.ReadFile(filepath.Join(destination, "app", "README"))
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(data))
+
+	// marker makes this idempotent
+	service, err = a.Run(ctx)
+	require.NoError(t, err)
+	require.Empty(t, service)
+}
+
+func TestArchiveResource_Run_StripComponents(t *testing.T) {
+	archive := createTarGz(t, []testArchiveEntry{
+		{name: "app-1.0/", typeflag: tar.TypeDir},
+		{name: "app-1.0/bin/run", contents: "x"},
+	})
+	destination := t.TempDir()
+
+	a := &archiveResource{
+		Source:          archive,
+		Destination:     destination,
+		StripComponents: 1,
+	}
+
+	_, err := a.Run(t.Context())
+	require.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(destination, "bin", "run"))
+	require.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(destination, "app-1.0"))
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestArchiveResource_Run_Creates(t *testing.T) {
+	archive := createTarGz(t, []testArchiveEntry{
+		{name: "file", contents: "x"},
+	})
+	destination := t.TempDir()
+	creates := filepath.Join(destination, "file")
+
+	a := &archiveResource{
+		Source:      archive,
+		Destination: destination,
+		Creates:     &creates,
+		Notify: notifyResource{
+			Service: "test-service",
+		},
+	}
+
+	ctx := t.Context()
+
+	service, err := a.Run(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "test-service", service)
+
+	service, err = a.Run(ctx)
+	require.NoError(t, err)
+	require.Empty(t, service)
+
+	// no marker when creates is used
+	entries, err := os.ReadDir(destination)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}
+
+func TestArchiveResource_Run_ChecksumMismatch(t *testing.T) {
+	archive := createTarGz(t, []testArchiveEntry{
+		{name: "file", contents: "x"},
+	})
+	checksum := "0000"
+
+	a := &archiveResource{
+		Source:      archive,
+		Destination: t.TempDir(),
+		Checksum:    &checksum,
+	}
+
+	_, err := a.Run(t.Context())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "checksum mismatch")
+}
+
+func TestArchiveResource_Run_ExtractTarXz(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.tar.xz")
+	file, err := os.Create(path)
+	require.NoError(t, err)
+
+	xzw, err := xz.NewWriter(file)
+	require.NoError(t, err)
+	writeTarArchive(t, xzw, []testArchiveEntry{{name: "file", contents: "xz"}})
+	require.NoError(t, xzw.Close())
+	require.NoError(t, file.Close())
+
+	destination := t.TempDir()
+
+	a := &archiveResource{
+		Source:      path,
+		Destination: destination,
+	}
+
+	_, err = a.Run(t.Context())
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(destination, "file"))
+	require.NoError(t, err)
+	require.Equal(t, "xz", string(data))
+}
+
+func TestArchiveResource_Run_ExtractZip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.zip")
+	file, err := os.Create(path)
+	require.NoError(t, err)
+
+	zw := zip.NewWriter(file)
+	w, err := zw.Create("dir/file.txt")
+	require.NoError(t, err)
+	_, err = w.Write([]byte("zip"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	require.NoError(t, file.Close())
+
+	destination := t.TempDir()
+
+	a := &archiveResource{
+		Source:      path,
+		Destination: destination,
+	}
+
+	_, err = a.Run(t.Context())
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(destination, "dir", "file.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "zip", string(data))
+}
+
+func TestArchiveResource_Run_FromURL(t *testing.T) {
+	archive := createTarGz(t, []testArchiveEntry{
+		{name: "file", contents: "remote"},
+	})
+	data, err := os.ReadFile(archive)
+	require.NoError(t, err)
+
+	sum := sha256.Sum256(data)
+	checksum := hex.EncodeToString(sum[:])
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = w.Write(data)
+	}))
+	defer server.Close()
+
+	destination := t.TempDir()
+
+	a := &archiveResource{
+		Source:      server.URL + "/release.tar.gz",
+		Destination: destination,
+		Checksum:    &checksum,
+	}
+
+	ctx := t.Context()
+
+	_, err = a.Run(ctx)
+	require.NoError(t, err)
+
+	contents, err := os.ReadFile(filepath.Join(destination, "file"))
+	require.NoError(t, err)
+	require.Equal(t, "remote", string(contents))
+
+	// with a known checksum, the archive is not downloaded again
+	_, err = a.Run(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, requests)
+}
+
+func TestArchiveResource_Run_RefusesPathTraversal(t *testing.T) {
+	for name, entries := range map[string][]testArchiveEntry{
+		"parent":   {{name: "../evil", contents: "x"}},
+		"absolute": {{name: "/tmp/evil", contents: "x"}},
+		"nested":   {{name: "a/../../evil", contents: "x"}},
+		"symlink":  {{name: "link", typeflag: tar.TypeSymlink, linkname: "/etc"}},
+		"relative symlink": {
+			{name: "link", typeflag: tar.TypeSymlink, linkname: "../../.."},
+		},
+		"through symlink": {
+			{name: "dir", typeflag: tar.TypeDir},
+			{name: "dir/link", typeflag: tar.TypeSymlink, linkname: "."},
+			{name: "dir/link/file", contents: "x"},
+		},
+		"chained symlink": {
+			{name: "l1", typeflag: tar.TypeSymlink, linkname: "."},
+			{name: "x", typeflag: tar.TypeSymlink, linkname: "l1/.."},
+		},
+		"symlink replaced later": {
+			{name: "d", contents: "x"},
+			{name: "x", typeflag: tar.TypeSymlink, linkname: "d/.."},
+			{name: "d", typeflag: tar.TypeSymlink, linkname: "."},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			a := &archiveResource{
+				Source:      createTarGz(t, entries),
+				Destination: filepath.Join(t.TempDir(), "dest"),
+			}
+
+			_, err := a.Run(t.Context())
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "refusing")
+
+			// nothing that escapes is left behind
+			_, err = os.Lstat(filepath.Join(a.Destination, "x"))
+			require.True(t, os.IsNotExist(err))
+		})
+	}
+}
+
+func TestArchiveResource_Run_SymlinkThroughSymlinkInside(t *testing.T) {
+	destination := filepath.Join(t.TempDir(), "dest")
+	a := &archiveResource{
+		Source: createTarGz(t, []testArchiveEntry{
+			{name: "a/b", typeflag: tar.TypeDir},
+			{name: "a/b/file", contents: "x"},
+			{name: "current", typeflag: tar.TypeSymlink, linkname: "a/b"},
+			{name: "file", typeflag: tar.TypeSymlink, linkname: "current/../b/file"},
+		}),
+		Destination: destination,
+	}
+
+	_, err := a.Run(t.Context())
+	require.NoError(t, err)
+
+	contents, err := os.ReadFile(filepath.Join(destination, "file"))
+	require.NoError(t, err)
+	require.Equal(t, "x", string(contents))
+}
+
+func TestArchiveResource_Run_UnknownFormat(t *testing.T) {
+	a := &archiveResource{
+		Source:      "/tmp/archive.rar",
+		Destination: t.TempDir(),
+	}
+
+	_, err := a.Run(t.Context())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "unable to determine archive format")
+}
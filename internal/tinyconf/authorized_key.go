@@ -0,0 +1,450 @@
+package tinyconf
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"unicode"
+)
+
+// authorizedKeyResource manages public keys in a user's authorized_keys file.
+type authorizedKeyResource struct {
+	User string `json:"user" validate:"required"`
+	// authorized_keys formatted lines, optionally starting with options
+	Keys []string `json:"keys" validate:"required,min=1"`
+	// defaults to ~user/.ssh/authorized_keys
+	Path *string `json:"path"`
+	// if set, keys not listed are removed
+	Exclusive bool           `json:"exclusive"`
+	State     *string        `json:"state" validate:"omitempty,oneof=present absent"`
+	Notify    notifyResource `json:"notify"`
+	// for testing, used instead of the user's home directory
+	home string
+}
+
+// key types accepted by OpenSSH
+var authorizedKeyTypes = []string{
+	"ssh-ed25519",
+	"ssh-rsa",
+	"ssh-dss",
+	"ecdsa-sha2-nistp256",
+	"ecdsa-sha2-nistp384",
+	"ecdsa-sha2-nistp521",
+	"sk-ssh-ed25519@openssh.com",
+	"sk-ecdsa-sha2-nistp256@openssh.com",
+}
+
+type authorizedKey struct {
+	Options string
+	Type    string
+	Blob    string
+	Comment string
+}
+
+// keys are identified by type and blob, so changing options or the comment
+// replaces the existing line
+func (k *authorizedKey) id() string {
+	return k.Type + " " + k.Blob
+}
+
+func (a *authorizedKeyResource) Run(ctx context.Context) (string, error) {
+	shouldExist := a.State == nil || *a.State == "present"
+
+	if !shouldExist && a.Exclusive {
+		return "", fmt.Errorf("exclusive can not be used with state absent for authorized keys of %s", a.User)
+	}
+
+	keys := make([]*authorizedKey, 0, len(a.Keys))
+	for _, line := range a.Keys {
+		key, err := parseAuthorizedKey(line)
+		if err != nil {
+			return "", fmt.Errorf("invalid key for user %s %w", a.User, err)
+		}
+		keys = append(keys, key)
+	}
+
+	// looked up at run time, so the user may be created earlier in the run
+	u, err := lookupUser(a.User)
+	if err != nil {
+		return "", fmt.Errorf("unable to determine home directory for user %s needed by authorized_key %w", a.User, err)
+	}
+
+	userID, groupID, err := getUserAndGroup("authorized_key "+a.User, &u.Uid, &u.Gid)
+	if err != nil {
+		return "", err
+	}
+
+	home := u.HomeDir
+	if a.home != "" {
+		home = a.home
+	}
+
+	defaultPath := filepath.Join(home, ".ssh", "authorized_keys")
+	path := defaultPath
+	if a.Path != nil {
+		path = filepath.Clean(*a.Path)
+	}
+	dir := filepath.Dir(path)
+
+	// only the user's own .ssh directory is created and owned by the user. the directory
+	// of a custom path, such as /etc/ssh/authorized_keys, is usually shared so it is left alone
+	ownDir := path == defaultPath
+
+	dirMode := os.FileMode(0o700)
+	fileMode := os.FileMode(0o600)
+
+	// the directory and file are owned by the user, so never follow symlinks in them.
+	// everything is done relative to the opened directory so it can not be swapped out.
+	var sshDir *os.File
+	defer func() {
+		if sshDir != nil {
+			_ = sshDir.Close()
+		}
+	}()
+
+	name := filepath.Base(path)
+
+	tasks := []func() (bool, error){
+		func() (bool, error) {
+			created := false
+
+			info, err := os.Lstat(dir)
+			switch {
+			case err == nil && info.Mode()&fs.ModeSymlink != 0:
+				return false, fmt.Errorf("refusing to use %s as it is a symlink", dir)
+			case err == nil && !info.IsDir():
+				return false, fmt.Errorf("%s is not a directory", dir)
+			case err != nil && !errors.Is(err, os.ErrNotExist):
+				return false, fmt.Errorf("failed to stat %s %w", dir, err)
+			case err != nil:
+				// nothing to remove
+				if !shouldExist {
+					return false, nil
+				}
+
+				if !ownDir {
+					return false, fmt.Errorf("directory %s for authorized keys of %s does not exist", dir, a.User)
+				}
+
+				slog.Info("creating directory", "path", dir)
+				if err := os.Mkdir(dir, dirMode); err != nil {
+					return false, fmt.Errorf("failed to create directory %s %w", dir, err)
+				}
+				created = true
+			}
+
+			sshDir, err = os.OpenFile(dir, os.O_RDONLY|syscall.O_DIRECTORY|syscall.O_NOFOLLOW, 0)
+			if err != nil {
+				return created, fmt.Errorf("failed to open directory %s %w", dir, err)
+			}
+
+			if !ownDir {
+				return false, nil
+			}
+
+			changed, err := ensureFileAttributes(sshDir, userID, groupID, &dirMode)
+			return created || changed, err
+		},
+		func() (bool, error) {
+			if sshDir == nil {
+				return false, nil
+			}
+
+			data, err := readFileAt(sshDir, name)
+			if err != nil {
+				if !errors.Is(err, os.ErrNotExist) {
+					return false, fmt.Errorf("failed to read %s %w", path, err)
+				}
+
+				if !shouldExist {
+					return false, nil
+				}
+
+				updated, _ := a.update(nil, keys, true)
+
+				slog.Info("creating file", "path", path)
+				if err := writeFileAt(sshDir, name, []byte(joinLines(updated, true)), fileMode, false); err != nil {
+					return false, fmt.Errorf("failed to write %s %w", path, err)
+				}
+
+				return true, nil
+			}
+
+			lines, trailingNewline := splitLines(string(data))
+
+			updated, changed := a.update(lines, keys, shouldExist)
+			if !changed {
+				return false, nil
+			}
+
+			if err := writeFileAt(sshDir, name, []byte(joinLines(updated, trailingNewline || len(updated) > 0)), fileMode, true); err != nil {
+				return false, fmt.Errorf("failed to write %s %w", path, err)
+			}
+
+			return true, nil
+		},
+		func() (bool, error) {
+			if sshDir == nil {
+				return false, nil
+			}
+
+			file, err := openFileAt(sshDir, name, os.O_RDONLY, 0)
+			if err != nil {
+				if !shouldExist && errors.Is(err, os.ErrNotExist) {
+					return false, nil
+				}
+				return false, fmt.Errorf("failed to open %s %w", path, err)
+			}
+			defer file.Close()
+
+			return ensureFileAttributes(file, userID, groupID, &fileMode)
+		},
+	}
+
+	// use runTasks in case we add some debugging/logging/etc
+	changed, err := runTasks(tasks)
+	if err != nil {
+		return "", err
+	}
+
+	if changed {
+		return a.Notify.Service, nil
+	}
+
+	return "", nil
+}
+
+// opens name in dir without following a symlink
+func openFileAt(dir *os.File, name string, flag int, mode os.FileMode) (*os.File, error) {
+	fd, err := syscall.Openat(int(dir.Fd()), name, flag|syscall.O_NOFOLLOW|syscall.O_CLOEXEC, uint32(mode.Perm()))
+	if err != nil {
+		if errors.Is(err, syscall.ELOOP) {
+			return nil, fmt.Errorf("refusing to use %s as it is a symlink", filepath.Join(dir.Name(), name))
+		}
+		return nil, &os.PathError{Op: "openat", Path: filepath.Join(dir.Name(), name), Err: err}
+	}
+
+	return os.NewFile(uintptr(fd), filepath.Join(dir.Name(), name)), nil
+}
+
+func readFileAt(dir *os.File, name string) ([]byte, error) {
+	file, err := openFileAt(dir, name, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return io.ReadAll(file)
+}
+
+// writes a new file in dir. if replace is set, the file is written to a temporary
+// file and renamed over the existing one, otherwise it must not already exist.
+func writeFileAt(dir *os.File, name string, contents []byte, mode os.FileMode, replace bool) error {
+	target := name
+	if replace {
+		target = "." + name + "." + strconv.Itoa(os.Getpid()) + ".tmp"
+		_ = syscall.Unlinkat(int(dir.Fd()), target)
+	}
+
+	file, err := openFileAt(dir, target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := file.Write(contents); err != nil {
+		return err
+	}
+
+	// umask may have changed the mode
+	if err := file.Chmod(mode); err != nil {
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	if replace {
+		if err := syscall.Renameat(int(dir.Fd()), target, int(dir.Fd()), name); err != nil {
+			_ = syscall.Unlinkat(int(dir.Fd()), target)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// returns the path of the authorized_keys file. the user must exist unless path is set
+func (a *authorizedKeyResource) path() (string, error) {
+	if a.Path != nil {
+		return *a.Path, nil
+	}
+
+	u, err := lookupUser(a.User)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(u.HomeDir, ".ssh", "authorized_keys"), nil
+}
+
+// returns the updated lines of an authorized_keys file.
+// lines that are not keys, such as comments, are left alone.
+func (a *authorizedKeyResource) update(lines []string, keys []*authorizedKey, shouldExist bool) ([]string, bool) {
+	wanted := make(map[string]string, len(keys))
+	for i, key := range keys {
+		wanted[key.id()] = strings.TrimSpace(a.Keys[i])
+	}
+
+	var out []string
+	seen := make(map[string]bool)
+	changed := false
+
+	for _, line := range lines {
+		existing, err := parseAuthorizedKey(line)
+		if err != nil {
+			out = append(out, line)
+			continue
+		}
+
+		want, ok := wanted[existing.id()]
+		switch {
+		case !shouldExist && ok:
+			slog.Info("removing authorized key", "user", a.User, "key", existing.Comment)
+			changed = true
+		case !ok && a.Exclusive:
+			slog.Info("removing authorized key", "user", a.User, "key", existing.Comment)
+			changed = true
+		case !ok || !shouldExist:
+			out = append(out, line)
+		case seen[existing.id()]:
+			// duplicate of a key we already kept
+			changed = true
+		case line != want:
+			slog.Info("updating authorized key", "user", a.User, "key", existing.Comment)
+			out = append(out, want)
+			seen[existing.id()] = true
+			changed = true
+		default:
+			out = append(out, line)
+			seen[existing.id()] = true
+		}
+	}
+
+	if !shouldExist {
+		return out, changed
+	}
+
+	for i, key := range keys {
+		if seen[key.id()] {
+			continue
+		}
+
+		slog.Info("adding authorized key", "user", a.User, "key", key.Comment)
+		out = append(out, strings.TrimSpace(a.Keys[i]))
+		seen[key.id()] = true
+		changed = true
+	}
+
+	return out, changed
+}
+
+func lookupUser(name string) (*user.User, error) {
+	if _, ok := numericID(name); ok {
+		return user.LookupId(name)
+	}
+
+	return user.Lookup(name)
+}
+
+// parses a single authorized_keys line, validating the key
+func parseAuthorizedKey(line string) (*authorizedKey, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil, fmt.Errorf("no key found in %q", line)
+	}
+
+	fields := splitAuthorizedKey(line)
+
+	var key authorizedKey
+	if !slices.Contains(authorizedKeyTypes, fields[0]) {
+		key.Options = fields[0]
+		fields = fields[1:]
+	}
+
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("no key found in %q", line)
+	}
+
+	key.Type = fields[0]
+	key.Blob = fields[1]
+	key.Comment = strings.Join(fields[2:], " ")
+
+	if !slices.Contains(authorizedKeyTypes, key.Type) {
+		return nil, fmt.Errorf("unknown key type %s in %q", key.Type, line)
+	}
+
+	blob, err := base64.StdEncoding.DecodeString(key.Blob)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key data in %q %w", line, err)
+	}
+
+	// the blob starts with the key type as a length prefixed string
+	if len(blob) < 4 {
+		return nil, fmt.Errorf("invalid key data in %q", line)
+	}
+	n := binary.BigEndian.Uint32(blob)
+	if uint64(len(blob)-4) < uint64(n) || !bytes.Equal(blob[4:4+n], []byte(key.Type)) {
+		return nil, fmt.Errorf("key data does not match key type %s in %q", key.Type, line)
+	}
+
+	return &key, nil
+}
+
+// splits on whitespace outside of double quotes, as options may contain spaces
+func splitAuthorizedKey(line string) []string {
+	var (
+		fields  []string
+		current strings.Builder
+		quoted  bool
+		escaped bool
+	)
+
+	for _, r := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\' && quoted:
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+		case unicode.IsSpace(r) && !quoted:
+			if current.Len() > 0 {
+				fields = append(fields, current.String())
+				current.Reset()
+			}
+			continue
+		}
+		current.WriteRune(r)
+	}
+
+	if current.Len() > 0 {
+		fields = append(fields, current.String())
+	}
+
+	return fields
+}
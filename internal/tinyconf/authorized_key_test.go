@@ -0,0 +1,286 @@
+package tinyconf
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"os"
+	"os/user"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// returns a syntactically valid public key line
+func testPublicKey(keyType string, data string, comment string) string {
+	blob := binary.BigEndian.AppendUint32(nil, uint32(len(keyType)))
+	blob = append(blob, keyType...)
+	blob = binary.BigEndian.AppendUint32(blob, uint32(len(data)))
+	blob = append(blob, data...)
+
+	return keyType + " " + base64.StdEncoding.EncodeToString(blob) + " " + comment
+}
+
+func currentUsername(t *testing.T) string {
+	t.Helper()
+
+	u, err := user.Current()
+	require.NoError(t, err)
+
+	return u.Username
+}
+
+func TestAuthorizedKeyResource_Run_CreateFile(t *testing.T) {
+	home := t.TempDir()
+	path := filepath.Join(home, ".ssh", "authorized_keys")
+	key := testPublicKey("ssh-ed25519", "alice", "alice@laptop")
+
+	a := &authorizedKeyResource{
+		User: currentUsername(t),
+		Keys: []string{key},
+		Notify: notifyResource{
+			Service: "test-service",
+		},
+		home: home,
+	}
+
+	ctx := t.Context()
+
+	service, err := a.Run(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "test-service", service)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, key+"\n", string(data))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	info, err = os.Stat(filepath.Dir(path))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o700), info.Mode().Perm())
+
+	service, err = a.Run(ctx)
+	require.NoError(t, err)
+	require.Empty(t, service)
+}
+
+func TestAuthorizedKeyResource_Run_CustomPathLeavesDirectory(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "authorized_keys")
+	path := filepath.Join(dir, "deploy")
+	key := testPublicKey("ssh-ed25519", "alice", "alice@laptop")
+
+	a := &authorizedKeyResource{
+		User: currentUsername(t),
+		Keys: []string{key},
+		Path: &path,
+	}
+
+	// the directory is not created for a custom path
+	_, err := a.Run(t.Context())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "does not exist")
+
+	require.NoError(t, os.Mkdir(dir, 0o755))
+	require.NoError(t, os.Chmod(dir, 0o755))
+
+	_, err = a.Run(t.Context())
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, key+"\n", string(data))
+
+	info, err := os.Stat(dir)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o755), info.Mode().Perm())
+}
+
+func TestAuthorizedKeyResource_Run_AddKeepsExisting(t *testing.T) {
+	existing := testPublicKey("ssh-rsa", "bob", "bob@desktop")
+	path := writeTestFile(t, "# managed keys\n"+existing+"\n", 0o644)
+	key := testPublicKey("ssh-ed25519", "alice", "alice@laptop")
+
+	a := &authorizedKeyResource{
+		User: currentUsername(t),
+		Keys: []string{key},
+		Path: &path,
+	}
+
+	_, err := a.Run(t.Context())
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "# managed keys\n"+existing+"\n"+key+"\n", string(data))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
+
+func TestAuthorizedKeyResource_Run_UpdateOptions(t *testing.T) {
+	key := testPublicKey("ssh-ed25519", "alice", "alice@laptop")
+	path := writeTestFile(t, key+"\n", 0o600)
+	withOptions := `from="10.0.0.0/8",command="/usr/bin/backup --quiet" ` + key
+
+	a := &authorizedKeyResource{
+		User: currentUsername(t),
+		Keys: []string{withOptions},
+		Path: &path,
+	}
+
+	_, err := a.Run(t.Context())
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, withOptions+"\n", string(data))
+}
+
+func TestAuthorizedKeyResource_Run_Exclusive(t *testing.T) {
+	keep := testPublicKey("ssh-ed25519", "alice", "alice@laptop")
+	remove := testPublicKey("ssh-rsa", "bob", "bob@desktop")
+	path := writeTestFile(t, "# comment\n"+remove+"\n"+keep+"\n", 0o600)
+
+	a := &authorizedKeyResource{
+		User:      currentUsername(t),
+		Keys:      []string{keep},
+		Path:      &path,
+		Exclusive: true,
+	}
+
+	_, err := a.Run(t.Context())
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "# comment\n"+keep+"\n", string(data))
+}
+
+func TestAuthorizedKeyResource_Run_Absent(t *testing.T) {
+	keep := testPublicKey("ssh-ed25519", "alice", "alice@laptop")
+	remove := testPublicKey("ssh-rsa", "bob", "bob@desktop")
+	path := writeTestFile(t, keep+"\n"+`no-pty `+remove+"\n", 0o600)
+
+	a := &authorizedKeyResource{
+		User:  currentUsername(t),
+		Keys:  []string{remove},
+		Path:  &path,
+		State: ptr("absent"),
+		Notify: notifyResource{
+			Service: "test-service",
+		},
+	}
+
+	ctx := t.Context()
+
+	service, err := a.Run(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "test-service", service)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, keep+"\n", string(data))
+
+	service, err = a.Run(ctx)
+	require.NoError(t, err)
+	require.Empty(t, service)
+}
+
+func TestAuthorizedKeyResource_Run_InvalidKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "authorized_keys")
+
+	a := &authorizedKeyResource{
+		User: currentUsername(t),
+		Keys: []string{"ssh-ed25519 not-base64!"},
+		Path: &path,
+	}
+
+	_, err := a.Run(t.Context())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid key")
+
+	_, err = os.Stat(path)
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestAuthorizedKeyResource_Run_RefusesSymlinkedDirectory(t *testing.T) {
+	home := t.TempDir()
+	outside := t.TempDir()
+	require.NoError(t, os.Chmod(outside, 0o755))
+	require.NoError(t, os.Symlink(outside, filepath.Join(home, ".ssh")))
+
+	a := &authorizedKeyResource{
+		User: currentUsername(t),
+		Keys: []string{testPublicKey("ssh-ed25519", "alice", "alice@laptop")},
+		home: home,
+	}
+
+	_, err := a.Run(t.Context())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "symlink")
+
+	// the target of the link is left alone
+	info, err := os.Stat(outside)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o755), info.Mode().Perm())
+
+	_, err = os.Stat(filepath.Join(outside, "authorized_keys"))
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestAuthorizedKeyResource_Run_RefusesSymlinkedFile(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), ".ssh")
+	require.NoError(t, os.Mkdir(dir, 0o700))
+
+	outside := t.TempDir()
+	existing := filepath.Join(outside, "shadow")
+	require.NoError(t, os.WriteFile(existing, []byte("secret\n"), 0o600))
+	dangling := filepath.Join(outside, "sudoers")
+
+	key := testPublicKey("ssh-ed25519", "alice", "alice@laptop")
+	for _, target := range []string{existing, dangling} {
+		path := filepath.Join(dir, "authorized_keys")
+		require.NoError(t, os.RemoveAll(path))
+		require.NoError(t, os.Symlink(target, path))
+
+		a := &authorizedKeyResource{
+			User: currentUsername(t),
+			Keys: []string{key},
+			Path: &path,
+		}
+
+		_, err := a.Run(t.Context())
+		require.Error(t, err, target)
+		require.Contains(t, err.Error(), "symlink", target)
+	}
+
+	data, err := os.ReadFile(existing)
+	require.NoError(t, err)
+	require.Equal(t, "secret\n", string(data))
+
+	_, err = os.Stat(dangling)
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestParseAuthorizedKey(t *testing.T) {
+	key := testPublicKey("ssh-ed25519", "alice", "alice@laptop")
+
+	parsed, err := parseAuthorizedKey(`command="echo hello world",no-pty ` + key)
+	require.NoError(t, err)
+	require.Equal(t, `command="echo hello world",no-pty`, parsed.Options)
+	require.Equal(t, "ssh-ed25519", parsed.Type)
+	require.Equal(t, "alice@laptop", parsed.Comment)
+
+	_, err = parseAuthorizedKey("ssh-foo AAAA")
+	require.Error(t, err)
+
+	// blob for a different key type
+	rsa := testPublicKey("ssh-rsa", "alice", "")
+	_, err = parseAuthorizedKey("ssh-ed25519 " + rsa[len("ssh-rsa "):])
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "does not match")
+}
@@ -0,0 +1,146 @@
+package tinyconf
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+)
+
+// blockResource manages a block of text between marker lines in an existing file.
+// Everything outside of the markers is left alone.
+type blockResource struct {
+	Path string `json:"path" validate:"required"`
+	// used to build the marker lines so multiple blocks can live in the same file
+	ID       string  `json:"id" validate:"required"`
+	Contents *string `json:"contents"`
+	// {mark} is replaced by BEGIN or END and {id} by the id
+	Marker      *string        `json:"marker"`
+	InsertAfter *string        `json:"insert_after"`
+	State       *string        `json:"state" validate:"omitempty,oneof=present absent"`
+	Notify      notifyResource `json:"notify"`
+}
+
+const defaultBlockMarker = "# {mark} tinyconf {id}"
+
+func (b *blockResource) Run(ctx context.Context) (string, error) {
+	shouldExist := b.State == nil || *b.State == "present"
+
+	tasks := []func() (bool, error){
+		func() (bool, error) {
+			data, err := os.ReadFile(b.Path)
+			if err != nil {
+				return false, fmt.Errorf("failed to read %s %w", b.Path, err)
+			}
+
+			updated, changed, err := b.update(string(data), shouldExist)
+			if err != nil {
+				return false, err
+			}
+
+			if !changed {
+				return false, nil
+			}
+
+			if err := replaceFile(b.Path, []byte(updated)); err != nil {
+				return false, err
+			}
+
+			return true, nil
+		},
+	}
+
+	// use runTasks in case we add some debugging/logging/etc
+	changed, err := runTasks(tasks)
+	if err != nil {
+		return "", err
+	}
+
+	if changed {
+		return b.Notify.Service, nil
+	}
+
+	return "", nil
+}
+
+func (b *blockResource) markers() (string, string) {
+	marker := defaultBlockMarker
+	if b.Marker != nil {
+		marker = *b.Marker
+	}
+
+	marker = strings.ReplaceAll(marker, "{id}", b.ID)
+
+	return strings.ReplaceAll(marker, "{mark}", "BEGIN"), strings.ReplaceAll(marker, "{mark}", "END")
+}
+
+func (b *blockResource) update(contents string, shouldExist bool) (string, bool, error) {
+	lines, trailingNewline := splitLines(contents)
+	begin, end := b.markers()
+
+	if begin == end {
+		return "", false, fmt.Errorf("marker for %s must contain {mark}", b.Path)
+	}
+
+	start := slices.Index(lines, begin)
+	stop := -1
+	if start != -1 {
+		stop = slices.Index(lines[start:], end)
+		if stop == -1 {
+			return "", false, fmt.Errorf("found %q without %q in %s", begin, end, b.Path)
+		}
+		stop += start
+	}
+
+	if !shouldExist {
+		if start == -1 {
+			return "", false, nil
+		}
+
+		slog.Info("removing block", "path", b.Path, "id", b.ID)
+		lines = slices.Delete(lines, start, stop+1)
+
+		return joinLines(lines, trailingNewline), true, nil
+	}
+
+	block := []string{begin}
+	if b.Contents != nil {
+		body, _ := splitLines(*b.Contents)
+		block = append(block, body...)
+	}
+	block = append(block, end)
+
+	if start != -1 {
+		if slices.Equal(lines[start:stop+1], block) {
+			return "", false, nil
+		}
+
+		slog.Info("updating block", "path", b.Path, "id", b.ID)
+		lines = slices.Replace(lines, start, stop+1, block...)
+
+		return joinLines(lines, trailingNewline), true, nil
+	}
+
+	// default to the end of the file if no anchor matches
+	index := len(lines)
+	if b.InsertAfter != nil {
+		re, err := compileRegexp("insert_after", *b.InsertAfter)
+		if err != nil {
+			return "", false, err
+		}
+		if i := lastMatch(lines, re); i != -1 {
+			index = i + 1
+		}
+	}
+
+	if index == len(lines) {
+		trailingNewline = true
+	}
+
+	slog.Info("inserting block", "path", b.Path, "id", b.ID, "index", index)
+	lines = slices.Insert(lines, index, block...)
+
+	return joinLines(lines, trailingNewline), true, nil
+}
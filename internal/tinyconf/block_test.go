@@ -0,0 +1,188 @@
+package tinyconf
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockResource_Run_InsertAtEnd(t *testing.T) {
+	filePath := writeTestFile(t, "127.0.0.1 localhost\n", 0o644)
+	contents := "10.0.0.1 db\n10.0.0.2 cache\n"
+
+	b := &blockResource{
+		Path:     filePath,
+		ID:       "hosts",
+		Contents: &contents,
+		Notify: notifyResource{
+			Service: "test-service",
+		},
+	}
+
+	ctx := t.Context()
+
+	service, err := b.Run(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "test-service", service)
+
+	data, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	require.Equal(t, "127.0.0.1 localhost\n# BEGIN tinyconf hosts\n10.0.0.1 db\n10.0.0.2 cache\n# END tinyconf hosts\n", string(data))
+
+	// idempotent
+	service, err = b.Run(ctx)
+	require.NoError(t, err)
+	require.Empty(t, service)
+}
+
+func TestBlockResource_Run_InsertAfter(t *testing.T) {
+	filePath := writeTestFile(t, "first\nanchor\nlast\n", 0o644)
+	contents := "inside"
+	after := "^anchor$"
+
+	b := &blockResource{
+		Path:        filePath,
+		ID:          "test",
+		Contents:    &contents,
+		InsertAfter: &after,
+	}
+
+	_, err := b.Run(t.Context())
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	require.Equal(t, "first\nanchor\n# BEGIN tinyconf test\ninside\n# END tinyconf test\nlast\n", string(data))
+}
+
+func TestBlockResource_Run_UpdateInPlace(t *testing.T) {
+	filePath := writeTestFile(t, "before\n# BEGIN tinyconf test\nold\n# END tinyconf test\nafter\n", 0o644)
+	contents := "new\nlines\n"
+
+	b := &blockResource{
+		Path:     filePath,
+		ID:       "test",
+		Contents: &contents,
+		Notify: notifyResource{
+			Service: "test-service",
+		},
+	}
+
+	service, err := b.Run(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, "test-service", service)
+
+	data, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	require.Equal(t, "before\n# BEGIN tinyconf test\nnew\nlines\n# END tinyconf test\nafter\n", string(data))
+}
+
+func TestBlockResource_Run_RemoveBlock(t *testing.T) {
+	filePath := writeTestFile(t, "before\n# BEGIN tinyconf test\nold\n# END tinyconf test\nafter\n", 0o644)
+	absent := "absent"
+
+	b := &blockResource{
+		Path:  filePath,
+		ID:    "test",
+		State: &absent,
+		Notify: notifyResource{
+			Service: "test-service",
+		},
+	}
+
+	ctx := t.Context()
+
+	service, err := b.Run(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "test-service", service)
+
+	data, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	require.Equal(t, "before\nafter\n", string(data))
+
+	service, err = b.Run(ctx)
+	require.NoError(t, err)
+	require.Empty(t, service)
+}
+
+func TestBlockResource_Run_MultipleBlocks(t *testing.T) {
+	filePath := writeTestFile(t, "", 0o644)
+	one := "one"
+	two := "two"
+
+	ctx := t.Context()
+
+	_, err := (&blockResource{Path: filePath, ID: "one", Contents: &one}).Run(ctx)
+	require.NoError(t, err)
+
+	_, err = (&blockResource{Path: filePath, ID: "two", Contents: &two}).Run(ctx)
+	require.NoError(t, err)
+
+	updated := "uno"
+	_, err = (&blockResource{Path: filePath, ID: "one", Contents: &updated}).Run(ctx)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	require.Equal(t, "# BEGIN tinyconf one\nuno\n# END tinyconf one\n# BEGIN tinyconf two\ntwo\n# END tinyconf two\n", string(data))
+}
+
+func TestBlockResource_Run_CustomMarker(t *testing.T) {
+	filePath := writeTestFile(t, "", 0o644)
+	contents := "body"
+	marker := "// {mark} managed {id}"
+
+	b := &blockResource{
+		Path:     filePath,
+		ID:       "test",
+		Contents: &contents,
+		Marker:   &marker,
+	}
+
+	_, err := b.Run(t.Context())
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	require.Equal(t, "// BEGIN managed test\nbody\n// END managed test\n", string(data))
+}
+
+func TestBlockResource_Run_ErrorMarkerWithoutMark(t *testing.T) {
+	filePath := writeTestFile(t, "", 0o644)
+	marker := "# managed"
+
+	b := &blockResource{
+		Path:   filePath,
+		ID:     "test",
+		Marker: &marker,
+	}
+
+	_, err := b.Run(t.Context())
+	require.Error(t, err)
+}
+
+func TestBlockResource_Run_ErrorUnterminatedBlock(t *testing.T) {
+	filePath := writeTestFile(t, "# BEGIN tinyconf test\nold\n", 0o644)
+	contents := "new"
+
+	b := &blockResource{
+		Path:     filePath,
+		ID:       "test",
+		Contents: &contents,
+	}
+
+	_, err := b.Run(t.Context())
+	require.Error(t, err)
+}
+
+func TestBlockResource_Run_ErrorMissingFile(t *testing.T) {
+	b := &blockResource{
+		Path: filepath.Join(t.TempDir(), "missing"),
+		ID:   "test",
+	}
+
+	_, err := b.Run(t.Context())
+	require.Error(t, err)
+}
@@ -352,3 +352,893 @@ resources:
 	require.Len(t, cfg.Resources, 1)
 	require.Equal(t, "package", cfg.Resources[0].Type)
 }
+
+func TestConfigFromBytes_ValidLine(t *testing.T) {
+	yaml := `
+resources:
+  - type: line
+    path: /etc/php/8.3/apache2/php.ini
+    regexp: '^memory_limit\s*='
+    line: memory_limit = 512M
+    notify:
+      service: apache2
+`
+	cfg, err := configFromBytes([]byte(yaml))
+	require.NoError(t, err)
+	require.Len(t, cfg.Resources, 1)
+	require.Equal(t, "line", cfg.Resources[0].Type)
+	require.NotNil(t, cfg.Resources[0].Line)
+	require.Equal(t, "memory_limit = 512M", *cfg.Resources[0].Line.Line)
+	require.Equal(t, "apache2", cfg.Resources[0].Line.Notify.Service)
+}
+
+func TestConfigFromBytes_InvalidLine(t *testing.T) {
+	yaml := `
+resources:
+  - type: line
+    path: /etc/hosts
+    line: 127.0.0.1 localhost
+    insert_after: foo
+    insert_before: bar
+`
+	_, err := configFromBytes([]byte(yaml))
+	require.Error(t, err)
+}
+
+func TestConfigFromBytes_ValidBlock(t *testing.T) {
+	yaml := `
+resources:
+  - type: block
+    path: /etc/hosts
+    id: app
+    contents: |
+      10.0.0.1 db
+`
+	cfg, err := configFromBytes([]byte(yaml))
+	require.NoError(t, err)
+	require.Len(t, cfg.Resources, 1)
+	require.Equal(t, "block", cfg.Resources[0].Type)
+	require.NotNil(t, cfg.Resources[0].Block)
+	require.Equal(t, "app", cfg.Resources[0].Block.ID)
+}
+
+func TestConfigFromBytes_BlockMissingID(t *testing.T) {
+	yaml := `
+resources:
+  - type: block
+    path: /etc/hosts
+`
+	_, err := configFromBytes([]byte(yaml))
+	require.Error(t, err)
+}
+
+func TestConfigFromBytes_ValidConfigValue(t *testing.T) {
+	yaml := `
+resources:
+  - type: config_value
+    path: /etc/php/8.3/apache2/php.ini
+    format: ini
+    section: PHP
+    key: memory_limit
+    value: 512M
+`
+	cfg, err := configFromBytes([]byte(yaml))
+	require.NoError(t, err)
+	require.Len(t, cfg.Resources, 1)
+	require.Equal(t, "config_value", cfg.Resources[0].Type)
+	require.NotNil(t, cfg.Resources[0].ConfigValue)
+	require.Equal(t, "512M", cfg.Resources[0].ConfigValue.Value)
+}
+
+func TestConfigFromBytes_ConfigValueInvalidFormat(t *testing.T) {
+	yaml := `
+resources:
+  - type: config_value
+    path: /etc/app.xml
+    format: xml
+    key: a
+    value: b
+`
+	_, err := configFromBytes([]byte(yaml))
+	require.Error(t, err)
+}
+
+func TestConfigFromBytes_ConfigValueRequiresValue(t *testing.T) {
+	yaml := `
+resources:
+  - type: config_value
+    path: /etc/app.json
+    format: json
+    key: a
+`
+	_, err := configFromBytes([]byte(yaml))
+	require.Error(t, err)
+
+	yaml = `
+resources:
+  - type: config_value
+    path: /etc/app.json
+    format: json
+    key: a
+    state: absent
+  - type: config_value
+    path: /etc/app.json
+    format: json
+    key: b
+    value: false
+`
+	_, err = configFromBytes([]byte(yaml))
+	require.NoError(t, err)
+}
+
+func TestConfigFromBytes_PurgeManagedPaths(t *testing.T) {
+	yaml := `
+resources:
+  - type: directory
+    path: /etc/apache2/conf-enabled/
+    purge: true
+  - type: file
+    path: /etc/apache2/conf-enabled/php-index.conf
+  - type: line
+    path: /etc/hosts
+    line: 127.0.0.1 localhost
+`
+	cfg, err := configFromBytes([]byte(yaml))
+	require.NoError(t, err)
+	cfg.purgeDryRun = true
+
+	_, err = cfg.getRunners()
+	require.NoError(t, err)
+	require.True(t, cfg.Resources[0].Directory.purgeDryRun)
+
+	require.Equal(t, []string{
+		"/etc/apache2/conf-enabled",
+		"/etc/apache2/conf-enabled/php-index.conf",
+		"/etc/hosts",
+	}, cfg.Resources[0].Directory.managed)
+}
+
+func TestConfigFromBytes_PurgeManagedTimerPaths(t *testing.T) {
+	yaml := `
+resources:
+  - type: directory
+    path: /etc/systemd/system
+    purge: true
+  - type: systemd_unit
+    name: app.service
+    contents: |
+      [Service]
+      ExecStart=/usr/local/bin/app
+  - type: timer
+    name: backup
+    command: /usr/local/bin/backup
+    on_calendar: daily
+`
+	cfg, err := configFromBytes([]byte(yaml))
+	require.NoError(t, err)
+
+	_, err = cfg.getRunners()
+	require.NoError(t, err)
+
+	require.Equal(t, []string{
+		"/etc/systemd/system",
+		"/etc/systemd/system/app.service",
+		"/etc/systemd/system/backup.service",
+		"/etc/systemd/system/backup.timer",
+	}, cfg.Resources[0].Directory.managed)
+}
+
+func TestConfigFromBytes_PurgeManagedKeyPaths(t *testing.T) {
+	yaml := `
+resources:
+  - type: directory
+    path: /etc/apt/keyrings
+    purge: true
+  - type: apt_repository
+    name: docker
+    uris: [https://download.docker.com/linux/debian]
+    suites: [bookworm]
+    key: https://download.docker.com/linux/debian/gpg
+    key_fingerprint: 9DC858229FC7DD38854AE2D88D81803C0EBFCD88
+  - type: authorized_key
+    user: deploy
+    path: /srv/deploy/.ssh/authorized_keys
+    keys:
+      - ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIGx0 deploy
+  - type: authorized_key
+    user: root
+    keys:
+      - ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIGx0 root
+`
+	cfg, err := configFromBytes([]byte(yaml))
+	require.NoError(t, err)
+
+	_, err = cfg.getRunners()
+	require.NoError(t, err)
+
+	require.Equal(t, []string{
+		"/etc/apt/keyrings",
+		"/etc/apt/sources.list.d/docker.sources",
+		"/etc/apt/keyrings/docker.asc",
+		"/etc/apt/keyrings/docker.gpg",
+		"/srv/deploy/.ssh/authorized_keys",
+		"/root/.ssh/authorized_keys",
+	}, cfg.Resources[0].Directory.managed)
+}
+
+func TestConfigFromBytes_DirectoryAbsent(t *testing.T) {
+	yaml := `
+directory_remove_allowed:
+  - /srv/apps
+resources:
+  - type: directory
+    path: /srv/apps/old
+    state: absent
+    force: true
+`
+	cfg, err := configFromBytes([]byte(yaml))
+	require.NoError(t, err)
+	require.Equal(t, "absent", *cfg.Resources[0].Directory.State)
+	require.True(t, cfg.Resources[0].Directory.Force)
+
+	_, err = cfg.getRunners()
+	require.NoError(t, err)
+	require.Equal(t, []string{"/srv/apps"}, cfg.Resources[0].Directory.removeAllowed)
+}
+
+func TestConfigFromBytes_DirectoryInvalidState(t *testing.T) {
+	yaml := `
+resources:
+  - type: directory
+    path: /srv/apps/old
+    state: gone
+`
+	_, err := configFromBytes([]byte(yaml))
+	require.Error(t, err)
+}
+
+func TestConfigFromBytes_ValidSync(t *testing.T) {
+	yaml := `
+resources:
+  - type: sync
+    source: /opt/releases/site
+    destination: /var/www/html
+    owner: www-data
+    delete: true
+    exclude:
+      - .git
+`
+	cfg, err := configFromBytes([]byte(yaml))
+	require.NoError(t, err)
+	require.Len(t, cfg.Resources, 1)
+	require.Equal(t, "sync", cfg.Resources[0].Type)
+	require.NotNil(t, cfg.Resources[0].Sync)
+	require.True(t, cfg.Resources[0].Sync.Delete)
+	require.Equal(t, []string{".git"}, cfg.Resources[0].Sync.Exclude)
+}
+
+func TestConfigFromBytes_SyncMissingDestination(t *testing.T) {
+	yaml := `
+resources:
+  - type: sync
+    source: /opt/releases/site
+`
+	_, err := configFromBytes([]byte(yaml))
+	require.Error(t, err)
+}
+
+func TestConfigFromBytes_ValidArchive(t *testing.T) {
+	yaml := `
+resources:
+  - type: archive
+    source: https://example.com/app-1.0.tar.gz
+    destination: /opt/app
+    strip_components: 1
+    checksum: abc123
+`
+	cfg, err := configFromBytes([]byte(yaml))
+	require.NoError(t, err)
+	require.Len(t, cfg.Resources, 1)
+	require.Equal(t, "archive", cfg.Resources[0].Type)
+	require.NotNil(t, cfg.Resources[0].Archive)
+	require.Equal(t, 1, cfg.Resources[0].Archive.StripComponents)
+}
+
+func TestConfigFromBytes_ArchiveInvalidFormat(t *testing.T) {
+	yaml := `
+resources:
+  - type: archive
+    source: /tmp/app.rar
+    destination: /opt/app
+    format: rar
+`
+	_, err := configFromBytes([]byte(yaml))
+	require.Error(t, err)
+}
+
+func TestConfigFromBytes_NumericOwnerAndGroup(t *testing.T) {
+	yaml := `
+resources:
+  - type: file
+    path: /srv/nfs/data.txt
+    owner: 1000
+    group: "1001"
+  - type: directory
+    path: /srv/nfs
+    owner: 0
+`
+	cfg, err := configFromBytes([]byte(yaml))
+	require.NoError(t, err)
+	require.Equal(t, "1000", *cfg.Resources[0].File.Owner)
+	require.Equal(t, "1001", *cfg.Resources[0].File.Group)
+	require.Equal(t, "0", *cfg.Resources[1].Directory.Owner)
+}
+
+func TestConfigFromBytes_ValidUser(t *testing.T) {
+	yaml := `
+resources:
+  - type: user
+    name: deploy
+    uid: 1500
+    groups:
+      - www-data
+    shell: /bin/bash
+    create_home: true
+    locked: true
+`
+	cfg, err := configFromBytes([]byte(yaml))
+	require.NoError(t, err)
+	require.Len(t, cfg.Resources, 1)
+	require.Equal(t, "user", cfg.Resources[0].Type)
+	require.NotNil(t, cfg.Resources[0].User)
+	require.Equal(t, 1500, *cfg.Resources[0].User.UID)
+	require.True(t, *cfg.Resources[0].User.Locked)
+}
+
+func TestConfigFromBytes_UserInvalidState(t *testing.T) {
+	yaml := `
+resources:
+  - type: user
+    name: deploy
+    state: deleted
+`
+	_, err := configFromBytes([]byte(yaml))
+	require.Error(t, err)
+}
+
+func TestConfigFromBytes_ValidGroup(t *testing.T) {
+	yaml := `
+resources:
+  - type: group
+    name: deploy
+    gid: 1600
+    members:
+      - deploy
+    exclusive: true
+  - type: directory
+    path: /srv/app
+    group: deploy
+`
+	cfg, err := configFromBytes([]byte(yaml))
+	require.NoError(t, err)
+	require.Len(t, cfg.Resources, 2)
+	require.Equal(t, "group", cfg.Resources[0].Type)
+	require.NotNil(t, cfg.Resources[0].Group)
+	require.Equal(t, 1600, *cfg.Resources[0].Group.GID)
+	require.True(t, cfg.Resources[0].Group.Exclusive)
+}
+
+func TestConfigFromBytes_ValidAuthorizedKey(t *testing.T) {
+	yaml := `
+resources:
+  - type: authorized_key
+    user: deploy
+    exclusive: true
+    keys:
+      - ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIA deploy@ci
+`
+	cfg, err := configFromBytes([]byte(yaml))
+	require.NoError(t, err)
+	require.Len(t, cfg.Resources, 1)
+	require.NotNil(t, cfg.Resources[0].AuthorizedKey)
+	require.Equal(t, "deploy", cfg.Resources[0].AuthorizedKey.User)
+	require.True(t, cfg.Resources[0].AuthorizedKey.Exclusive)
+}
+
+func TestConfigFromBytes_AuthorizedKeyMissingKeys(t *testing.T) {
+	yaml := `
+resources:
+  - type: authorized_key
+    user: deploy
+`
+	_, err := configFromBytes([]byte(yaml))
+	require.Error(t, err)
+}
+
+func TestConfigFromBytes_ValidExec(t *testing.T) {
+	yaml := `
+resources:
+  - type: exec
+    name: enable-rewrite
+    command: a2enmod rewrite
+    unless: apache2ctl -M | grep -q rewrite_module
+    timeout: 30s
+    user: 0
+    notify:
+      service: apache2
+`
+	cfg, err := configFromBytes([]byte(yaml))
+	require.NoError(t, err)
+	require.Len(t, cfg.Resources, 1)
+	require.NotNil(t, cfg.Resources[0].Exec)
+	require.Equal(t, "a2enmod rewrite", cfg.Resources[0].Exec.Command)
+	require.Equal(t, "0", *cfg.Resources[0].Exec.User)
+	require.Equal(t, "apache2", cfg.Resources[0].Exec.Notify.Service)
+}
+
+func TestConfigFromBytes_ExecInvalidTimeout(t *testing.T) {
+	yaml := `
+resources:
+  - type: exec
+    command: apt-get clean
+    timeout: soon
+`
+	_, err := configFromBytes([]byte(yaml))
+	require.Error(t, err)
+}
+
+func TestConfigFromBytes_ExecRefreshOnlyRequiresName(t *testing.T) {
+	yaml := `
+resources:
+  - type: exec
+    command: update-ca-certificates
+    refresh_only: true
+`
+	_, err := configFromBytes([]byte(yaml))
+	require.Error(t, err)
+}
+
+func TestConfigFromBytes_PackageVersion(t *testing.T) {
+	yaml := `
+resources:
+  - type: package
+    name: nginx
+    state: installed
+    version: "1.24*"
+    hold: true
+`
+	cfg, err := configFromBytes([]byte(yaml))
+	require.NoError(t, err)
+	require.Equal(t, "1.24*", *cfg.Resources[0].Package.Version)
+	require.True(t, *cfg.Resources[0].Package.Hold)
+}
+
+func TestConfigFromBytes_PackageVersionWithLatest(t *testing.T) {
+	yaml := `
+resources:
+  - type: package
+    name: nginx
+    state: latest
+    version: "1.24.0"
+`
+	_, err := configFromBytes([]byte(yaml))
+	require.Error(t, err)
+}
+
+func TestConfigFromBytes_UpdateCache(t *testing.T) {
+	yaml := `
+update_cache: 24h
+resources:
+  - type: package
+    name: nginx
+    state: installed
+    update_cache: never
+`
+	cfg, err := configFromBytes([]byte(yaml))
+	require.NoError(t, err)
+	require.Equal(t, "24h", *cfg.UpdateCache)
+	require.Equal(t, "never", *cfg.Resources[0].Package.UpdateCache)
+
+	runners, err := cfg.getRunners()
+	require.NoError(t, err)
+	require.Equal(t, "24h", runners[0].(*packageResource).defaultUpdateCache)
+}
+
+func TestConfigFromBytes_InvalidUpdateCache(t *testing.T) {
+	for _, yaml := range []string{
+		`
+update_cache: sometimes
+resources: []
+`,
+		`
+resources:
+  - type: package
+    name: nginx
+    state: installed
+    update_cache: sometimes
+`,
+	} {
+		_, err := configFromBytes([]byte(yaml))
+		require.Error(t, err)
+	}
+}
+
+func TestConfigFromBytes_ValidAptRepository(t *testing.T) {
+	yaml := `
+resources:
+  - type: apt_repository
+    name: docker
+    uris:
+      - https://download.docker.com/linux/ubuntu
+    suites:
+      - noble
+    components:
+      - stable
+    key: https://download.docker.com/linux/ubuntu/gpg
+    key_fingerprint: 9DC858229FC7DD38854AE2D88D81803C0EBFCD88
+  - type: package
+    name: docker-ce
+    state: installed
+    provider: apt
+`
+	cfg, err := configFromBytes([]byte(yaml))
+	require.NoError(t, err)
+	require.NotNil(t, cfg.Resources[0].AptRepository)
+	require.Equal(t, []string{"noble"}, cfg.Resources[0].AptRepository.Suites)
+
+	runners, err := cfg.getRunners()
+	require.NoError(t, err)
+
+	// the repository marks the same apt manager the package uses as stale
+	require.Same(t, runners[1].(*packageResource).manager, runners[0].(*aptRepositoryResource).cache)
+}
+
+func TestConfigFromBytes_AptRepositoryKeyRequiresFingerprint(t *testing.T) {
+	yaml := `
+resources:
+  - type: apt_repository
+    name: docker
+    uris:
+      - https://download.docker.com/linux/ubuntu
+    suites:
+      - noble
+    key: https://download.docker.com/linux/ubuntu/gpg
+`
+	_, err := configFromBytes([]byte(yaml))
+	require.Error(t, err)
+}
+
+func TestConfigFromBytes_AptRepositoryAbsent(t *testing.T) {
+	yaml := `
+resources:
+  - type: apt_repository
+    name: docker
+    state: absent
+`
+	_, err := configFromBytes([]byte(yaml))
+	require.NoError(t, err)
+}
+
+func TestConfigFromBytes_PackageLocations(t *testing.T) {
+	yaml := `
+resources:
+  - type: package
+    name: requests
+    state: installed
+    provider: pip
+    virtualenv: /opt/app/venv
+  - type: package
+    name: typescript
+    state: installed
+    provider: npm
+    prefix: /opt/node
+`
+	cfg, err := configFromBytes([]byte(yaml))
+	require.NoError(t, err)
+	require.Equal(t, "/opt/app/venv", *cfg.Resources[0].Package.Virtualenv)
+	require.Equal(t, "/opt/node", *cfg.Resources[1].Package.Prefix)
+}
+
+func TestConfigFromBytes_PackageLocationWrongProvider(t *testing.T) {
+	for _, yaml := range []string{
+		`
+resources:
+  - type: package
+    name: requests
+    state: installed
+    virtualenv: /opt/app/venv
+`,
+		`
+resources:
+  - type: package
+    name: typescript
+    state: installed
+    provider: pip
+    prefix: /opt/node
+`,
+	} {
+		_, err := configFromBytes([]byte(yaml))
+		require.Error(t, err)
+	}
+}
+
+func TestConfigFromBytes_PackagePreseed(t *testing.T) {
+	yaml := `
+resources:
+  - type: package
+    name: unattended-upgrades
+    state: installed
+    preseed:
+      - question: unattended-upgrades/enable_auto_updates
+        type: boolean
+        value: true
+`
+	cfg, err := configFromBytes([]byte(yaml))
+	require.NoError(t, err)
+	require.Equal(t, "true", cfg.Resources[0].Package.Preseed[0].value())
+
+	_, err = configFromBytes([]byte(`
+resources:
+  - type: package
+    name: tzdata
+    state: installed
+    preseed:
+      - question: tzdata/Areas
+        type: dropdown
+        value: Etc
+`))
+	require.Error(t, err)
+}
+
+func TestConfigFromBytes_ServiceEnabled(t *testing.T) {
+	yaml := `
+resources:
+  - type: service
+    name: nginx
+    state: running
+    enabled: true
+  - type: service
+    name: apache2
+    state: stopped
+    enabled: false
+    masked: true
+`
+	cfg, err := configFromBytes([]byte(yaml))
+	require.NoError(t, err)
+	require.True(t, *cfg.Resources[0].Service.Enabled)
+	require.True(t, *cfg.Resources[1].Service.Masked)
+}
+
+func TestConfigFromBytes_NotifyAction(t *testing.T) {
+	yaml := `
+resources:
+  - type: file
+    path: /etc/nginx/nginx.conf
+    contents: "events {}"
+    notify:
+      service: nginx
+      action: reload
+`
+	cfg, err := configFromBytes([]byte(yaml))
+	require.NoError(t, err)
+	require.Equal(t, "reload", cfg.Resources[0].File.Notify.Action)
+
+	_, err = configFromBytes([]byte(`
+resources:
+  - type: file
+    path: /etc/nginx/nginx.conf
+    contents: "events {}"
+    notify:
+      service: nginx
+      action: bounce
+`))
+	require.Error(t, err)
+}
+
+func TestConfigFromBytes_ValidSystemdUnit(t *testing.T) {
+	yaml := `
+init: systemd
+resources:
+  - type: systemd_unit
+    name: app.service
+    sections:
+      Service:
+        ExecStart: /usr/local/bin/app
+        Environment:
+          - PORT=8080
+          - ENV=production
+  - type: systemd_unit
+    name: nginx.service
+    drop_in: override
+    contents: |
+      [Service]
+      LimitNOFILE=65536
+  - type: service
+    name: app.service
+    state: running
+`
+	cfg, err := configFromBytes([]byte(yaml))
+	require.NoError(t, err)
+	require.Equal(t, "override", *cfg.Resources[1].SystemdUnit.DropIn)
+
+	runners, err := cfg.getRunners()
+	require.NoError(t, err)
+
+	// units and services share a manager so daemon-reload is batched
+	require.Same(t, cfg.serviceManager(), runners[0].(*systemdUnitResource).reloader)
+	require.Same(t, cfg.serviceManager(), runners[2].(*serviceResource).manager)
+}
+
+func TestConfigFromBytes_SystemdUnitContentsAndSections(t *testing.T) {
+	yaml := `
+resources:
+  - type: systemd_unit
+    name: app.service
+    contents: "[Service]"
+    sections:
+      Service:
+        ExecStart: /usr/local/bin/app
+`
+	_, err := configFromBytes([]byte(yaml))
+	require.Error(t, err)
+}
+
+func TestConfigFromBytes_ValidCronAndTimer(t *testing.T) {
+	yaml := `
+init: systemd
+resources:
+  - type: cron
+    name: backup
+    schedule: "30 2 * * *"
+    user: backup
+    command: /usr/local/bin/backup
+    env:
+      MAILTO: ops@example.com
+  - type: cron
+    name: old-backup
+    state: absent
+  - type: timer
+    name: cleanup
+    command: /usr/local/bin/cleanup
+    on_calendar: daily
+    persistent: true
+`
+	cfg, err := configFromBytes([]byte(yaml))
+	require.NoError(t, err)
+	require.Equal(t, "30 2 * * *", cfg.Resources[0].Cron.Schedule)
+	require.Equal(t, "daily", *cfg.Resources[2].Timer.OnCalendar)
+
+	runners, err := cfg.getRunners()
+	require.NoError(t, err)
+	require.Same(t, cfg.serviceManager(), runners[2].(*timerResource).manager)
+}
+
+func TestConfigFromBytes_InvalidCron(t *testing.T) {
+	for _, yaml := range []string{
+		`
+resources:
+  - type: cron
+    name: backup
+    schedule: "every day"
+    command: /usr/local/bin/backup
+`,
+		`
+resources:
+  - type: cron
+    name: backup.sh
+    schedule: "@daily"
+    command: /usr/local/bin/backup
+`,
+		`
+resources:
+  - type: cron
+    name: backup
+    command: /usr/local/bin/backup
+`,
+	} {
+		_, err := configFromBytes([]byte(yaml))
+		require.Error(t, err)
+	}
+}
+
+func TestConfigFromBytes_Healthcheck(t *testing.T) {
+	yaml := `
+resources:
+  - type: service
+    name: apache2
+    state: running
+    healthcheck:
+      http: http://localhost/server-status
+      status: 200
+      timeout: 10s
+  - type: file
+    path: /etc/apache2/apache2.conf
+    contents: "ServerName localhost"
+    notify:
+      service: apache2
+      healthcheck:
+        tcp: localhost:80
+`
+	cfg, err := configFromBytes([]byte(yaml))
+	require.NoError(t, err)
+	require.Equal(t, 200, *cfg.Resources[0].Service.Healthcheck.Status)
+	require.Equal(t, "localhost:80", *cfg.Resources[1].File.Notify.Healthcheck.TCP)
+
+	for _, invalid := range []string{
+		`
+resources:
+  - type: service
+    name: apache2
+    state: running
+    healthcheck:
+      timeout: 10s
+`,
+		`
+resources:
+  - type: service
+    name: apache2
+    state: running
+    healthcheck:
+      tcp: localhost:80
+      command: "true"
+`,
+		`
+resources:
+  - type: service
+    name: apache2
+    state: running
+    healthcheck:
+      tcp: localhost:80
+      status: 200
+`,
+		`
+resources:
+  - type: service
+    name: apache2
+    state: running
+    healthcheck:
+      tcp: localhost:80
+      timeout: 10
+`,
+		`
+resources:
+  - type: file
+    path: /etc/apache2/apache2.conf
+    contents: "ServerName localhost"
+    notify:
+      service: apache2
+      healthcheck:
+        tcp: localhost:80
+        timeout: soon
+`,
+	} {
+		_, err := configFromBytes([]byte(invalid))
+		require.Error(t, err)
+	}
+}
+
+func TestConfigFromBytes_Init(t *testing.T) {
+	yaml := `
+init: openrc
+resources:
+  - type: service
+    name: nginx
+    state: running
+  - type: timer
+    name: backup
+    command: /usr/local/bin/backup
+    on_calendar: daily
+`
+	cfg, err := configFromBytes([]byte(yaml))
+	require.NoError(t, err)
+
+	runners, err := cfg.getRunners()
+	require.NoError(t, err)
+
+	require.IsType(t, &openrcServiceManager{}, runners[0].(*serviceResource).manager)
+	// timers always use systemd
+	require.Same(t, cfg.systemdManager(), runners[1].(*timerResource).manager)
+
+	_, err = configFromBytes([]byte("init: upstart\nresources: []\n"))
+	require.Error(t, err)
+}
@@ -0,0 +1,571 @@
+package tinyconf
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// configValueResource sets or deletes a single key in a structured config file.
+// The file is only written when the value actually changes.
+type configValueResource struct {
+	Path   string `json:"path" validate:"required"`
+	Format string `json:"format" validate:"required,oneof=ini json yaml toml"`
+	// dot separated path for json, yaml, and toml. for ini, this is the
+	// literal key name within section
+	Key string `json:"key" validate:"required"`
+	// ini only. empty means keys before the first section
+	Section *string        `json:"section"`
+	Value   any            `json:"value" validate:"required_unless=State absent"`
+	State   *string        `json:"state" validate:"omitempty,oneof=present absent"`
+	Notify  notifyResource `json:"notify"`
+}
+
+// each supported format implements this.
+// data may be empty.
+type configEditor interface {
+	// returns the updated contents and whether anything changed
+	Set(data []byte, key []string, value any) ([]byte, bool, error)
+	Delete(data []byte, key []string) ([]byte, bool, error)
+}
+
+func (c *configValueResource) Run(ctx context.Context) (string, error) {
+	shouldExist := c.State == nil || *c.State == "present"
+
+	if shouldExist && c.Value == nil {
+		return "", fmt.Errorf("value is required for %s in %s when state is present", c.Key, c.Path)
+	}
+
+	editor, err := c.editor()
+	if err != nil {
+		return "", err
+	}
+
+	key := strings.Split(c.Key, ".")
+	if c.Format == "ini" {
+		var section string
+		if c.Section != nil {
+			section = *c.Section
+		}
+		key = []string{section, c.Key}
+	}
+
+	tasks := []func() (bool, error){
+		func() (bool, error) {
+			data, err := os.ReadFile(c.Path)
+			if err != nil {
+				return false, fmt.Errorf("failed to read %s %w", c.Path, err)
+			}
+
+			var (
+				updated []byte
+				changed bool
+			)
+			if shouldExist {
+				updated, changed, err = editor.Set(data, key, c.Value)
+			} else {
+				updated, changed, err = editor.Delete(data, key)
+			}
+			if err != nil {
+				return false, fmt.Errorf("failed to update %s in %s %w", c.Key, c.Path, err)
+			}
+
+			if !changed {
+				return false, nil
+			}
+
+			if shouldExist {
+				slog.Info("setting config value", "path", c.Path, "key", c.Key, "value", c.Value)
+			} else {
+				slog.Info("removing config value", "path", c.Path, "key", c.Key)
+			}
+
+			if err := replaceFile(c.Path, updated); err != nil {
+				return false, err
+			}
+
+			return true, nil
+		},
+	}
+
+	// use runTasks in case we add some debugging/logging/etc
+	changed, err := runTasks(tasks)
+	if err != nil {
+		return "", err
+	}
+
+	if changed {
+		return c.Notify.Service, nil
+	}
+
+	return "", nil
+}
+
+func (c *configValueResource) editor() (configEditor, error) {
+	switch c.Format {
+	case "ini":
+		return &iniEditor{}, nil
+	case "json":
+		return &jsonEditor{}, nil
+	case "toml":
+		return &mapEditor{unmarshal: toml.Unmarshal, marshal: toml.Marshal, convert: integerFloats}, nil
+	case "yaml":
+		return &yamlEditor{}, nil
+	default:
+		// validation should catch this, but in case
+		return nil, fmt.Errorf("unexpected config format %s", c.Format)
+	}
+}
+
+// values from the config file and the target file may be decoded into different
+// types (int vs float64, etc). round trip through json so they can be compared.
+func normalizeValue(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var out any
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+func valuesEqual(a, b any) (bool, error) {
+	a, err := normalizeValue(a)
+	if err != nil {
+		return false, err
+	}
+
+	b, err = normalizeValue(b)
+	if err != nil {
+		return false, err
+	}
+
+	return reflect.DeepEqual(a, b), nil
+}
+
+// numbers from the config are always float64, but toml distinguishes
+// between integers and floats. assume whole numbers are meant to be integers.
+func integerFloats(v any) any {
+	switch val := v.(type) {
+	case float64:
+		if val == math.Trunc(val) && math.Abs(val) < math.MaxInt64 {
+			return int64(val)
+		}
+		return val
+	case []any:
+		out := make([]any, len(val))
+		for i, item := range val {
+			out[i] = integerFloats(item)
+		}
+		return out
+	case map[string]any:
+		out := make(map[string]any, len(val))
+		for k, item := range val {
+			out[k] = integerFloats(item)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// mapEditor works on formats that decode into plain maps.
+// comments are not preserved and keys are written in sorted order.
+type mapEditor struct {
+	unmarshal func([]byte, any) error
+	marshal   func(any) ([]byte, error)
+	// optional conversion of the value before it is written
+	convert func(any) any
+}
+
+func (m *mapEditor) load(data []byte) (map[string]any, error) {
+	doc := map[string]any{}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return doc, nil
+	}
+
+	if err := m.unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+func (m *mapEditor) Set(data []byte, key []string, value any) ([]byte, bool, error) {
+	doc, err := m.load(data)
+	if err != nil {
+		return nil, false, err
+	}
+
+	parent := doc
+	for i, k := range key[:len(key)-1] {
+		next, ok := parent[k]
+		if !ok {
+			child := map[string]any{}
+			parent[k] = child
+			parent = child
+			continue
+		}
+
+		child, ok := next.(map[string]any)
+		if !ok {
+			return nil, false, fmt.Errorf("%s is not a map", strings.Join(key[:i+1], "."))
+		}
+		parent = child
+	}
+
+	last := key[len(key)-1]
+	if current, ok := parent[last]; ok {
+		equal, err := valuesEqual(current, value)
+		if err != nil {
+			return nil, false, err
+		}
+		if equal {
+			return nil, false, nil
+		}
+	}
+
+	if m.convert != nil {
+		value = m.convert(value)
+	}
+	parent[last] = value
+
+	out, err := m.marshal(doc)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return out, true, nil
+}
+
+func (m *mapEditor) Delete(data []byte, key []string) ([]byte, bool, error) {
+	doc, err := m.load(data)
+	if err != nil {
+		return nil, false, err
+	}
+
+	parent := doc
+	for _, k := range key[:len(key)-1] {
+		child, ok := parent[k].(map[string]any)
+		if !ok {
+			return nil, false, nil
+		}
+		parent = child
+	}
+
+	last := key[len(key)-1]
+	if _, ok := parent[last]; !ok {
+		return nil, false, nil
+	}
+
+	delete(parent, last)
+
+	out, err := m.marshal(doc)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return out, true, nil
+}
+
+// yamlEditor works on the yaml node tree so comments and key order are preserved.
+type yamlEditor struct{}
+
+func (y *yamlEditor) load(data []byte) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	if doc.Kind == 0 {
+		doc = yaml.Node{
+			Kind:    yaml.DocumentNode,
+			Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}},
+		}
+	}
+
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) != 1 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("top level of document is not a map")
+	}
+
+	return &doc, nil
+}
+
+func (y *yamlEditor) save(doc *yaml.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// returns the index of the key node within a mapping node's content or -1
+func yamlMappingIndex(node *yaml.Node, key string) int {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (y *yamlEditor) Set(data []byte, key []string, value any) ([]byte, bool, error) {
+	doc, err := y.load(data)
+	if err != nil {
+		return nil, false, err
+	}
+
+	parent := doc.Content[0]
+	for i, k := range key[:len(key)-1] {
+		index := yamlMappingIndex(parent, k)
+		if index == -1 {
+			child := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			parent.Content = append(parent.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: k}, child)
+			parent = child
+			continue
+		}
+
+		child := parent.Content[index+1]
+		if child.Kind != yaml.MappingNode {
+			return nil, false, fmt.Errorf("%s is not a map", strings.Join(key[:i+1], "."))
+		}
+		parent = child
+	}
+
+	var node yaml.Node
+	if err := node.Encode(value); err != nil {
+		return nil, false, err
+	}
+
+	last := key[len(key)-1]
+	index := yamlMappingIndex(parent, last)
+	if index == -1 {
+		parent.Content = append(parent.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: last}, &node)
+	} else {
+		current := parent.Content[index+1]
+
+		var currentValue any
+		if err := current.Decode(&currentValue); err != nil {
+			return nil, false, err
+		}
+
+		equal, err := valuesEqual(currentValue, value)
+		if err != nil {
+			return nil, false, err
+		}
+		if equal {
+			return nil, false, nil
+		}
+
+		node.HeadComment = current.HeadComment
+		node.LineComment = current.LineComment
+		node.FootComment = current.FootComment
+		parent.Content[index+1] = &node
+	}
+
+	out, err := y.save(doc)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return out, true, nil
+}
+
+func (y *yamlEditor) Delete(data []byte, key []string) ([]byte, bool, error) {
+	doc, err := y.load(data)
+	if err != nil {
+		return nil, false, err
+	}
+
+	parent := doc.Content[0]
+	for _, k := range key[:len(key)-1] {
+		index := yamlMappingIndex(parent, k)
+		if index == -1 || parent.Content[index+1].Kind != yaml.MappingNode {
+			return nil, false, nil
+		}
+		parent = parent.Content[index+1]
+	}
+
+	index := yamlMappingIndex(parent, key[len(key)-1])
+	if index == -1 {
+		return nil, false, nil
+	}
+
+	parent.Content = slices.Delete(parent.Content, index, index+2)
+
+	out, err := y.save(doc)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return out, true, nil
+}
+
+// iniEditor edits ini files line by line so comments and formatting are preserved.
+// key is always [section, name].
+type iniEditor struct{}
+
+func iniValue(v any) (string, error) {
+	switch val := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return val, nil
+	case bool:
+		return strconv.FormatBool(val), nil
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64), nil
+	case int:
+		return strconv.Itoa(val), nil
+	default:
+		return "", fmt.Errorf("unsupported ini value type %T", v)
+	}
+}
+
+func iniSection(line string) (string, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "[") || !strings.HasSuffix(line, "]") {
+		return "", false
+	}
+
+	return strings.TrimSpace(line[1 : len(line)-1]), true
+}
+
+func iniKeyValue(line string) (string, string, bool) {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || strings.HasPrefix(trimmed, ";") || strings.HasPrefix(trimmed, "#") {
+		return "", "", false
+	}
+
+	k, v, ok := strings.Cut(trimmed, "=")
+	if !ok {
+		return "", "", false
+	}
+
+	v = strings.TrimSpace(v)
+	if len(v) >= 2 && v[0] == '"' && v[len(v)-1] == '"' {
+		v = v[1 : len(v)-1]
+	}
+
+	return strings.TrimSpace(k), v, true
+}
+
+// returns the index of the section header, or -1 for the global section,
+// and the index of the last line of the section. start is -2 if the section does not exist.
+func iniFindSection(lines []string, section string) (int, int) {
+	start := -2
+	if section == "" {
+		start = -1
+	}
+
+	for i, line := range lines {
+		name, ok := iniSection(line)
+		if !ok {
+			continue
+		}
+
+		if start != -2 {
+			return start, i - 1
+		}
+
+		if name == section {
+			start = i
+		}
+	}
+
+	return start, len(lines) - 1
+}
+
+func iniFindKey(lines []string, start int, end int, key string) int {
+	for i := start + 1; i <= end; i++ {
+		if k, _, ok := iniKeyValue(lines[i]); ok && k == key {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (e *iniEditor) Set(data []byte, key []string, value any) ([]byte, bool, error) {
+	section, name := key[0], key[1]
+
+	val, err := iniValue(value)
+	if err != nil {
+		return nil, false, err
+	}
+
+	lines, trailingNewline := splitLines(string(data))
+	newLine := name + " = " + val
+
+	start, end := iniFindSection(lines, section)
+	switch {
+	case start == -2:
+		if len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) != "" {
+			lines = append(lines, "")
+		}
+		lines = append(lines, "["+section+"]", newLine)
+		trailingNewline = true
+	default:
+		if i := iniFindKey(lines, start, end, name); i != -1 {
+			if _, current, _ := iniKeyValue(lines[i]); current == val {
+				return nil, false, nil
+			}
+			lines[i] = newLine
+			break
+		}
+
+		// insert after the last non blank line of the section
+		index := end + 1
+		for index > start+1 && strings.TrimSpace(lines[index-1]) == "" {
+			index--
+		}
+		if index == len(lines) {
+			trailingNewline = true
+		}
+		lines = slices.Insert(lines, index, newLine)
+	}
+
+	return []byte(joinLines(lines, trailingNewline)), true, nil
+}
+
+func (e *iniEditor) Delete(data []byte, key []string) ([]byte, bool, error) {
+	section, name := key[0], key[1]
+
+	lines, trailingNewline := splitLines(string(data))
+
+	start, end := iniFindSection(lines, section)
+	if start == -2 {
+		return nil, false, nil
+	}
+
+	i := iniFindKey(lines, start, end, name)
+	if i == -1 {
+		return nil, false, nil
+	}
+
+	lines = slices.Delete(lines, i, i+1)
+
+	return []byte(joinLines(lines, trailingNewline)), true, nil
+}
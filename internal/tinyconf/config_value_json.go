@@ -0,0 +1,275 @@
+package tinyconf
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// jsonEditor keeps the key order of existing objects and the exact text of
+// existing numbers, so only the edited key differs in the written file.
+type jsonEditor struct{}
+
+// an object that remembers the order of its keys
+type jsonObject struct {
+	keys   []string
+	values map[string]any
+}
+
+func newJSONObject() *jsonObject {
+	return &jsonObject{values: map[string]any{}}
+}
+
+func (o *jsonObject) get(key string) (any, bool) {
+	v, ok := o.values[key]
+	return v, ok
+}
+
+// new keys are added at the end
+func (o *jsonObject) set(key string, value any) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+func (o *jsonObject) delete(key string) {
+	delete(o.values, key)
+	o.keys = slices.DeleteFunc(o.keys, func(k string) bool { return k == key })
+}
+
+// used when comparing values
+func (o *jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, o); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (j *jsonEditor) load(data []byte) (*jsonObject, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return newJSONObject(), nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	// numbers are kept as written, so large integers are not rounded through float64
+	dec.UseNumber()
+
+	v, err := decodeJSON(dec)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("unexpected data after top level value")
+	}
+
+	doc, ok := v.(*jsonObject)
+	if !ok {
+		return nil, fmt.Errorf("top level of document is not an object")
+	}
+
+	return doc, nil
+}
+
+func decodeJSON(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		obj := newJSONObject()
+		for dec.More() {
+			keyToken, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+
+			key, ok := keyToken.(string)
+			if !ok {
+				return nil, fmt.Errorf("unexpected object key %v", keyToken)
+			}
+
+			value, err := decodeJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj.set(key, value)
+		}
+
+		// closing brace
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		return obj, nil
+	case json.Delim('['):
+		list := []any{}
+		for dec.More() {
+			value, err := decodeJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+
+		// closing bracket
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		return list, nil
+	default:
+		return tok, nil
+	}
+}
+
+// writes compact json. values from the config are plain maps and are written with sorted keys.
+func writeJSON(buf *bytes.Buffer, v any) error {
+	switch val := v.(type) {
+	case *jsonObject:
+		buf.WriteByte('{')
+		for i, k := range val.keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJSON(buf, k); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+			if err := writeJSON(buf, val.values[k]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case map[string]any:
+		obj := newJSONObject()
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			obj.set(k, val[k])
+		}
+		return writeJSON(buf, obj)
+	case []any:
+		buf.WriteByte('[')
+		for i, item := range val {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJSON(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	default:
+		enc := json.NewEncoder(buf)
+		// config files are not html
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		// Encode adds a newline
+		buf.Truncate(buf.Len() - 1)
+	}
+
+	return nil
+}
+
+func (j *jsonEditor) save(doc *jsonObject) ([]byte, error) {
+	var compact bytes.Buffer
+	if err := writeJSON(&compact, doc); err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, compact.Bytes(), "", "  "); err != nil {
+		return nil, err
+	}
+	out.WriteByte('\n')
+
+	return out.Bytes(), nil
+}
+
+func (j *jsonEditor) Set(data []byte, key []string, value any) ([]byte, bool, error) {
+	doc, err := j.load(data)
+	if err != nil {
+		return nil, false, err
+	}
+
+	parent := doc
+	for i, k := range key[:len(key)-1] {
+		next, ok := parent.get(k)
+		if !ok {
+			child := newJSONObject()
+			parent.set(k, child)
+			parent = child
+			continue
+		}
+
+		child, ok := next.(*jsonObject)
+		if !ok {
+			return nil, false, fmt.Errorf("%s is not a map", strings.Join(key[:i+1], "."))
+		}
+		parent = child
+	}
+
+	last := key[len(key)-1]
+	if current, ok := parent.get(last); ok {
+		equal, err := valuesEqual(current, value)
+		if err != nil {
+			return nil, false, err
+		}
+		if equal {
+			return nil, false, nil
+		}
+	}
+
+	parent.set(last, value)
+
+	out, err := j.save(doc)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return out, true, nil
+}
+
+func (j *jsonEditor) Delete(data []byte, key []string) ([]byte, bool, error) {
+	doc, err := j.load(data)
+	if err != nil {
+		return nil, false, err
+	}
+
+	parent := doc
+	for _, k := range key[:len(key)-1] {
+		next, _ := parent.get(k)
+		child, ok := next.(*jsonObject)
+		if !ok {
+			return nil, false, nil
+		}
+		parent = child
+	}
+
+	last := key[len(key)-1]
+	if _, ok := parent.get(last); !ok {
+		return nil, false, nil
+	}
+
+	parent.delete(last)
+
+	out, err := j.save(doc)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return out, true, nil
+}
@@ -0,0 +1,349 @@
+package tinyconf
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigValueResource_Run_INIUpdateExistingKey(t *testing.T) {
+	filePath := writeTestFile(t, "[PHP]\n; how much memory\nmemory_limit = 128M\n\n[Session]\nsession.save_path = /tmp\n", 0o644)
+	section := "PHP"
+
+	c := &configValueResource{
+		Path:    filePath,
+		Format:  "ini",
+		Section: &section,
+		Key:     "memory_limit",
+		Value:   "512M",
+		Notify: notifyResource{
+			Service: "test-service",
+		},
+	}
+
+	ctx := t.Context()
+
+	service, err := c.Run(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "test-service", service)
+
+	data, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	require.Equal(t, "[PHP]\n; how much memory\nmemory_limit = 512M\n\n[Session]\nsession.save_path = /tmp\n", string(data))
+
+	// idempotent
+	service, err = c.Run(ctx)
+	require.NoError(t, err)
+	require.Empty(t, service)
+}
+
+func TestConfigValueResource_Run_INIAddKeyToSection(t *testing.T) {
+	filePath := writeTestFile(t, "[PHP]\nmemory_limit = 128M\n\n[Session]\nsession.save_path = /tmp\n", 0o644)
+	section := "PHP"
+
+	c := &configValueResource{
+		Path:    filePath,
+		Format:  "ini",
+		Section: &section,
+		Key:     "max_execution_time",
+		Value:   float64(60),
+	}
+
+	_, err := c.Run(t.Context())
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	require.Equal(t, "[PHP]\nmemory_limit = 128M\nmax_execution_time = 60\n\n[Session]\nsession.save_path = /tmp\n", string(data))
+}
+
+func TestConfigValueResource_Run_INIAddSection(t *testing.T) {
+	filePath := writeTestFile(t, "global = 1\n", 0o644)
+	section := "new"
+
+	c := &configValueResource{
+		Path:    filePath,
+		Format:  "ini",
+		Section: &section,
+		Key:     "enabled",
+		Value:   true,
+	}
+
+	_, err := c.Run(t.Context())
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	require.Equal(t, "global = 1\n\n[new]\nenabled = true\n", string(data))
+}
+
+func TestConfigValueResource_Run_INIQuotedValueUnchanged(t *testing.T) {
+	filePath := writeTestFile(t, "[main]\nname = \"hello\"\n", 0o644)
+	section := "main"
+
+	c := &configValueResource{
+		Path:    filePath,
+		Format:  "ini",
+		Section: &section,
+		Key:     "name",
+		Value:   "hello",
+	}
+
+	service, err := c.Run(t.Context())
+	require.NoError(t, err)
+	require.Empty(t, service)
+}
+
+func TestConfigValueResource_Run_INIDeleteKey(t *testing.T) {
+	filePath := writeTestFile(t, "a = 1\n[main]\na = 2\nb = 3\n", 0o644)
+	section := "main"
+	absent := "absent"
+
+	c := &configValueResource{
+		Path:    filePath,
+		Format:  "ini",
+		Section: &section,
+		Key:     "a",
+		State:   &absent,
+	}
+
+	ctx := t.Context()
+
+	_, err := c.Run(ctx)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	require.Equal(t, "a = 1\n[main]\nb = 3\n", string(data))
+
+	service, err := c.Run(ctx)
+	require.NoError(t, err)
+	require.Empty(t, service)
+}
+
+func TestConfigValueResource_Run_JSONSetNestedKey(t *testing.T) {
+	filePath := writeTestFile(t, `{"editor": {"fontSize": 12}, "theme": "dark"}`, 0o644)
+
+	c := &configValueResource{
+		Path:   filePath,
+		Format: "json",
+		Key:    "editor.tabSize",
+		Value:  float64(2),
+		Notify: notifyResource{
+			Service: "test-service",
+		},
+	}
+
+	ctx := t.Context()
+
+	service, err := c.Run(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "test-service", service)
+
+	data, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+
+	var doc map[string]any
+	require.NoError(t, json.Unmarshal(data, &doc))
+	require.Equal(t, map[string]any{
+		"editor": map[string]any{"fontSize": float64(12), "tabSize": float64(2)},
+		"theme":  "dark",
+	}, doc)
+
+	service, err = c.Run(ctx)
+	require.NoError(t, err)
+	require.Empty(t, service)
+}
+
+func TestConfigValueResource_Run_JSONSemanticallyEqualNotWritten(t *testing.T) {
+	// formatting differs from what we would write
+	contents := `{"a":1,   "b":   [1,2]}`
+	filePath := writeTestFile(t, contents, 0o644)
+
+	c := &configValueResource{
+		Path:   filePath,
+		Format: "json",
+		Key:    "b",
+		Value:  []any{1, 2},
+	}
+
+	service, err := c.Run(t.Context())
+	require.NoError(t, err)
+	require.Empty(t, service)
+
+	data, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	require.Equal(t, contents, string(data))
+}
+
+func TestConfigValueResource_Run_JSONPreservesOrderAndNumbers(t *testing.T) {
+	filePath := writeTestFile(t, `{"zeta": 9007199254740993, "alpha": {"url": "http://a/?x=1&y=<2>", "ratio": 1.50}}`, 0o644)
+
+	c := &configValueResource{
+		Path:   filePath,
+		Format: "json",
+		Key:    "alpha.enabled",
+		Value:  true,
+	}
+
+	_, err := c.Run(t.Context())
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	require.Equal(t, `{
+  "zeta": 9007199254740993,
+  "alpha": {
+    "url": "http://a/?x=1&y=<2>",
+    "ratio": 1.50,
+    "enabled": true
+  }
+}
+`, string(data))
+}
+
+func TestConfigValueResource_Run_JSONDelete(t *testing.T) {
+	filePath := writeTestFile(t, `{"b": 1, "a": {"c": 2, "d": 3}}`, 0o644)
+
+	c := &configValueResource{
+		Path:   filePath,
+		Format: "json",
+		Key:    "a.c",
+		State:  ptr("absent"),
+	}
+
+	ctx := t.Context()
+
+	_, err := c.Run(ctx)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	require.Equal(t, "{\n  \"b\": 1,\n  \"a\": {\n    \"d\": 3\n  }\n}\n", string(data))
+
+	service, err := c.Run(ctx)
+	require.NoError(t, err)
+	require.Empty(t, service)
+}
+
+func TestConfigValueResource_Run_RequiresValue(t *testing.T) {
+	filePath := writeTestFile(t, `{}`, 0o644)
+
+	c := &configValueResource{
+		Path:   filePath,
+		Format: "json",
+		Key:    "a",
+	}
+
+	_, err := c.Run(t.Context())
+	require.Error(t, err)
+}
+
+func TestConfigValueResource_Run_JSONErrorNotAMap(t *testing.T) {
+	filePath := writeTestFile(t, `{"a": 1}`, 0o644)
+
+	c := &configValueResource{
+		Path:   filePath,
+		Format: "json",
+		Key:    "a.b",
+		Value:  "x",
+	}
+
+	_, err := c.Run(t.Context())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "is not a map")
+}
+
+func TestConfigValueResource_Run_YAMLPreservesComments(t *testing.T) {
+	filePath := writeTestFile(t, "# top comment\nserver:\n  # the port\n  port: 80\n  host: localhost # inline\n", 0o644)
+
+	c := &configValueResource{
+		Path:   filePath,
+		Format: "yaml",
+		Key:    "server.port",
+		Value:  float64(8080),
+	}
+
+	ctx := t.Context()
+
+	_, err := c.Run(ctx)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	require.Equal(t, "# top comment\nserver:\n  # the port\n  port: 8080\n  host: localhost # inline\n", string(data))
+
+	service, err := c.Run(ctx)
+	require.NoError(t, err)
+	require.Empty(t, service)
+}
+
+func TestConfigValueResource_Run_YAMLDeleteKey(t *testing.T) {
+	filePath := writeTestFile(t, "a: 1\nb:\n  c: 2\n  d: 3\n", 0o644)
+	absent := "absent"
+
+	c := &configValueResource{
+		Path:   filePath,
+		Format: "yaml",
+		Key:    "b.c",
+		State:  &absent,
+	}
+
+	_, err := c.Run(t.Context())
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	require.Equal(t, "a: 1\nb:\n  d: 3\n", string(data))
+}
+
+func TestConfigValueResource_Run_TOMLSetKey(t *testing.T) {
+	filePath := writeTestFile(t, "[server]\nport = 80\n", 0o644)
+
+	c := &configValueResource{
+		Path:   filePath,
+		Format: "toml",
+		Key:    "server.port",
+		Value:  float64(8080),
+	}
+
+	ctx := t.Context()
+
+	service, err := c.Run(ctx)
+	require.NoError(t, err)
+	require.Empty(t, service)
+
+	data, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+
+	var doc map[string]any
+	require.NoError(t, toml.Unmarshal(data, &doc))
+	require.Equal(t, map[string]any{"server": map[string]any{"port": int64(8080)}}, doc)
+
+	// integer in file and float from config should compare equal
+	c.Notify.Service = "test-service"
+	service, err = c.Run(ctx)
+	require.NoError(t, err)
+	require.Empty(t, service)
+}
+
+func TestConfigValueResource_Run_EmptyFile(t *testing.T) {
+	filePath := writeTestFile(t, "", 0o644)
+
+	c := &configValueResource{
+		Path:   filePath,
+		Format: "yaml",
+		Key:    "a.b",
+		Value:  "c",
+	}
+
+	_, err := c.Run(t.Context())
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	require.Equal(t, "a:\n  b: c\n", string(data))
+}
@@ -0,0 +1,132 @@
+package tinyconf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const defaultCronDir = "/etc/cron.d"
+
+// cronResource manages a job in its own file in /etc/cron.d.
+type cronResource struct {
+	// file name. cron ignores files with dots in the name
+	Name string `json:"name" validate:"required,excludesall=./"`
+	// five fields, such as */5 * * * *, or a shortcut such as @daily
+	Schedule string `json:"schedule" validate:"required_unless=State absent,omitempty,cron_schedule"`
+	Command  string `json:"command" validate:"required_unless=State absent"`
+	// defaults to root
+	User *string `json:"user"`
+	// variables such as PATH and MAILTO, set before the job
+	Env    map[string]string `json:"env"`
+	State  *string           `json:"state" validate:"omitempty,oneof=present absent"`
+	Notify notifyResource    `json:"notify"`
+	// for testing
+	cronDir string
+}
+
+var cronShortcuts = []string{"@reboot", "@yearly", "@annually", "@monthly", "@weekly", "@daily", "@midnight", "@hourly"}
+
+// used to validate schedules. the fields themselves are left to cron
+func validCronSchedule(schedule string) bool {
+	if strings.HasPrefix(schedule, "@") {
+		return slices.Contains(cronShortcuts, schedule)
+	}
+
+	return len(strings.Fields(schedule)) == 5
+}
+
+func (c *cronResource) path() string {
+	dir := c.cronDir
+	if dir == "" {
+		dir = defaultCronDir
+	}
+
+	return filepath.Join(dir, c.Name)
+}
+
+func (c *cronResource) Run(ctx context.Context) (string, error) {
+	shouldExist := c.State == nil || *c.State == "present"
+
+	tasks := []func() (bool, error){
+		func() (bool, error) {
+			path := c.path()
+
+			if !shouldExist {
+				return removeFile(path)
+			}
+
+			contents, err := c.contents()
+			if err != nil {
+				return false, err
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return false, fmt.Errorf("failed to read %s %w", path, err)
+			}
+
+			if err == nil && string(data) == contents {
+				return false, nil
+			}
+
+			slog.Info("writing cron job", "name", c.Name, "path", path)
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				return false, fmt.Errorf("failed to create directory for %s %w", path, err)
+			}
+
+			// cron ignores files that are writable by others
+			if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+				return false, fmt.Errorf("failed to write %s %w", path, err)
+			}
+
+			return true, os.Chmod(path, 0o644)
+		},
+	}
+
+	// use runTasks in case we add some debugging/logging/etc
+	changed, err := runTasks(tasks)
+	if err != nil {
+		return "", err
+	}
+
+	if changed {
+		return c.Notify.Service, nil
+	}
+
+	return "", nil
+}
+
+// returns the contents of the cron.d file
+func (c *cronResource) contents() (string, error) {
+	if strings.ContainsAny(c.Command, "\r\n") {
+		return "", fmt.Errorf("cron job %s command must be a single line", c.Name)
+	}
+
+	user := "root"
+	if c.User != nil {
+		user = *c.User
+	}
+
+	var b strings.Builder
+	b.WriteString("# managed by tinyconf\n")
+
+	for _, key := range slices.Sorted(maps.Keys(c.Env)) {
+		if strings.ContainsAny(c.Env[key], "\r\n") {
+			return "", fmt.Errorf("cron job %s env %s must be a single line", c.Name, key)
+		}
+		fmt.Fprintf(&b, "%s=%s\n", key, c.Env[key])
+	}
+
+	// cron turns an unescaped % into a newline, with the rest of the line passed on stdin
+	command := strings.ReplaceAll(c.Command, "%", `\%`)
+	fmt.Fprintf(&b, "%s %s %s\n", c.Schedule, user, command)
+
+	return b.String(), nil
+}
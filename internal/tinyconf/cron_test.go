@@ -0,0 +1,120 @@
+package tinyconf
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCronResource_Run(t *testing.T) {
+	cron := &cronResource{
+		Name:     "backup",
+		Schedule: "30 2 * * *",
+		Command:  "/usr/local/bin/backup --quiet",
+		User:     ptr("backup"),
+		Env:      map[string]string{"PATH": "/usr/local/bin:/usr/bin:/bin", "MAILTO": ""},
+		Notify:   notifyResource{Service: "cron"},
+		cronDir:  t.TempDir(),
+	}
+
+	service, err := cron.Run(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, "cron", service)
+
+	path := filepath.Join(cron.cronDir, "backup")
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "# managed by tinyconf\nMAILTO=\nPATH=/usr/local/bin:/usr/bin:/bin\n30 2 * * * backup /usr/local/bin/backup --quiet\n", string(data))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o644), info.Mode().Perm())
+
+	service, err = cron.Run(t.Context())
+	require.NoError(t, err)
+	require.Empty(t, service)
+
+	cron.Schedule = "@daily"
+	service, err = cron.Run(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, "cron", service)
+}
+
+func TestCronResource_Run_DefaultUser(t *testing.T) {
+	cron := &cronResource{
+		Name:     "cleanup",
+		Schedule: "@hourly",
+		Command:  "find /tmp -mtime +7 -delete",
+		cronDir:  t.TempDir(),
+	}
+
+	_, err := cron.Run(t.Context())
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(cron.cronDir, "cleanup"))
+	require.NoError(t, err)
+	require.Equal(t, "# managed by tinyconf\n@hourly root find /tmp -mtime +7 -delete\n", string(data))
+}
+
+func TestCronResource_Run_Absent(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "backup")
+	require.NoError(t, os.WriteFile(path, []byte("@daily root true\n"), 0o644))
+
+	cron := &cronResource{Name: "backup", State: ptr("absent"), cronDir: dir}
+
+	_, err := cron.Run(t.Context())
+	require.NoError(t, err)
+
+	_, err = os.Stat(path)
+	require.True(t, os.IsNotExist(err))
+
+	service, err := cron.Run(t.Context())
+	require.NoError(t, err)
+	require.Empty(t, service)
+}
+
+func TestCronResource_Run_EscapesPercent(t *testing.T) {
+	cron := &cronResource{
+		Name:     "backup",
+		Schedule: "@daily",
+		Command:  "tar czf /var/backups/etc-$(date +%F).tgz /etc",
+		cronDir:  t.TempDir(),
+	}
+
+	_, err := cron.Run(t.Context())
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(cron.cronDir, "backup"))
+	require.NoError(t, err)
+	require.Equal(t, "# managed by tinyconf\n@daily root tar czf /var/backups/etc-$(date +\\%F).tgz /etc\n", string(data))
+}
+
+func TestCronResource_Run_MultilineCommand(t *testing.T) {
+	cron := &cronResource{
+		Name:     "backup",
+		Schedule: "@daily",
+		Command:  "true\n* * * * * root evil",
+		cronDir:  t.TempDir(),
+	}
+
+	_, err := cron.Run(t.Context())
+	require.Error(t, err)
+}
+
+func TestValidCronSchedule(t *testing.T) {
+	for schedule, expected := range map[string]bool{
+		"*/5 * * * *":  true,
+		"0 2 * * 1-5":  true,
+		"@daily":       true,
+		"@reboot":      true,
+		"@fortnightly": false,
+		"* * * *":      false,
+		"* * * * * *":  false,
+		"":             false,
+	} {
+		require.Equal(t, expected, validCronSchedule(schedule), schedule)
+	}
+}
@@ -0,0 +1,160 @@
+package tinyconf
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strings"
+)
+
+// debconfSelection is an answer to a debconf question, set before the package is installed.
+type debconfSelection struct {
+	// such as tzdata/Areas
+	Question string `json:"question" validate:"required"`
+	Type     string `json:"type" validate:"required,oneof=string boolean select multiselect note text password title error"`
+	// booleans and numbers are converted to strings
+	Value any `json:"value"`
+}
+
+func (d debconfSelection) value() string {
+	if d.Value == nil {
+		return ""
+	}
+
+	return fmt.Sprint(d.Value)
+}
+
+// package managers that support preseeding answers to install time questions
+type debconfPreseeder interface {
+	// sets the selections that differ from the current answers.
+	// returns whether any comparable selection changed
+	Preseed(context.Context, string, []debconfSelection) (bool, error)
+}
+
+// returns the answers from debconf-get-selections output. each line is the owner, question,
+// type, and value separated by tabs, such as "tzdata\ttzdata/Areas\tselect\tEtc".
+// questions are not always owned by the package being installed, such as
+// mysql-server/root_password being owned by mysql-server-8.0, so answers are keyed by question only.
+func parseDebconfSelections(output string) map[string]string {
+	answers := make(map[string]string)
+
+	for line := range strings.Lines(output) {
+		line = strings.TrimRight(line, "\n")
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.SplitN(line, "\t", 4)
+		if len(fields) < 3 || fields[1] == "" {
+			continue
+		}
+
+		value := ""
+		if len(fields) == 4 {
+			value = fields[3]
+		}
+		answers[fields[1]] = value
+	}
+
+	return answers
+}
+
+// returns the answers from debconf-show output. each line is a question and
+// its value, such as "* tzdata/Areas: Etc", where * marks questions that have been seen
+func parseDebconfShow(output string) map[string]string {
+	answers := make(map[string]string)
+
+	for line := range strings.Lines(output) {
+		line = strings.TrimLeft(line, "* \t")
+		question, value, ok := strings.Cut(line, ":")
+		if !ok || question == "" {
+			continue
+		}
+
+		answers[question] = strings.TrimSpace(value)
+	}
+
+	return answers
+}
+
+// returns the current answers. debconf-get-selections is part of debconf-utils, which
+// is not always installed, in which case debconf-show is used. it only shows
+// questions owned by the package itself.
+func currentDebconfSelections(ctx context.Context, packageName string) (map[string]string, error) {
+	if _, err := exec.LookPath("debconf-get-selections"); err != nil {
+		output, err := exec.CommandContext(ctx, "debconf-show", packageName).CombinedOutput()
+		if err != nil {
+			return nil, fmt.Errorf("failed to show debconf selections for %s (output: %s): %w", packageName, string(output), err)
+		}
+
+		return parseDebconfShow(string(output)), nil
+	}
+
+	output, err := exec.CommandContext(ctx, "debconf-get-selections").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get debconf selections for %s: %w", packageName, err)
+	}
+
+	return parseDebconfSelections(string(output)), nil
+}
+
+// returns the selections that need to be set, and whether any of them
+// are a change. packages often clear passwords after using them, so they are
+// always set and are not considered a change.
+func debconfChanges(current map[string]string, selections []debconfSelection) ([]debconfSelection, bool) {
+	var (
+		out     []debconfSelection
+		changed bool
+	)
+
+	for _, s := range selections {
+		if s.Type == "password" {
+			out = append(out, s)
+			continue
+		}
+
+		if value, ok := current[s.Question]; ok && value == s.value() {
+			continue
+		}
+
+		out = append(out, s)
+		changed = true
+	}
+
+	return out, changed
+}
+
+// returns input for debconf-set-selections
+func formatDebconfSelections(packageName string, selections []debconfSelection) string {
+	var b strings.Builder
+	for _, s := range selections {
+		fmt.Fprintf(&b, "%s %s %s %s\n", packageName, s.Question, s.Type, s.value())
+	}
+
+	return b.String()
+}
+
+func (a *aptPackageManager) Preseed(ctx context.Context, packageName string, selections []debconfSelection) (bool, error) {
+	current, err := currentDebconfSelections(ctx, packageName)
+	if err != nil {
+		return false, err
+	}
+
+	changes, changed := debconfChanges(current, selections)
+	if len(changes) == 0 {
+		return false, nil
+	}
+
+	if changed {
+		slog.Info("setting debconf selections", "name", packageName)
+	}
+
+	cmd := exec.CommandContext(ctx, "debconf-set-selections")
+	cmd.Stdin = strings.NewReader(formatDebconfSelections(packageName, changes))
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return false, fmt.Errorf("failed to set debconf selections for %s (output: %s): %w", packageName, string(output), err)
+	}
+
+	return changed, nil
+}
@@ -0,0 +1,66 @@
+package tinyconf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDebconfSelections(t *testing.T) {
+	output := "# Geographic area:\ntzdata\ttzdata/Areas\tselect\tEtc\ntzdata\ttzdata/Zones/Etc\tselect\tUTC\n" +
+		"tzdata\ttzdata/Zones/Africa\tselect\t\nmysql-server-8.0\tmysql-server/root_password\tpassword\t\n" +
+		"postfix\tpostfix/main_mailer_type\tselect\tInternet Site\n"
+
+	require.Equal(t, map[string]string{
+		"tzdata/Areas":               "Etc",
+		"tzdata/Zones/Etc":           "UTC",
+		"tzdata/Zones/Africa":        "",
+		"mysql-server/root_password": "",
+		"postfix/main_mailer_type":   "Internet Site",
+	}, parseDebconfSelections(output))
+}
+
+func TestParseDebconfShow(t *testing.T) {
+	output := "* tzdata/Areas: Etc\n  tzdata/Zones/Etc: UTC\n  tzdata/Zones/Africa:\n* mysql-server/root_password: (password omitted)\n"
+
+	require.Equal(t, map[string]string{
+		"tzdata/Areas":               "Etc",
+		"tzdata/Zones/Etc":           "UTC",
+		"tzdata/Zones/Africa":        "",
+		"mysql-server/root_password": "(password omitted)",
+	}, parseDebconfShow(output))
+}
+
+func TestDebconfChanges(t *testing.T) {
+	current := map[string]string{
+		"tzdata/Areas":               "Etc",
+		"unattended-upgrades/enable": "true",
+		"mysql-server/root_password": "",
+		"postfix/main_mailer_type":   "Local only",
+	}
+
+	selections := []debconfSelection{
+		{Question: "tzdata/Areas", Type: "select", Value: "Etc"},
+		{Question: "unattended-upgrades/enable", Type: "boolean", Value: true},
+		{Question: "mysql-server/root_password", Type: "password", Value: "secret"},
+		{Question: "postfix/main_mailer_type", Type: "select", Value: "Internet Site"},
+	}
+
+	changes, changed := debconfChanges(current, selections)
+	require.True(t, changed)
+	require.Equal(t, []debconfSelection{selections[2], selections[3]}, changes)
+
+	// passwords are always set, but are not a change
+	changes, changed = debconfChanges(current, selections[:3])
+	require.False(t, changed)
+	require.Equal(t, []debconfSelection{selections[2]}, changes)
+}
+
+func TestFormatDebconfSelections(t *testing.T) {
+	output := formatDebconfSelections("postfix", []debconfSelection{
+		{Question: "postfix/main_mailer_type", Type: "select", Value: "Internet Site"},
+		{Question: "postfix/relayhost", Type: "string"},
+	})
+
+	require.Equal(t, "postfix postfix/main_mailer_type select Internet Site\npostfix postfix/relayhost string \n", output)
+}
@@ -0,0 +1,61 @@
+package tinyconf
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnifiedDiff_Same(t *testing.T) {
+	require.Empty(t, unifiedDiff("a.conf", "a\nb\n", "a\nb\n"))
+}
+
+func TestUnifiedDiff_Replace(t *testing.T) {
+	old := "1\n2\n3\n4\n5\n6\n7\n8\n"
+	updated := "1\n2\n3\n4\nfive\n6\n7\n8\n"
+
+	require.Equal(t, `--- a.conf
++++ a.conf
+@@ -2,7 +2,7 @@
+ 2
+ 3
+ 4
+-5
++five
+ 6
+ 7
+ 8
+`, unifiedDiff("a.conf", old, updated))
+}
+
+func TestUnifiedDiff_Hunks(t *testing.T) {
+	var lines []string
+	for i := range 20 {
+		lines = append(lines, strings.Repeat("x", i+1))
+	}
+	old := strings.Join(lines, "\n") + "\n"
+
+	lines[0] = "first"
+	lines = append(lines, "last")
+	updated := strings.Join(lines, "\n") + "\n"
+
+	require.Equal(t, `--- a.conf
++++ a.conf
+@@ -1,4 +1,4 @@
+-x
++first
+ xx
+ xxx
+ xxxx
+@@ -18,3 +18,4 @@
+ xxxxxxxxxxxxxxxxxx
+ xxxxxxxxxxxxxxxxxxx
+ xxxxxxxxxxxxxxxxxxxx
++last
+`, unifiedDiff("a.conf", old, updated))
+}
+
+func TestUnifiedDiff_EmptyFile(t *testing.T) {
+	require.Equal(t, "--- a.conf\n+++ a.conf\n@@ -0,0 +1 @@\n+a=1\n", unifiedDiff("a.conf", "", "a=1\n"))
+}
@@ -4,24 +4,68 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io/fs"
 	"log/slog"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"syscall"
 )
 
 type directoryResource struct {
-	Path      string         `json:"path" validate:"required"`
-	Owner     *string        `json:"owner"`
-	Group     *string        `json:"group"`
-	Mode      *os.FileMode   `json:"mode"`
-	Recursive bool           `json:"recursive"`
-	Notify    notifyResource `json:"notify"`
+	Path      string       `json:"path" validate:"required"`
+	Owner     *string      `json:"owner"`
+	Group     *string      `json:"group"`
+	Mode      *os.FileMode `json:"mode"`
+	Recursive bool         `json:"recursive"`
+	State     *string      `json:"state" validate:"omitempty,oneof=present absent"`
+	// required to remove a directory that is not empty
+	Force bool `json:"force"`
+	// apply owner and group to everything in the directory, mode to
+	// subdirectories, and file_mode to files
+	RecurseAttrs bool         `json:"recurse_attrs"`
+	FileMode     *os.FileMode `json:"file_mode"`
+	// remove anything in the directory not managed by another resource
+	Purge  bool           `json:"purge"`
+	Notify notifyResource `json:"notify"`
+	// paths managed by all resources in the config. used for purge.
+	managed []string
+	// purge logs what it would remove, but does not remove anything
+	purgeDryRun bool
+	// if not empty, directories can only be removed if they are in one of these
+	removeAllowed []string
 }
 
 const defaultDirMode = os.FileMode(0o755)
 
+// nothing below these can be removed, except for /usr/local
+var protectedTrees = []string{
+	"/bin", "/boot", "/dev", "/lib", "/lib32", "/lib64", "/libx32",
+	"/proc", "/run", "/sbin", "/sys", "/usr",
+}
+
+// system directories that can not be removed, though things inside of them can
+var protectedDirectories = []string{
+	"/etc/alternatives", "/etc/apt", "/etc/cron.d", "/etc/default", "/etc/init.d",
+	"/etc/pam.d", "/etc/security", "/etc/ssh", "/etc/ssl", "/etc/sudoers.d",
+	"/etc/systemd", "/etc/systemd/system",
+	"/usr/local", "/usr/local/bin", "/usr/local/etc", "/usr/local/lib", "/usr/local/sbin", "/usr/local/share",
+	"/var/backups", "/var/cache", "/var/lib", "/var/lib/apt", "/var/lib/dpkg", "/var/lib/rpm",
+	"/var/lib/systemd", "/var/log", "/var/spool",
+}
+
+// returns whether path is dir or below it
+func pathWithin(path string, dir string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
 func (d *directoryResource) Run(ctx context.Context) (string, error) {
-	userID, groupID, err := getUserAndGroup(d.Owner, d.Group)
+	if d.State != nil && *d.State == "absent" {
+		return d.remove()
+	}
+
+	userID, groupID, err := getUserAndGroup("directory "+d.Path, d.Owner, d.Group)
 	if err != nil {
 		return "", err
 	}
@@ -100,6 +144,68 @@ func (d *directoryResource) Run(ctx context.Context) (string, error) {
 		)
 	}
 
+	if d.Purge {
+		tasks = append(tasks, func() (bool, error) {
+			return d.purge(filepath.Clean(d.Path))
+		})
+	}
+
+	if d.RecurseAttrs {
+		tasks = append(tasks, func() (bool, error) {
+			return d.recurseAttrs(userID, groupID)
+		})
+	}
+
+	changed, err := runTasks(tasks)
+	if err != nil {
+		return "", err
+	}
+
+	if changed {
+		return d.Notify.Service, nil
+	}
+
+	return "", nil
+}
+
+func (d *directoryResource) remove() (string, error) {
+	path, err := d.checkRemovable(filepath.Clean(d.Path))
+	if err != nil {
+		return "", err
+	}
+
+	tasks := []func() (bool, error){
+		func() (bool, error) {
+			dirInfo, err := os.Lstat(path)
+			if err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					return false, nil
+				}
+				return false, fmt.Errorf("failed to stat %s %w", path, err)
+			}
+
+			if !dirInfo.IsDir() {
+				return false, fmt.Errorf("%s is not a directory", path)
+			}
+
+			entries, err := os.ReadDir(path)
+			if err != nil {
+				return false, fmt.Errorf("failed to read directory %s %w", path, err)
+			}
+
+			if len(entries) > 0 && !d.Force {
+				return false, fmt.Errorf("%s is not empty and force is not set", path)
+			}
+
+			slog.Info("removing directory", "path", path, "entries", len(entries))
+			if err := os.RemoveAll(path); err != nil {
+				return false, fmt.Errorf("failed to remove %s %w", path, err)
+			}
+
+			return true, nil
+		},
+	}
+
 	changed, err := runTasks(tasks)
 	if err != nil {
 		return "", err
@@ -111,3 +217,232 @@ func (d *directoryResource) Run(ctx context.Context) (string, error) {
 
 	return "", nil
 }
+
+// guardrails against removing something important. a symlink in a parent
+// directory could point anywhere, so the checks are also done on the real path,
+// which is returned to be removed.
+func (d *directoryResource) checkRemovable(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		return "", fmt.Errorf("refusing to remove relative path %s", path)
+	}
+
+	if err := checkProtected(path); err != nil {
+		return "", err
+	}
+
+	resolved, err := resolveParent(path)
+	if err != nil {
+		return "", err
+	}
+
+	if resolved != path {
+		if err := checkProtected(resolved); err != nil {
+			return "", fmt.Errorf("refusing to remove %s as it resolves to %s: %w", path, resolved, err)
+		}
+	}
+
+	if len(d.removeAllowed) == 0 {
+		return resolved, nil
+	}
+
+	// the allowed directory itself can not be removed
+	for _, allowed := range d.removeAllowed {
+		// compared using its real path, in case it is or is under a link
+		allowed = filepath.Clean(allowed)
+		if resolvedAllowed, err := filepath.EvalSymlinks(allowed); err == nil {
+			allowed = resolvedAllowed
+		}
+
+		if resolved != allowed && pathWithin(resolved, allowed) {
+			return resolved, nil
+		}
+	}
+
+	return "", fmt.Errorf("refusing to remove %s as it is not in directory_remove_allowed", path)
+}
+
+func checkProtected(path string) error {
+	// this covers / as well as /etc, /usr, /home, etc
+	if filepath.Dir(path) == "/" {
+		return fmt.Errorf("refusing to remove top level directory %s", path)
+	}
+
+	if slices.Contains(protectedDirectories, path) {
+		return fmt.Errorf("refusing to remove system directory %s", path)
+	}
+
+	for _, tree := range protectedTrees {
+		if pathWithin(path, tree) && !pathWithin(path, "/usr/local") {
+			return fmt.Errorf("refusing to remove %s as it is under system directory %s", path, tree)
+		}
+	}
+
+	return nil
+}
+
+// returns path with symlinks in its parent directories resolved. the last
+// element is kept as is. if the parent does not exist, the path is returned unchanged,
+// as there is nothing there to remove.
+func resolveParent(path string) (string, error) {
+	parent, err := filepath.EvalSymlinks(filepath.Dir(path))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return path, nil
+		}
+		return "", fmt.Errorf("failed to resolve %s %w", filepath.Dir(path), err)
+	}
+
+	return filepath.Join(parent, filepath.Base(path)), nil
+}
+
+// removes anything under dir that is not managed by a resource.
+// managed directories are left alone, as they are owned by their own resource.
+func (d *directoryResource) purge(dir string) (bool, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false, fmt.Errorf("failed to read directory %s %w", dir, err)
+	}
+
+	changed := false
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+
+		if slices.Contains(d.managed, path) {
+			continue
+		}
+
+		// something below this path is managed, so only purge inside of it
+		if entry.IsDir() && slices.ContainsFunc(d.managed, func(m string) bool {
+			return strings.HasPrefix(m, path+string(filepath.Separator))
+		}) {
+			c, err := d.purge(path)
+			if c {
+				changed = true
+			}
+			if err != nil {
+				return changed, err
+			}
+			continue
+		}
+
+		if d.purgeDryRun {
+			slog.Info("would purge unmanaged path", "path", path, "directory", d.Path)
+			changed = true
+			continue
+		}
+
+		slog.Info("purging unmanaged path", "path", path, "directory", d.Path)
+		if err := os.RemoveAll(path); err != nil {
+			return changed, fmt.Errorf("failed to remove %s %w", path, err)
+		}
+		changed = true
+	}
+
+	return changed, nil
+}
+
+// applies owner, group, and modes to everything under the directory.
+// symlinks are skipped.
+func (d *directoryResource) recurseAttrs(userID int, groupID int) (bool, error) {
+	changed := false
+
+	err := filepath.WalkDir(d.Path, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// the directory itself is handled by the other tasks
+		if path == d.Path || entry.Type()&fs.ModeSymlink != 0 {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		mode := d.FileMode
+		if entry.IsDir() {
+			mode = d.Mode
+		}
+
+		c, err := ensureAttributes(path, info, userID, groupID, mode)
+		if c {
+			changed = true
+		}
+
+		return err
+	})
+	if err != nil {
+		return changed, fmt.Errorf("failed to update attributes in %s %w", d.Path, err)
+	}
+
+	return changed, nil
+}
+
+// ensures a path has the given owner, group, and mode.
+// -1 for uid or gid and nil for mode means leave it alone.
+func ensureAttributes(path string, info fs.FileInfo, userID int, groupID int, mode *os.FileMode) (bool, error) {
+	return setAttributes(path, pathAttributes(path), info, userID, groupID, mode)
+}
+
+// like ensureAttributes, but changes the open file rather than following a path
+func ensureFileAttributes(file *os.File, userID int, groupID int, mode *os.FileMode) (bool, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return false, fmt.Errorf("failed to stat %s %w", file.Name(), err)
+	}
+
+	return setAttributes(file.Name(), file, info, userID, groupID, mode)
+}
+
+// implemented by *os.File and pathAttributes
+type attributeSetter interface {
+	Chown(uid int, gid int) error
+	Chmod(mode os.FileMode) error
+}
+
+type pathAttributes string
+
+func (p pathAttributes) Chown(uid int, gid int) error {
+	return os.Chown(string(p), uid, gid)
+}
+
+func (p pathAttributes) Chmod(mode os.FileMode) error {
+	return os.Chmod(string(p), mode)
+}
+
+func setAttributes(path string, setter attributeSetter, info fs.FileInfo, userID int, groupID int, mode *os.FileMode) (bool, error) {
+	sysStat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || sysStat == nil {
+		return false, fmt.Errorf("unexpected file info returned by stat for %s", path)
+	}
+
+	changed := false
+
+	if userID != -1 && sysStat.Uid != uint32(userID) {
+		slog.Info("changing owner", "path", path, "uid", userID)
+		if err := setter.Chown(userID, -1); err != nil {
+			return changed, err
+		}
+		changed = true
+	}
+
+	if groupID != -1 && sysStat.Gid != uint32(groupID) {
+		slog.Info("changing group", "path", path, "gid", groupID)
+		if err := setter.Chown(-1, groupID); err != nil {
+			return changed, err
+		}
+		changed = true
+	}
+
+	if mode != nil && info.Mode().Perm() != mode.Perm() {
+		slog.Info("changing mode", "path", path, "mode", *mode)
+		if err := setter.Chmod(*mode); err != nil {
+			return changed, err
+		}
+		changed = true
+	}
+
+	return changed, nil
+}
@@ -381,3 +381,343 @@ func TestDirectoryResource_Run_RecursiveWithExistingParent(t *testing.T) {
 	require.NoError(t, err)
 	require.True(t, info.IsDir())
 }
+
+func TestDirectoryResource_Run_PurgeUnmanaged(t *testing.T) {
+	dirPath := t.TempDir()
+
+	keep := filepath.Join(dirPath, "keep.conf")
+	remove := filepath.Join(dirPath, "remove.conf")
+	removeDir := filepath.Join(dirPath, "olddir")
+	nested := filepath.Join(dirPath, "nested")
+	nestedKeep := filepath.Join(nested, "keep.conf")
+	nestedRemove := filepath.Join(nested, "remove.conf")
+
+	require.NoError(t, os.MkdirAll(filepath.Join(removeDir, "child"), 0o755))
+	require.NoError(t, os.MkdirAll(nested, 0o755))
+	for _, path := range []string{keep, remove, nestedKeep, nestedRemove} {
+		require.NoError(t, os.WriteFile(path, []byte("x"), 0o644))
+	}
+
+	d := &directoryResource{
+		Path:  dirPath,
+		Purge: true,
+		Notify: notifyResource{
+			Service: "test-service",
+		},
+		managed: []string{dirPath, keep, nestedKeep},
+	}
+
+	ctx := t.Context()
+
+	service, err := d.Run(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "test-service", service)
+
+	for _, path := range []string{keep, nestedKeep} {
+		_, err := os.Stat(path)
+		require.NoError(t, err, path)
+	}
+
+	for _, path := range []string{remove, removeDir, nestedRemove} {
+		_, err := os.Stat(path)
+		require.True(t, os.IsNotExist(err), path)
+	}
+
+	service, err = d.Run(ctx)
+	require.NoError(t, err)
+	require.Empty(t, service)
+}
+
+func TestDirectoryResource_Run_PurgeDryRun(t *testing.T) {
+	dirPath := t.TempDir()
+
+	keep := filepath.Join(dirPath, "keep.conf")
+	remove := filepath.Join(dirPath, "remove.conf")
+	for _, path := range []string{keep, remove} {
+		require.NoError(t, os.WriteFile(path, []byte("x"), 0o644))
+	}
+
+	d := &directoryResource{
+		Path:        dirPath,
+		Purge:       true,
+		Notify:      notifyResource{Service: "test-service"},
+		managed:     []string{dirPath, keep},
+		purgeDryRun: true,
+	}
+
+	// reported as a change every run, as nothing is removed
+	for range 2 {
+		service, err := d.Run(t.Context())
+		require.NoError(t, err)
+		require.Equal(t, "test-service", service)
+
+		_, err = os.Stat(remove)
+		require.NoError(t, err)
+	}
+}
+
+func TestDirectoryResource_Run_PurgeLeavesManagedDirectories(t *testing.T) {
+	dirPath := t.TempDir()
+	subdir := filepath.Join(dirPath, "sub")
+	inside := filepath.Join(subdir, "unmanaged.conf")
+
+	require.NoError(t, os.MkdirAll(subdir, 0o755))
+	require.NoError(t, os.WriteFile(inside, []byte("x"), 0o644))
+
+	d := &directoryResource{
+		Path:    dirPath,
+		Purge:   true,
+		managed: []string{dirPath, subdir},
+	}
+
+	service, err := d.Run(t.Context())
+	require.NoError(t, err)
+	require.Empty(t, service)
+
+	_, err = os.Stat(inside)
+	require.NoError(t, err)
+}
+
+func TestDirectoryResource_Run_RecurseAttrsModes(t *testing.T) {
+	dirPath := t.TempDir()
+	subdir := filepath.Join(dirPath, "sub")
+	file := filepath.Join(subdir, "file.txt")
+
+	require.NoError(t, os.MkdirAll(subdir, 0o700))
+	require.NoError(t, os.WriteFile(file, []byte("x"), 0o600))
+
+	dirMode := os.FileMode(0o755)
+	fileMode := os.FileMode(0o644)
+
+	d := &directoryResource{
+		Path:         dirPath,
+		Mode:         &dirMode,
+		FileMode:     &fileMode,
+		RecurseAttrs: true,
+		Notify: notifyResource{
+			Service: "test-service",
+		},
+	}
+
+	ctx := t.Context()
+
+	service, err := d.Run(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "test-service", service)
+
+	info, err := os.Stat(subdir)
+	require.NoError(t, err)
+	require.Equal(t, dirMode, info.Mode().Perm())
+
+	info, err = os.Stat(file)
+	require.NoError(t, err)
+	require.Equal(t, fileMode, info.Mode().Perm())
+
+	service, err = d.Run(ctx)
+	require.NoError(t, err)
+	require.Empty(t, service)
+}
+
+func TestDirectoryResource_Run_RecurseAttrsSkipsSymlinks(t *testing.T) {
+	dirPath := t.TempDir()
+	target := filepath.Join(t.TempDir(), "target.txt")
+	require.NoError(t, os.WriteFile(target, []byte("x"), 0o600))
+	require.NoError(t, os.Symlink(target, filepath.Join(dirPath, "link")))
+
+	fileMode := os.FileMode(0o644)
+
+	d := &directoryResource{
+		Path:         dirPath,
+		FileMode:     &fileMode,
+		RecurseAttrs: true,
+	}
+
+	_, err := d.Run(t.Context())
+	require.NoError(t, err)
+
+	info, err := os.Stat(target)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
+
+func TestDirectoryResource_Run_RemoveEmptyDirectory(t *testing.T) {
+	dirPath := filepath.Join(t.TempDir(), "testdir")
+	require.NoError(t, os.Mkdir(dirPath, 0o755))
+
+	absent := "absent"
+	d := &directoryResource{
+		Path:  dirPath,
+		State: &absent,
+		Notify: notifyResource{
+			Service: "test-service",
+		},
+	}
+
+	ctx := t.Context()
+
+	service, err := d.Run(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "test-service", service)
+
+	_, err = os.Stat(dirPath)
+	require.True(t, os.IsNotExist(err))
+
+	service, err = d.Run(ctx)
+	require.NoError(t, err)
+	require.Empty(t, service)
+}
+
+func TestDirectoryResource_Run_RemoveNonEmptyRequiresForce(t *testing.T) {
+	dirPath := filepath.Join(t.TempDir(), "testdir")
+	require.NoError(t, os.MkdirAll(filepath.Join(dirPath, "child"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dirPath, "child", "file"), []byte("x"), 0o644))
+
+	absent := "absent"
+	d := &directoryResource{
+		Path:  dirPath,
+		State: &absent,
+	}
+
+	_, err := d.Run(t.Context())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "not empty")
+
+	_, err = os.Stat(dirPath)
+	require.NoError(t, err)
+
+	d.Force = true
+	_, err = d.Run(t.Context())
+	require.NoError(t, err)
+
+	_, err = os.Stat(dirPath)
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestDirectoryResource_Run_RemoveErrorWhenPathIsFile(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(filePath, []byte("x"), 0o644))
+
+	absent := "absent"
+	d := &directoryResource{
+		Path:  filePath,
+		State: &absent,
+	}
+
+	_, err := d.Run(t.Context())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "is not a directory")
+}
+
+func TestDirectoryResource_Run_RemoveRefusesTopLevel(t *testing.T) {
+	absent := "absent"
+
+	for _, path := range []string{"/", "/etc", "/usr/", "relative/dir", "/usr/lib", "/usr/share/doc/bash", "/var/lib", "/etc/ssh", "/usr/local"} {
+		d := &directoryResource{
+			Path:  path,
+			State: &absent,
+			Force: true,
+		}
+
+		_, err := d.Run(t.Context())
+		require.Error(t, err, path)
+		require.Contains(t, err.Error(), "refusing", path)
+	}
+}
+
+func TestDirectoryResource_Run_RemoveAllowlist(t *testing.T) {
+	allowed := t.TempDir()
+	other := t.TempDir()
+
+	absent := "absent"
+	inside := filepath.Join(allowed, "app")
+	outside := filepath.Join(other, "app")
+	require.NoError(t, os.Mkdir(inside, 0o755))
+	require.NoError(t, os.Mkdir(outside, 0o755))
+
+	d := &directoryResource{
+		Path:          outside,
+		State:         &absent,
+		removeAllowed: []string{allowed},
+	}
+
+	_, err := d.Run(t.Context())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "directory_remove_allowed")
+
+	// only paths inside the allowed directory
+	d.Path = allowed
+	_, err = d.Run(t.Context())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "directory_remove_allowed")
+
+	d.Path = inside
+	_, err = d.Run(t.Context())
+	require.NoError(t, err)
+
+	_, err = os.Stat(inside)
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestDirectoryResource_CheckRemovable(t *testing.T) {
+	d := &directoryResource{}
+	check := func(path string) error {
+		_, err := d.checkRemovable(path)
+		return err
+	}
+
+	require.NoError(t, check("/usr/local/share/app"))
+	require.NoError(t, check("/var/lib/app"))
+	require.NoError(t, check("/etc/ssh/sshd_config.d"))
+	require.Error(t, check("/usr/lib/app"))
+	require.Error(t, check("/var/log"))
+
+	// a link to a protected tree
+	link := filepath.Join(t.TempDir(), "link")
+	require.NoError(t, os.Symlink("/usr", link))
+	require.Error(t, check(filepath.Join(link, "lib")))
+}
+
+func TestDirectoryResource_Run_RemoveSymlinkedParent(t *testing.T) {
+	root := t.TempDir()
+	allowed := filepath.Join(root, "allowed")
+	outside := filepath.Join(root, "outside")
+	victim := filepath.Join(outside, "victim")
+	require.NoError(t, os.Mkdir(allowed, 0o755))
+	require.NoError(t, os.MkdirAll(victim, 0o755))
+	require.NoError(t, os.Symlink(outside, filepath.Join(allowed, "link")))
+
+	d := &directoryResource{
+		Path:          filepath.Join(allowed, "link", "victim"),
+		State:         ptr("absent"),
+		removeAllowed: []string{allowed},
+	}
+
+	_, err := d.Run(t.Context())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "directory_remove_allowed")
+
+	_, err = os.Stat(victim)
+	require.NoError(t, err)
+
+	// links that stay inside the allowed directory are followed
+	inside := filepath.Join(allowed, "real", "app")
+	require.NoError(t, os.MkdirAll(inside, 0o755))
+	require.NoError(t, os.Symlink(filepath.Join(allowed, "real"), filepath.Join(allowed, "current")))
+
+	d.Path = filepath.Join(allowed, "current", "app")
+	_, err = d.Run(t.Context())
+	require.NoError(t, err)
+
+	_, err = os.Stat(inside)
+	require.True(t, os.IsNotExist(err))
+
+	// the allowed directory can be given through a link
+	require.NoError(t, os.MkdirAll(inside, 0o755))
+	d.removeAllowed = []string{filepath.Join(allowed, "current")}
+	d.Path = filepath.Join(allowed, "real", "app")
+	_, err = d.Run(t.Context())
+	require.NoError(t, err)
+
+	_, err = os.Stat(inside)
+	require.True(t, os.IsNotExist(err))
+}
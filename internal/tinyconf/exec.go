@@ -0,0 +1,240 @@
+package tinyconf
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// execResource runs a command. guards are used to only run it when needed.
+type execResource struct {
+	// used in logs and as the notify target for refresh_only
+	Name *string `json:"name" validate:"required_if=RefreshOnly true"`
+	// ran using /bin/sh -c when args is empty
+	Command string            `json:"command" validate:"required"`
+	Args    []string          `json:"args"`
+	Cwd     *string           `json:"cwd"`
+	Env     map[string]string `json:"env"`
+	// run the command and guards as this user
+	User *string `json:"user"`
+	// duration, such as 30s or 5m
+	Timeout *string `json:"timeout" validate:"omitempty,duration"`
+	// skip if this path exists
+	Creates *string `json:"creates"`
+	// skip if this path does not exist
+	Removes *string `json:"removes"`
+	// skip if this shell command succeeds
+	Unless *string `json:"unless"`
+	// skip if this shell command fails
+	OnlyIf *string `json:"only_if"`
+	// only run when notified by an earlier resource
+	RefreshOnly bool           `json:"refresh_only"`
+	Notify      notifyResource `json:"notify"`
+}
+
+func (e *execResource) Run(ctx context.Context) (string, error) {
+	if e.RefreshOnly {
+		return "", nil
+	}
+
+	return e.run(ctx)
+}
+
+// runs the command, even if refresh_only is set. guards still apply.
+func (e *execResource) Refresh(ctx context.Context) (string, error) {
+	return e.run(ctx)
+}
+
+func (e *execResource) name() string {
+	if e.Name != nil {
+		return *e.Name
+	}
+
+	return e.Command
+}
+
+func (e *execResource) run(ctx context.Context) (string, error) {
+	if e.Timeout != nil {
+		timeout, err := time.ParseDuration(*e.Timeout)
+		if err != nil {
+			return "", fmt.Errorf("invalid timeout %s for exec %s %w", *e.Timeout, e.name(), err)
+		}
+
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	tasks := []func() (bool, error){
+		func() (bool, error) {
+			ok, err := e.shouldRun(ctx)
+			if err != nil || !ok {
+				return false, err
+			}
+
+			cmd, err := e.command(ctx, e.Command, e.Args)
+			if err != nil {
+				return false, err
+			}
+
+			var stdout, stderr bytes.Buffer
+			cmd.Stdout = &stdout
+			cmd.Stderr = &stderr
+
+			slog.Info("running command", "name", e.name())
+			err = cmd.Run()
+
+			slog.Info("command output", "name", e.name(), "stdout", strings.TrimSpace(stdout.String()), "stderr", strings.TrimSpace(stderr.String()))
+
+			if err != nil {
+				if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+					return true, fmt.Errorf("command %s timed out after %s %w", e.name(), *e.Timeout, err)
+				}
+				return true, fmt.Errorf("failed to run command %s (output: %s) %w", e.name(), strings.TrimSpace(stdout.String()+stderr.String()), err)
+			}
+
+			return true, nil
+		},
+	}
+
+	// use runTasks in case we add some debugging/logging/etc
+	changed, err := runTasks(tasks)
+	if err != nil {
+		return "", err
+	}
+
+	if changed {
+		return e.Notify.Service, nil
+	}
+
+	return "", nil
+}
+
+// checks the guards
+func (e *execResource) shouldRun(ctx context.Context) (bool, error) {
+	if e.Creates != nil {
+		if _, err := os.Stat(*e.Creates); err == nil {
+			return false, nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return false, fmt.Errorf("failed to stat %s %w", *e.Creates, err)
+		}
+	}
+
+	if e.Removes != nil {
+		if _, err := os.Stat(*e.Removes); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return false, nil
+			}
+			return false, fmt.Errorf("failed to stat %s %w", *e.Removes, err)
+		}
+	}
+
+	if e.Unless != nil {
+		ok, err := e.check(ctx, *e.Unless)
+		if err != nil || ok {
+			return false, err
+		}
+	}
+
+	if e.OnlyIf != nil {
+		ok, err := e.check(ctx, *e.OnlyIf)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// runs a guard command. a non-zero exit is not an error.
+func (e *execResource) check(ctx context.Context, command string) (bool, error) {
+	cmd, err := e.command(ctx, command, nil)
+	if err != nil {
+		return false, err
+	}
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		if _, ok := err.(*exec.ExitError); ok && ctx.Err() == nil {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to run guard %s for %s (output: %s) %w", command, e.name(), string(output), err)
+	}
+
+	return true, nil
+}
+
+func (e *execResource) command(ctx context.Context, command string, args []string) (*exec.Cmd, error) {
+	var cmd *exec.Cmd
+	if len(args) == 0 {
+		cmd = exec.CommandContext(ctx, "/bin/sh", "-c", command)
+	} else {
+		cmd = exec.CommandContext(ctx, command, args...)
+	}
+
+	// run in a process group so a timeout kills anything the command started
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+
+	if e.Cwd != nil {
+		cmd.Dir = *e.Cwd
+	}
+
+	var env []string
+
+	if e.User != nil {
+		// looked up at run time, so the user may be created earlier in the run
+		u, err := lookupUser(*e.User)
+		if err != nil {
+			return nil, fmt.Errorf("unable to determine uid for user %s needed by exec %s %w", *e.User, e.name(), err)
+		}
+
+		userID, groupID, err := getUserAndGroup("exec "+e.name(), &u.Uid, &u.Gid)
+		if err != nil {
+			return nil, err
+		}
+
+		groupIDs, err := u.GroupIds()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get groups of user %s needed by exec %s %w", *e.User, e.name(), err)
+		}
+
+		groups := make([]uint32, 0, len(groupIDs))
+		for _, id := range groupIDs {
+			gid, err := strconv.ParseUint(id, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid group id %s for user %s %w", id, *e.User, err)
+			}
+			groups = append(groups, uint32(gid))
+		}
+
+		cmd.SysProcAttr.Credential = &syscall.Credential{
+			Uid:    uint32(userID),
+			Gid:    uint32(groupID),
+			Groups: groups,
+		}
+
+		// as if logged in as the user, rather than root's environment
+		env = append(env, "HOME="+u.HomeDir, "USER="+u.Username, "LOGNAME="+u.Username)
+	}
+
+	// later values win, so env from the config overrides the user's
+	for k, v := range e.Env {
+		env = append(env, k+"="+v)
+	}
+
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+
+	return cmd, nil
+}
@@ -0,0 +1,242 @@
+package tinyconf
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecResource_Run_Command(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "output")
+
+	e := &execResource{
+		Command: "echo $GREETING > output",
+		Cwd:     &dir,
+		Env:     map[string]string{"GREETING": "hello"},
+		Notify: notifyResource{
+			Service: "test-service",
+		},
+	}
+
+	service, err := e.Run(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, "test-service", service)
+
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	require.Equal(t, "hello\n", string(data))
+}
+
+func TestExecResource_Run_Args(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file with spaces")
+
+	e := &execResource{
+		Command: "touch",
+		Args:    []string{path},
+	}
+
+	_, err := e.Run(t.Context())
+	require.NoError(t, err)
+
+	_, err = os.Stat(path)
+	require.NoError(t, err)
+}
+
+func TestExecResource_Run_Creates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "marker")
+
+	e := &execResource{
+		Command: "touch " + path,
+		Creates: &path,
+		Notify: notifyResource{
+			Service: "test-service",
+		},
+	}
+
+	ctx := t.Context()
+
+	service, err := e.Run(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "test-service", service)
+
+	service, err = e.Run(ctx)
+	require.NoError(t, err)
+	require.Empty(t, service)
+}
+
+func TestExecResource_Run_Removes(t *testing.T) {
+	path := writeTestFile(t, "", 0o644)
+
+	e := &execResource{
+		Command: "rm " + path,
+		Removes: &path,
+	}
+
+	ctx := t.Context()
+
+	_, err := e.Run(ctx)
+	require.NoError(t, err)
+
+	_, err = os.Stat(path)
+	require.True(t, os.IsNotExist(err))
+
+	// would fail if it ran again
+	_, err = e.Run(ctx)
+	require.NoError(t, err)
+}
+
+func TestExecResource_Run_Unless(t *testing.T) {
+	e := &execResource{
+		Command: "false",
+		Unless:  ptr("true"),
+		Notify: notifyResource{
+			Service: "test-service",
+		},
+	}
+
+	service, err := e.Run(t.Context())
+	require.NoError(t, err)
+	require.Empty(t, service)
+}
+
+func TestExecResource_Run_OnlyIf(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "marker")
+
+	e := &execResource{
+		Command: "touch " + path,
+		OnlyIf:  ptr("test -e " + path),
+	}
+
+	_, err := e.Run(t.Context())
+	require.NoError(t, err)
+
+	_, err = os.Stat(path)
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestExecResource_Run_FailureIncludesOutput(t *testing.T) {
+	e := &execResource{
+		Name:    ptr("broken"),
+		Command: "echo something went wrong >&2; exit 3",
+	}
+
+	_, err := e.Run(t.Context())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "broken")
+	require.Contains(t, err.Error(), "something went wrong")
+}
+
+func TestExecResource_Run_Timeout(t *testing.T) {
+	e := &execResource{
+		Command: "sleep 5",
+		Timeout: ptr("50ms"),
+	}
+
+	_, err := e.Run(t.Context())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "timed out")
+}
+
+func TestExecResource_Run_InvalidTimeout(t *testing.T) {
+	e := &execResource{
+		Command: "true",
+		Timeout: ptr("soon"),
+	}
+
+	_, err := e.Run(t.Context())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid timeout")
+}
+
+func TestExecResource_Run_UserEnvironment(t *testing.T) {
+	u, err := user.Current()
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	output := filepath.Join(dir, "output")
+
+	e := &execResource{
+		Command: `printf '%s\n%s\n%s\n' "$HOME" "$USER" "$(id -G)" > output`,
+		Cwd:     &dir,
+		User:    &u.Username,
+		Env:     map[string]string{"USER": "override"},
+	}
+
+	_, err = e.Run(t.Context())
+	require.NoError(t, err)
+
+	groups, err := u.GroupIds()
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 3)
+	require.Equal(t, u.HomeDir, lines[0])
+	// env from the config wins
+	require.Equal(t, "override", lines[1])
+	require.ElementsMatch(t, groups, strings.Fields(lines[2]))
+}
+
+func TestExecResource_Run_RefreshOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "marker")
+
+	e := &execResource{
+		Name:        ptr("make-marker"),
+		Command:     "touch " + path,
+		RefreshOnly: true,
+	}
+
+	service, err := e.Run(t.Context())
+	require.NoError(t, err)
+	require.Empty(t, service)
+
+	_, err = os.Stat(path)
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestRunRunners_RefreshOnlyExec(t *testing.T) {
+	dir := t.TempDir()
+	notified := filepath.Join(dir, "notified")
+	skipped := filepath.Join(dir, "skipped")
+
+	runners := []runner{
+		&fileResource{
+			Path:     filepath.Join(dir, "config"),
+			Contents: ptr("data"),
+			Notify: notifyResource{
+				Service: "reload-config",
+			},
+		},
+		&execResource{
+			Name:        ptr("reload-config"),
+			Command:     "touch " + notified,
+			RefreshOnly: true,
+			Notify: notifyResource{
+				Service: "test-service",
+			},
+		},
+		&execResource{
+			Name:        ptr("not-notified"),
+			Command:     "touch " + skipped,
+			RefreshOnly: true,
+		},
+	}
+
+	services, err := runRunners(t.Context(), runners)
+	require.NoError(t, err)
+	// the exec consumes its notification
+	require.Equal(t, []notifyResource{{Service: "test-service"}}, services)
+
+	_, err = os.Stat(notified)
+	require.NoError(t, err)
+
+	_, err = os.Stat(skipped)
+	require.True(t, os.IsNotExist(err))
+}
@@ -23,7 +23,7 @@ type fileResource struct {
 const defaultFileMode = os.FileMode(0o644)
 
 func (f *fileResource) Run(ctx context.Context) (string, error) {
-	userID, groupID, err := getUserAndGroup(f.Owner, f.Group)
+	userID, groupID, err := getUserAndGroup("file "+f.Path, f.Owner, f.Group)
 	if err != nil {
 		return "", err
 	}
@@ -138,33 +138,9 @@ func (f *fileResource) Run(ctx context.Context) (string, error) {
 						return false, nil
 					}
 
-					// attempt to write to tempfile and move into place
-					file, err := os.CreateTemp(filepath.Dir(f.Path), ".*.tmp")
-					if err != nil {
-						return false, fmt.Errorf("failed to create temp file for %s %w", f.Path, err)
-					}
-
-					defer func() {
-						_ = file.Close()
-						_ = os.Remove(file.Name())
-					}()
-
-					if _, err := file.WriteString(*f.Contents); err != nil {
-						return false, fmt.Errorf("failed to write to temp file for %s %w", f.Path, err)
-					}
-
-					if err := file.Close(); err != nil {
-						return false, fmt.Errorf("failed to close temp file for %s %w", f.Path, err)
-					}
-
-					// need to reread permissions - we could be smarter about this, but brute force is fine for now
-					if err := copyPermissions(f.Path, file.Name()); err != nil {
-						return false, err
-					}
-
 					slog.Info("updating file contents", "path", f.Path)
-					if err := os.Rename(file.Name(), f.Path); err != nil {
-						return false, fmt.Errorf("failed to rename temp file for %s %w", f.Path, err)
+					if err := replaceFile(f.Path, []byte(*f.Contents)); err != nil {
+						return false, err
 					}
 
 					return true, nil
@@ -184,3 +160,37 @@ func (f *fileResource) Run(ctx context.Context) (string, error) {
 
 	return "", nil
 }
+
+// replaceFile atomically replaces the contents of an existing file by writing
+// to a temp file in the same directory and renaming it into place.
+// The permissions and ownership of the original file are preserved.
+func replaceFile(path string, contents []byte) error {
+	file, err := os.CreateTemp(filepath.Dir(path), ".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file for %s %w", path, err)
+	}
+
+	defer func() {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+	}()
+
+	if _, err := file.Write(contents); err != nil {
+		return fmt.Errorf("failed to write to temp file for %s %w", path, err)
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close temp file for %s %w", path, err)
+	}
+
+	// need to reread permissions - we could be smarter about this, but brute force is fine for now
+	if err := copyPermissions(path, file.Name()); err != nil {
+		return err
+	}
+
+	if err := os.Rename(file.Name(), path); err != nil {
+		return fmt.Errorf("failed to rename temp file for %s %w", path, err)
+	}
+
+	return nil
+}
@@ -507,3 +507,67 @@ func TestFileResource_Run_CreateAndRemoveCycle(t *testing.T) {
 	_, err = os.Stat(filePath)
 	require.NoError(t, err)
 }
+
+func TestFileResource_Run_NumericOwnerAndGroup(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "test.txt")
+
+	// use the current ids so this works without root
+	owner := strconv.Itoa(os.Getuid())
+	group := strconv.Itoa(os.Getgid())
+
+	f := &fileResource{
+		Path:  filePath,
+		Owner: &owner,
+		Group: &group,
+	}
+
+	_, err := f.Run(t.Context())
+	require.NoError(t, err)
+
+	info, err := os.Stat(filePath)
+	require.NoError(t, err)
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	require.True(t, ok)
+
+	require.Equal(t, uint32(os.Getuid()), stat.Uid)
+	require.Equal(t, uint32(os.Getgid()), stat.Gid)
+}
+
+func TestFileResource_Run_NumericOwnerWithoutUser(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("skipping test that requires root privileges")
+	}
+
+	filePath := filepath.Join(t.TempDir(), "test.txt")
+
+	// an id that almost certainly does not have a user
+	owner := "54321"
+
+	f := &fileResource{
+		Path:  filePath,
+		Owner: &owner,
+	}
+
+	_, err := f.Run(t.Context())
+	require.NoError(t, err)
+
+	info, err := os.Stat(filePath)
+	require.NoError(t, err)
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	require.True(t, ok)
+	require.Equal(t, uint32(54321), stat.Uid)
+}
+
+func TestFileResource_Run_ErrorIncludesResource(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "test.txt")
+	invalidUser := "thisuserdoesnotexist12345"
+
+	f := &fileResource{
+		Path:  filePath,
+		Owner: &invalidUser,
+	}
+
+	_, err := f.Run(t.Context())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "needed by file "+filePath)
+}
@@ -0,0 +1,199 @@
+package tinyconf
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type groupResource struct {
+	Name string `json:"name" validate:"required"`
+	GID  *int   `json:"gid"`
+	// only used when creating the group
+	System  bool     `json:"system"`
+	Members []string `json:"members"`
+	// if set, members not listed are removed. otherwise members are only added
+	Exclusive bool           `json:"exclusive"`
+	State     *string        `json:"state" validate:"omitempty,oneof=present absent"`
+	Notify    notifyResource `json:"notify"`
+	manager   groupManager
+}
+
+// current state of a group
+type groupInfo struct {
+	GID     int
+	Members []string
+}
+
+// for testing
+type groupManager interface {
+	// returns nil if the group does not exist
+	Get(context.Context, string) (*groupInfo, error)
+	Create(context.Context, string, *int, bool) error
+	SetGID(context.Context, string, int) error
+	SetMembers(context.Context, string, []string) error
+	Delete(context.Context, string) error
+}
+
+func (g *groupResource) Run(ctx context.Context) (string, error) {
+	if isNil(g.manager) {
+		g.manager = &shadowGroupManager{}
+	}
+
+	shouldExist := g.State == nil || *g.State == "present"
+
+	var info *groupInfo
+
+	tasks := []func() (bool, error){
+		func() (bool, error) {
+			var err error
+			info, err = g.manager.Get(ctx, g.Name)
+			if err != nil {
+				return false, fmt.Errorf("failed to get group %s %w", g.Name, err)
+			}
+
+			if !shouldExist {
+				if info == nil {
+					return false, nil
+				}
+
+				slog.Info("removing group", "name", g.Name)
+				return true, g.manager.Delete(ctx, g.Name)
+			}
+
+			if info == nil {
+				slog.Info("creating group", "name", g.Name)
+				if err := g.manager.Create(ctx, g.Name, g.GID, g.System); err != nil {
+					return true, err
+				}
+				info = &groupInfo{}
+				return true, nil
+			}
+
+			if g.GID == nil || *g.GID == info.GID {
+				return false, nil
+			}
+
+			slog.Info("changing group gid", "name", g.Name, "gid", *g.GID)
+			return true, g.manager.SetGID(ctx, g.Name, *g.GID)
+		},
+		func() (bool, error) {
+			if !shouldExist || g.Members == nil {
+				return false, nil
+			}
+
+			members := slices.Clone(g.Members)
+			if !g.Exclusive {
+				members = append(members, info.Members...)
+			}
+			slices.Sort(members)
+			members = slices.Compact(members)
+
+			current := slices.Sorted(slices.Values(info.Members))
+			if slices.Equal(members, current) {
+				return false, nil
+			}
+
+			slog.Info("changing group members", "name", g.Name, "members", members)
+			return true, g.manager.SetMembers(ctx, g.Name, members)
+		},
+	}
+
+	// use runTasks in case we add some debugging/logging/etc
+	changed, err := runTasks(tasks)
+	if err != nil {
+		return "", err
+	}
+
+	if changed {
+		return g.Notify.Service, nil
+	}
+
+	return "", nil
+}
+
+type shadowGroupManager struct{}
+
+// parses a single group file formatted line
+func parseGroupEntry(line string) (*groupInfo, error) {
+	// name:password:gid:members
+	fields := strings.Split(strings.TrimSpace(line), ":")
+	if len(fields) != 4 {
+		return nil, fmt.Errorf("unexpected group entry: %s", line)
+	}
+
+	gid, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return nil, fmt.Errorf("unexpected gid in group entry: %s %w", line, err)
+	}
+
+	info := &groupInfo{GID: gid}
+	if fields[3] != "" {
+		info.Members = strings.Split(fields[3], ",")
+	}
+
+	return info, nil
+}
+
+func (s *shadowGroupManager) Get(ctx context.Context, name string) (*groupInfo, error) {
+	cmd := exec.CommandContext(ctx, "getent", "group", name)
+	output, err := cmd.Output()
+	if err != nil {
+		// getent returns exit code 2 when the key is not found
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 2 {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get group %s: %w", name, err)
+	}
+
+	return parseGroupEntry(string(output))
+}
+
+func (s *shadowGroupManager) Create(ctx context.Context, name string, gid *int, system bool) error {
+	var args []string
+	if gid != nil {
+		args = append(args, "-g", strconv.Itoa(*gid))
+	}
+	if system {
+		args = append(args, "-r")
+	}
+	args = append(args, name)
+
+	cmd := exec.CommandContext(ctx, "groupadd", args...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to create group %s (output: %s): %w", name, string(output), err)
+	}
+
+	return nil
+}
+
+func (s *shadowGroupManager) SetGID(ctx context.Context, name string, gid int) error {
+	cmd := exec.CommandContext(ctx, "groupmod", "-g", strconv.Itoa(gid), name)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to change gid of group %s (output: %s): %w", name, string(output), err)
+	}
+
+	return nil
+}
+
+func (s *shadowGroupManager) SetMembers(ctx context.Context, name string, members []string) error {
+	cmd := exec.CommandContext(ctx, "gpasswd", "-M", strings.Join(members, ","), name)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to set members of group %s (output: %s): %w", name, string(output), err)
+	}
+
+	return nil
+}
+
+func (s *shadowGroupManager) Delete(ctx context.Context, name string) error {
+	cmd := exec.CommandContext(ctx, "groupdel", name)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to remove group %s (output: %s): %w", name, string(output), err)
+	}
+
+	return nil
+}
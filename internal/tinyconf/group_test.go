@@ -0,0 +1,199 @@
+package tinyconf
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type mockGroupManager struct {
+	groups           map[string]*groupInfo
+	getErr           error
+	createCalled     []string
+	setGIDCalled     map[string]int
+	setMembersCalled map[string][]string
+	deleteCalled     []string
+}
+
+func newMockGroupManager() *mockGroupManager {
+	return &mockGroupManager{
+		groups:           make(map[string]*groupInfo),
+		setGIDCalled:     make(map[string]int),
+		setMembersCalled: make(map[string][]string),
+	}
+}
+
+func (m *mockGroupManager) Get(ctx context.Context, name string) (*groupInfo, error) {
+	if m.getErr != nil {
+		return nil, m.getErr
+	}
+	return m.groups[name], nil
+}
+
+func (m *mockGroupManager) Create(ctx context.Context, name string, gid *int, system bool) error {
+	m.createCalled = append(m.createCalled, name)
+	info := &groupInfo{}
+	if gid != nil {
+		info.GID = *gid
+	}
+	m.groups[name] = info
+	return nil
+}
+
+func (m *mockGroupManager) SetGID(ctx context.Context, name string, gid int) error {
+	m.setGIDCalled[name] = gid
+	m.groups[name].GID = gid
+	return nil
+}
+
+func (m *mockGroupManager) SetMembers(ctx context.Context, name string, members []string) error {
+	m.setMembersCalled[name] = members
+	m.groups[name].Members = members
+	return nil
+}
+
+func (m *mockGroupManager) Delete(ctx context.Context, name string) error {
+	m.deleteCalled = append(m.deleteCalled, name)
+	delete(m.groups, name)
+	return nil
+}
+
+func TestGroupResource_Run_CreateGroupWithMembers(t *testing.T) {
+	mock := newMockGroupManager()
+
+	g := &groupResource{
+		Name:    "deploy",
+		GID:     ptr(1600),
+		Members: []string{"bob", "alice"},
+		Notify: notifyResource{
+			Service: "test-service",
+		},
+		manager: mock,
+	}
+
+	ctx := t.Context()
+
+	service, err := g.Run(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "test-service", service)
+	require.Equal(t, []string{"deploy"}, mock.createCalled)
+	require.Equal(t, []string{"alice", "bob"}, mock.setMembersCalled["deploy"])
+
+	service, err = g.Run(ctx)
+	require.NoError(t, err)
+	require.Empty(t, service)
+}
+
+func TestGroupResource_Run_AdditiveMembers(t *testing.T) {
+	mock := newMockGroupManager()
+	mock.groups["www-data"] = &groupInfo{GID: 33, Members: []string{"existing"}}
+
+	g := &groupResource{
+		Name:    "www-data",
+		Members: []string{"deploy"},
+		manager: mock,
+	}
+
+	_, err := g.Run(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, []string{"deploy", "existing"}, mock.setMembersCalled["www-data"])
+}
+
+func TestGroupResource_Run_AdditiveMembersAlreadyPresent(t *testing.T) {
+	mock := newMockGroupManager()
+	mock.groups["www-data"] = &groupInfo{GID: 33, Members: []string{"existing", "deploy"}}
+
+	g := &groupResource{
+		Name:    "www-data",
+		Members: []string{"deploy"},
+		manager: mock,
+	}
+
+	service, err := g.Run(t.Context())
+	require.NoError(t, err)
+	require.Empty(t, service)
+	require.Empty(t, mock.setMembersCalled)
+}
+
+func TestGroupResource_Run_ExclusiveMembers(t *testing.T) {
+	mock := newMockGroupManager()
+	mock.groups["deploy"] = &groupInfo{GID: 1600, Members: []string{"old", "deploy"}}
+
+	g := &groupResource{
+		Name:      "deploy",
+		Members:   []string{"deploy"},
+		Exclusive: true,
+		manager:   mock,
+	}
+
+	_, err := g.Run(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, []string{"deploy"}, mock.setMembersCalled["deploy"])
+}
+
+func TestGroupResource_Run_ChangeGID(t *testing.T) {
+	mock := newMockGroupManager()
+	mock.groups["deploy"] = &groupInfo{GID: 1600}
+
+	g := &groupResource{
+		Name:    "deploy",
+		GID:     ptr(1700),
+		manager: mock,
+	}
+
+	_, err := g.Run(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, 1700, mock.setGIDCalled["deploy"])
+	require.Empty(t, mock.setMembersCalled)
+}
+
+func TestGroupResource_Run_RemoveGroup(t *testing.T) {
+	mock := newMockGroupManager()
+	mock.groups["old"] = &groupInfo{GID: 1600}
+
+	g := &groupResource{
+		Name:    "old",
+		State:   ptr("absent"),
+		manager: mock,
+	}
+
+	ctx := t.Context()
+
+	_, err := g.Run(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"old"}, mock.deleteCalled)
+
+	_, err = g.Run(ctx)
+	require.NoError(t, err)
+	require.Len(t, mock.deleteCalled, 1)
+}
+
+func TestGroupResource_Run_ErrorOnGet(t *testing.T) {
+	mock := newMockGroupManager()
+	mock.getErr = errors.New("getent failed")
+
+	g := &groupResource{
+		Name:    "deploy",
+		manager: mock,
+	}
+
+	_, err := g.Run(t.Context())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "getent failed")
+}
+
+func TestParseGroupEntry(t *testing.T) {
+	info, err := parseGroupEntry("www-data:x:33:deploy,bob\n")
+	require.NoError(t, err)
+	require.Equal(t, 33, info.GID)
+	require.Equal(t, []string{"deploy", "bob"}, info.Members)
+
+	info, err = parseGroupEntry("empty:x:1000:")
+	require.NoError(t, err)
+	require.Empty(t, info.Members)
+
+	_, err = parseGroupEntry("bad")
+	require.Error(t, err)
+}
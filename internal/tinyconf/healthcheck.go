@@ -0,0 +1,115 @@
+package tinyconf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os/exec"
+	"time"
+)
+
+const (
+	defaultHealthcheckTimeout = 30 * time.Second
+	// delay between checks starts small and doubles up to the max
+	healthcheckInitialDelay = 100 * time.Millisecond
+	healthcheckMaxDelay     = 5 * time.Second
+)
+
+// healthcheck verifies a service is working after it is started or restarted.
+// exactly one of TCP, HTTP, or Command is used.
+type healthcheck struct {
+	// host:port that accepts connections
+	TCP *string `json:"tcp" validate:"required_without_all=HTTP Command,excluded_with=HTTP Command"`
+	// URL for a GET request
+	HTTP *string `json:"http" validate:"omitempty,url,excluded_with=Command"`
+	// expected HTTP status. defaults to 200
+	Status *int `json:"status" validate:"excluded_without=HTTP"`
+	// ran with /bin/sh -c and must exit 0
+	Command *string `json:"command"`
+	// how long to wait for the service to become healthy. defaults to 30s
+	Timeout *string `json:"timeout" validate:"omitempty,duration"`
+}
+
+// polls until the check passes or the timeout is reached
+func (h *healthcheck) wait(ctx context.Context, service string) error {
+	timeout := defaultHealthcheckTimeout
+	if h.Timeout != nil {
+		var err error
+		if timeout, err = time.ParseDuration(*h.Timeout); err != nil {
+			return fmt.Errorf("invalid healthcheck timeout %s for service %s %w", *h.Timeout, service, err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	slog.Info("checking service health", "name", service)
+
+	var lastErr error
+	delay := healthcheckInitialDelay
+	for {
+		err := h.check(ctx)
+		if err == nil {
+			return nil
+		}
+
+		// the check that was cut off by the timeout is less useful than the one before it
+		if lastErr == nil || ctx.Err() == nil {
+			lastErr = err
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("service %s was not healthy after %s %w", service, timeout, lastErr)
+		case <-time.After(delay):
+		}
+
+		delay = min(delay*2, healthcheckMaxDelay)
+	}
+}
+
+func (h *healthcheck) check(ctx context.Context) error {
+	switch {
+	case h.TCP != nil:
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", *h.TCP)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	case h.HTTP != nil:
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, *h.HTTP, nil)
+		if err != nil {
+			return err
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		expected := http.StatusOK
+		if h.Status != nil {
+			expected = *h.Status
+		}
+
+		if resp.StatusCode != expected {
+			return fmt.Errorf("unexpected status %s, expected %d", resp.Status, expected)
+		}
+
+		return nil
+	case h.Command != nil:
+		output, err := exec.CommandContext(ctx, "/bin/sh", "-c", *h.Command).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("(output: %s): %w", string(output), err)
+		}
+		return nil
+	default:
+		// validation should catch this, but in case
+		return errors.New("healthcheck requires tcp, http, or command")
+	}
+}
@@ -0,0 +1,123 @@
+package tinyconf
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthcheck_TCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := listener.Addr().String()
+
+	check := &healthcheck{TCP: ptr(address), Timeout: ptr("1s")}
+	require.NoError(t, check.wait(t.Context(), "app"))
+
+	require.NoError(t, listener.Close())
+	require.Error(t, check.wait(t.Context(), "app"))
+}
+
+func TestHealthcheck_HTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	check := &healthcheck{HTTP: ptr(server.URL + "/health"), Timeout: ptr("1s")}
+	require.NoError(t, check.wait(t.Context(), "app"))
+
+	check = &healthcheck{HTTP: ptr(server.URL + "/missing"), Timeout: ptr("300ms")}
+	err := check.wait(t.Context(), "app")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "404")
+
+	check.Status = ptr(http.StatusNotFound)
+	require.NoError(t, check.wait(t.Context(), "app"))
+}
+
+func TestHealthcheck_Command(t *testing.T) {
+	check := &healthcheck{Command: ptr("true")}
+	require.NoError(t, check.wait(t.Context(), "app"))
+
+	check = &healthcheck{Command: ptr("echo not ready; false"), Timeout: ptr("300ms")}
+	err := check.wait(t.Context(), "app")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "not ready")
+}
+
+func TestHealthcheck_BecomesHealthy(t *testing.T) {
+	// the service takes a moment to start listening
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	listening := make(chan net.Listener, 1)
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		l, err := net.Listen("tcp", address)
+		if err != nil {
+			close(listening)
+			return
+		}
+		listening <- l
+	}()
+
+	check := &healthcheck{TCP: ptr(address), Timeout: ptr("5s")}
+	require.NoError(t, check.wait(t.Context(), "app"))
+
+	l, ok := <-listening
+	require.True(t, ok)
+	require.NoError(t, l.Close())
+}
+
+func TestServiceResource_Run_Healthcheck(t *testing.T) {
+	mock := newMockServiceManager()
+
+	resource := &serviceResource{
+		Name:        "app",
+		State:       "running",
+		Healthcheck: &healthcheck{Command: ptr("false"), Timeout: ptr("200ms")},
+		manager:     mock,
+	}
+
+	_, err := resource.Run(t.Context())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "not healthy")
+
+	// only checked when the service is started
+	_, err = resource.Run(t.Context())
+	require.NoError(t, err)
+}
+
+func TestNotifyServices_Healthcheck(t *testing.T) {
+	mock := newMockServiceNotifier()
+	services := []notifyResource{
+		{Service: "nginx", Action: "reload", Healthcheck: &healthcheck{Command: ptr("false"), Timeout: ptr("200ms")}},
+		{Service: "mysql"},
+	}
+
+	err := notifyServices(t.Context(), mock, services)
+	require.Error(t, err)
+	require.Equal(t, []string{"nginx"}, mock.reloadCalled)
+	require.Empty(t, mock.restartCalled)
+}
+
+func TestRunRunners_KeepsHealthcheck(t *testing.T) {
+	check := &healthcheck{TCP: ptr("127.0.0.1:80")}
+	runners := []runner{
+		&changedRunner{Notify: notifyResource{Service: "nginx"}},
+		&changedRunner{Notify: notifyResource{Service: "nginx", Healthcheck: check}},
+	}
+
+	services, err := runRunners(t.Context(), runners)
+	require.NoError(t, err)
+	require.Equal(t, []notifyResource{{Service: "nginx", Healthcheck: check}}, services)
+}
@@ -0,0 +1,230 @@
+package tinyconf
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// lineResource manages a single line in an existing file.
+// Loosely modeled on ansible's lineinfile.
+type lineResource struct {
+	Path string `json:"path" validate:"required"`
+	// the line to ensure is present. not needed when state is absent and regexp is set
+	Line *string `json:"line" validate:"required_without=Regexp"`
+	// if set, the last line matching is replaced when present, or
+	// all matching lines are removed when absent
+	Regexp       *string        `json:"regexp"`
+	InsertAfter  *string        `json:"insert_after" validate:"excluded_with=InsertBefore"`
+	InsertBefore *string        `json:"insert_before"`
+	State        *string        `json:"state" validate:"omitempty,oneof=present absent"`
+	Notify       notifyResource `json:"notify"`
+	// where the diff of changes is written. defaults to stdout
+	diffOutput io.Writer
+}
+
+func (l *lineResource) Run(ctx context.Context) (string, error) {
+	shouldExist := l.State == nil || *l.State == "present"
+
+	if shouldExist && l.Line == nil {
+		return "", fmt.Errorf("line is required for %s when state is present", l.Path)
+	}
+
+	tasks := []func() (bool, error){
+		func() (bool, error) {
+			data, err := os.ReadFile(l.Path)
+			if err != nil {
+				return false, fmt.Errorf("failed to read %s %w", l.Path, err)
+			}
+
+			var (
+				updated string
+				changed bool
+			)
+			if shouldExist {
+				updated, changed, err = l.ensurePresent(string(data))
+			} else {
+				updated, changed, err = l.ensureAbsent(string(data))
+			}
+			if err != nil {
+				return false, err
+			}
+
+			if !changed {
+				return false, nil
+			}
+
+			out := l.diffOutput
+			if out == nil {
+				out = os.Stdout
+			}
+			fmt.Fprint(out, unifiedDiff(l.Path, string(data), updated))
+
+			if err := replaceFile(l.Path, []byte(updated)); err != nil {
+				return false, err
+			}
+
+			return true, nil
+		},
+	}
+
+	// use runTasks in case we add some debugging/logging/etc
+	changed, err := runTasks(tasks)
+	if err != nil {
+		return "", err
+	}
+
+	if changed {
+		return l.Notify.Service, nil
+	}
+
+	return "", nil
+}
+
+func (l *lineResource) ensurePresent(contents string) (string, bool, error) {
+	lines, trailingNewline := splitLines(contents)
+	line := *l.Line
+
+	if l.Regexp != nil {
+		re, err := compileRegexp("regexp", *l.Regexp)
+		if err != nil {
+			return "", false, err
+		}
+
+		if i := lastMatch(lines, re); i != -1 {
+			if lines[i] == line {
+				return "", false, nil
+			}
+
+			slog.Info("replacing line", "path", l.Path, "old", lines[i], "new", line)
+			lines[i] = line
+			return joinLines(lines, trailingNewline), true, nil
+		}
+	}
+
+	if slices.Contains(lines, line) {
+		return "", false, nil
+	}
+
+	// default to the end of the file if no anchor matches
+	index := len(lines)
+	switch {
+	case l.InsertAfter != nil:
+		re, err := compileRegexp("insert_after", *l.InsertAfter)
+		if err != nil {
+			return "", false, err
+		}
+		if i := lastMatch(lines, re); i != -1 {
+			index = i + 1
+		}
+	case l.InsertBefore != nil:
+		re, err := compileRegexp("insert_before", *l.InsertBefore)
+		if err != nil {
+			return "", false, err
+		}
+		if i := firstMatch(lines, re); i != -1 {
+			index = i
+		}
+	}
+
+	if index == len(lines) {
+		trailingNewline = true
+	}
+
+	slog.Info("inserting line", "path", l.Path, "line", line, "index", index)
+	lines = append(lines[:index], append([]string{line}, lines[index:]...)...)
+
+	return joinLines(lines, trailingNewline), true, nil
+}
+
+func (l *lineResource) ensureAbsent(contents string) (string, bool, error) {
+	lines, trailingNewline := splitLines(contents)
+
+	match := func(s string) bool {
+		return s == *l.Line
+	}
+
+	if l.Regexp != nil {
+		re, err := compileRegexp("regexp", *l.Regexp)
+		if err != nil {
+			return "", false, err
+		}
+		match = re.MatchString
+	}
+
+	var (
+		out     []string
+		changed bool
+	)
+	for _, line := range lines {
+		if match(line) {
+			slog.Info("removing line", "path", l.Path, "line", line)
+			changed = true
+			continue
+		}
+		out = append(out, line)
+	}
+
+	if !changed {
+		return "", false, nil
+	}
+
+	return joinLines(out, trailingNewline), true, nil
+}
+
+func compileRegexp(field string, expr string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q %w", field, expr, err)
+	}
+
+	return re, nil
+}
+
+// returns the lines and whether the contents ended with a newline.
+// empty contents are treated as having a trailing newline so that
+// appended lines are terminated.
+func splitLines(contents string) ([]string, bool) {
+	if contents == "" {
+		return nil, true
+	}
+
+	trailingNewline := strings.HasSuffix(contents, "\n")
+	lines := strings.Split(strings.TrimSuffix(contents, "\n"), "\n")
+
+	return lines, trailingNewline
+}
+
+func joinLines(lines []string, trailingNewline bool) string {
+	out := strings.Join(lines, "\n")
+	if trailingNewline && len(lines) > 0 {
+		out += "\n"
+	}
+
+	return out
+}
+
+func firstMatch(lines []string, re *regexp.Regexp) int {
+	for i, line := range lines {
+		if re.MatchString(line) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func lastMatch(lines []string, re *regexp.Regexp) int {
+	for i := len(lines) - 1; i >= 0; i-- {
+		if re.MatchString(lines[i]) {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,311 @@
+package tinyconf
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestFile(t *testing.T, contents string, mode os.FileMode) string {
+	t.Helper()
+
+	filePath := filepath.Join(t.TempDir(), "test.conf")
+	err := os.WriteFile(filePath, []byte(contents), mode)
+	require.NoError(t, err)
+
+	return filePath
+}
+
+func TestLineResource_Run_AppendMissingLine(t *testing.T) {
+	filePath := writeTestFile(t, "a=1\nb=2\n", 0o644)
+	line := "c=3"
+
+	l := &lineResource{
+		Path: filePath,
+		Line: &line,
+		Notify: notifyResource{
+			Service: "test-service",
+		},
+	}
+
+	service, err := l.Run(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, "test-service", service)
+
+	data, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	require.Equal(t, "a=1\nb=2\nc=3\n", string(data))
+}
+
+func TestLineResource_Run_AppendToFileWithoutTrailingNewline(t *testing.T) {
+	filePath := writeTestFile(t, "a=1", 0o644)
+	line := "b=2"
+
+	l := &lineResource{
+		Path: filePath,
+		Line: &line,
+	}
+
+	_, err := l.Run(t.Context())
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	require.Equal(t, "a=1\nb=2\n", string(data))
+}
+
+func TestLineResource_Run_LineAlreadyPresent(t *testing.T) {
+	filePath := writeTestFile(t, "a=1\nb=2\n", 0o644)
+	line := "a=1"
+
+	l := &lineResource{
+		Path: filePath,
+		Line: &line,
+		Notify: notifyResource{
+			Service: "test-service",
+		},
+	}
+
+	service, err := l.Run(t.Context())
+	require.NoError(t, err)
+	require.Empty(t, service)
+}
+
+func TestLineResource_Run_ReplaceMatchingLine(t *testing.T) {
+	filePath := writeTestFile(t, "memory_limit = 128M\nmax_execution_time = 30\n", 0o644)
+	line := "memory_limit = 512M"
+	re := `^memory_limit\s*=`
+
+	l := &lineResource{
+		Path:   filePath,
+		Line:   &line,
+		Regexp: &re,
+		Notify: notifyResource{
+			Service: "test-service",
+		},
+	}
+
+	ctx := t.Context()
+
+	service, err := l.Run(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "test-service", service)
+
+	data, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	require.Equal(t, "memory_limit = 512M\nmax_execution_time = 30\n", string(data))
+
+	// idempotent
+	service, err = l.Run(ctx)
+	require.NoError(t, err)
+	require.Empty(t, service)
+}
+
+func TestLineResource_Run_ReplacesLastMatch(t *testing.T) {
+	filePath := writeTestFile(t, "x=1\ny=2\nx=3\n", 0o644)
+	line := "x=9"
+	re := `^x=`
+
+	l := &lineResource{
+		Path:   filePath,
+		Line:   &line,
+		Regexp: &re,
+	}
+
+	_, err := l.Run(t.Context())
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	require.Equal(t, "x=1\ny=2\nx=9\n", string(data))
+}
+
+func TestLineResource_Run_InsertAfter(t *testing.T) {
+	filePath := writeTestFile(t, "[main]\na=1\n[other]\nb=2\n", 0o644)
+	line := "c=3"
+	after := `^\[main\]`
+
+	l := &lineResource{
+		Path:        filePath,
+		Line:        &line,
+		InsertAfter: &after,
+	}
+
+	_, err := l.Run(t.Context())
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	require.Equal(t, "[main]\nc=3\na=1\n[other]\nb=2\n", string(data))
+}
+
+func TestLineResource_Run_InsertBefore(t *testing.T) {
+	filePath := writeTestFile(t, "a=1\n[other]\nb=2\n", 0o644)
+	line := "c=3"
+	before := `^\[other\]`
+
+	l := &lineResource{
+		Path:         filePath,
+		Line:         &line,
+		InsertBefore: &before,
+	}
+
+	_, err := l.Run(t.Context())
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	require.Equal(t, "a=1\nc=3\n[other]\nb=2\n", string(data))
+}
+
+func TestLineResource_Run_AnchorNotFoundAppends(t *testing.T) {
+	filePath := writeTestFile(t, "a=1\n", 0o644)
+	line := "b=2"
+	after := `^nomatch`
+
+	l := &lineResource{
+		Path:        filePath,
+		Line:        &line,
+		InsertAfter: &after,
+	}
+
+	_, err := l.Run(t.Context())
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	require.Equal(t, "a=1\nb=2\n", string(data))
+}
+
+func TestLineResource_Run_RemoveByRegexp(t *testing.T) {
+	filePath := writeTestFile(t, "a=1\n#x=2\nx=3\nb=4\n", 0o644)
+	re := `^#?x=`
+	absent := "absent"
+
+	l := &lineResource{
+		Path:   filePath,
+		Regexp: &re,
+		State:  &absent,
+		Notify: notifyResource{
+			Service: "test-service",
+		},
+	}
+
+	ctx := t.Context()
+
+	service, err := l.Run(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "test-service", service)
+
+	data, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	require.Equal(t, "a=1\nb=4\n", string(data))
+
+	service, err = l.Run(ctx)
+	require.NoError(t, err)
+	require.Empty(t, service)
+}
+
+func TestLineResource_Run_RemoveExactLine(t *testing.T) {
+	filePath := writeTestFile(t, "a=1\nb=2\n", 0o644)
+	line := "b=2"
+	absent := "absent"
+
+	l := &lineResource{
+		Path:  filePath,
+		Line:  &line,
+		State: &absent,
+	}
+
+	_, err := l.Run(t.Context())
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	require.Equal(t, "a=1\n", string(data))
+}
+
+func TestLineResource_Run_PreservesPermissions(t *testing.T) {
+	filePath := writeTestFile(t, "a=1\n", 0o600)
+	line := "b=2"
+
+	l := &lineResource{
+		Path: filePath,
+		Line: &line,
+	}
+
+	_, err := l.Run(t.Context())
+	require.NoError(t, err)
+
+	info, err := os.Stat(filePath)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
+
+func TestLineResource_Run_ErrorMissingFile(t *testing.T) {
+	line := "a=1"
+
+	l := &lineResource{
+		Path: filepath.Join(t.TempDir(), "missing.conf"),
+		Line: &line,
+	}
+
+	_, err := l.Run(t.Context())
+	require.Error(t, err)
+}
+
+func TestLineResource_Run_ErrorInvalidRegexp(t *testing.T) {
+	filePath := writeTestFile(t, "a=1\n", 0o644)
+	line := "a=2"
+	re := `(`
+
+	l := &lineResource{
+		Path:   filePath,
+		Line:   &line,
+		Regexp: &re,
+	}
+
+	_, err := l.Run(t.Context())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid regexp")
+}
+
+func TestLineResource_Run_ErrorPresentWithoutLine(t *testing.T) {
+	filePath := writeTestFile(t, "a=1\n", 0o644)
+	re := `^a=`
+
+	l := &lineResource{
+		Path:   filePath,
+		Regexp: &re,
+	}
+
+	_, err := l.Run(t.Context())
+	require.Error(t, err)
+}
+
+func TestLineResource_Run_WritesDiff(t *testing.T) {
+	filePath := writeTestFile(t, "a=1\nb=2\n", 0o644)
+	line := "b=3"
+	regexp := "^b="
+
+	var out bytes.Buffer
+	l := &lineResource{
+		Path:       filePath,
+		Line:       &line,
+		Regexp:     &regexp,
+		diffOutput: &out,
+	}
+
+	_, err := l.Run(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, "--- "+filePath+"\n+++ "+filePath+"\n@@ -1,2 +1,2 @@\n a=1\n-b=2\n+b=3\n", out.String())
+
+	// nothing is written when unchanged
+	out.Reset()
+	_, err = l.Run(t.Context())
+	require.NoError(t, err)
+	require.Empty(t, out.String())
+}
@@ -9,8 +9,11 @@ import (
 )
 
 type mockServiceNotifier struct {
-	restartCalled []string
-	restartErr    error
+	restartCalled         []string
+	reloadCalled          []string
+	reloadOrRestartCalled []string
+	tryRestartCalled      []string
+	restartErr            error
 }
 
 func newMockServiceNotifier() *mockServiceNotifier {
@@ -27,9 +30,24 @@ func (m *mockServiceNotifier) Restart(ctx context.Context, service string) error
 	return nil
 }
 
+func (m *mockServiceNotifier) TryRestart(ctx context.Context, service string) error {
+	m.tryRestartCalled = append(m.tryRestartCalled, service)
+	return nil
+}
+
+func (m *mockServiceNotifier) Reload(ctx context.Context, service string) error {
+	m.reloadCalled = append(m.reloadCalled, service)
+	return nil
+}
+
+func (m *mockServiceNotifier) ReloadOrRestart(ctx context.Context, service string) error {
+	m.reloadOrRestartCalled = append(m.reloadOrRestartCalled, service)
+	return nil
+}
+
 func TestNotifyServices_EmptyList(t *testing.T) {
 	mock := newMockServiceNotifier()
-	services := []string{}
+	services := []notifyResource{}
 
 	err := notifyServices(t.Context(), mock, services)
 	require.NoError(t, err)
@@ -38,7 +56,7 @@ func TestNotifyServices_EmptyList(t *testing.T) {
 
 func TestNotifyServices_SingleService(t *testing.T) {
 	mock := newMockServiceNotifier()
-	services := []string{"nginx"}
+	services := []notifyResource{{Service: "nginx"}}
 
 	err := notifyServices(t.Context(), mock, services)
 	require.NoError(t, err)
@@ -48,7 +66,7 @@ func TestNotifyServices_SingleService(t *testing.T) {
 
 func TestNotifyServices_MultipleServices(t *testing.T) {
 	mock := newMockServiceNotifier()
-	services := []string{"nginx", "mysql", "redis"}
+	services := []notifyResource{{Service: "nginx"}, {Service: "mysql"}, {Service: "redis"}}
 
 	err := notifyServices(t.Context(), mock, services)
 	require.NoError(t, err)
@@ -58,17 +76,17 @@ func TestNotifyServices_MultipleServices(t *testing.T) {
 
 func TestNotifyServices_PreservesOrder(t *testing.T) {
 	mock := newMockServiceNotifier()
-	services := []string{"service1", "service2", "service3"}
+	services := []notifyResource{{Service: "service1"}, {Service: "service2"}, {Service: "service3"}}
 
 	err := notifyServices(t.Context(), mock, services)
 	require.NoError(t, err)
-	require.Equal(t, services, mock.restartCalled)
+	require.Equal(t, []string{"service1", "service2", "service3"}, mock.restartCalled)
 }
 
 func TestNotifyServices_ErrorOnRestart(t *testing.T) {
 	mock := newMockServiceNotifier()
 	mock.restartErr = errors.New("failed to restart service")
-	services := []string{"nginx"}
+	services := []notifyResource{{Service: "nginx"}}
 
 	err := notifyServices(t.Context(), mock, services)
 	require.Error(t, err)
@@ -79,7 +97,7 @@ func TestNotifyServices_ErrorOnRestart(t *testing.T) {
 func TestNotifyServices_StopsOnFirstError(t *testing.T) {
 	mock := newMockServiceNotifier()
 	mock.restartErr = errors.New("restart failed")
-	services := []string{"nginx", "mysql", "redis"}
+	services := []notifyResource{{Service: "nginx"}, {Service: "mysql"}, {Service: "redis"}}
 
 	err := notifyServices(t.Context(), mock, services)
 	require.Error(t, err)
@@ -92,7 +110,7 @@ func TestNotifyServices_DuplicateServices(t *testing.T) {
 	mock := newMockServiceNotifier()
 	// The caller should deduplicate, but notifyServices will restart each
 	// TODO: should we dedup in notifyServices?
-	services := []string{"nginx", "nginx", "mysql"}
+	services := []notifyResource{{Service: "nginx"}, {Service: "nginx"}, {Service: "mysql"}}
 
 	err := notifyServices(t.Context(), mock, services)
 	require.NoError(t, err)
@@ -103,7 +121,7 @@ func TestNotifyServices_DuplicateServices(t *testing.T) {
 func TestNotifyServices_ErrorContainsServiceInfo(t *testing.T) {
 	mock := newMockServiceNotifier()
 	mock.restartErr = errors.New("connection refused")
-	services := []string{"critical-service"}
+	services := []notifyResource{{Service: "critical-service"}}
 
 	err := notifyServices(t.Context(), mock, services)
 	require.Error(t, err)
@@ -113,16 +131,145 @@ func TestNotifyServices_ErrorContainsServiceInfo(t *testing.T) {
 func TestNotifyServices_MultipleCalls(t *testing.T) {
 	mock := newMockServiceNotifier()
 
-	err := notifyServices(t.Context(), mock, []string{"nginx"})
+	err := notifyServices(t.Context(), mock, []notifyResource{{Service: "nginx"}})
 	require.NoError(t, err)
 
-	err = notifyServices(t.Context(), mock, []string{"mysql"})
+	err = notifyServices(t.Context(), mock, []notifyResource{{Service: "mysql"}})
 	require.NoError(t, err)
 
-	err = notifyServices(t.Context(), mock, []string{"redis"})
+	err = notifyServices(t.Context(), mock, []notifyResource{{Service: "redis"}})
 	require.NoError(t, err)
 
 	// All calls should have been recorded
 	require.Len(t, mock.restartCalled, 3)
 	require.Equal(t, []string{"nginx", "mysql", "redis"}, mock.restartCalled)
 }
+
+func TestNotifyServices_Actions(t *testing.T) {
+	mock := newMockServiceNotifier()
+	services := []notifyResource{
+		{Service: "nginx", Action: "reload"},
+		{Service: "mysql", Action: "reload-or-restart"},
+		{Service: "redis", Action: "restart"},
+		{Service: "apache2"},
+	}
+
+	err := notifyServices(t.Context(), mock, services)
+	require.NoError(t, err)
+	require.Equal(t, []string{"nginx"}, mock.reloadCalled)
+	require.Equal(t, []string{"mysql"}, mock.reloadOrRestartCalled)
+	require.Equal(t, []string{"redis", "apache2"}, mock.restartCalled)
+}
+
+// always reports a change
+type changedRunner struct {
+	Notify notifyResource
+}
+
+func (c *changedRunner) Run(ctx context.Context) (string, error) {
+	return c.Notify.Service, nil
+}
+
+func TestRunRunners_RestartWinsOverReload(t *testing.T) {
+	runners := []runner{
+		&changedRunner{Notify: notifyResource{Service: "nginx", Action: "reload"}},
+		&changedRunner{Notify: notifyResource{Service: "mysql", Action: "reload"}},
+		&changedRunner{Notify: notifyResource{Service: "nginx"}},
+		&changedRunner{Notify: notifyResource{Service: "mysql", Action: "reload-or-restart"}},
+		&changedRunner{Notify: notifyResource{Service: "mysql", Action: "reload"}},
+		&changedRunner{Notify: notifyResource{Service: "mysql", Action: "try-restart"}},
+	}
+
+	services, err := runRunners(t.Context(), runners)
+	require.NoError(t, err)
+	require.Equal(t, []notifyResource{{Service: "nginx"}, {Service: "mysql", Action: "reload-or-restart"}}, services)
+}
+
+func TestRunRunners_SystemdUnitTryRestartsItself(t *testing.T) {
+	unit, _ := newTestSystemdUnit(t, "app.service")
+	unit.Contents = ptr("[Service]\nExecStart=/usr/local/bin/app\n")
+
+	other, _ := newTestSystemdUnit(t, "worker.service")
+	other.Contents = ptr("[Service]\nExecStart=/usr/local/bin/worker\n")
+	other.Notify = notifyResource{Action: "restart"}
+
+	runners := []runner{
+		unit,
+		other,
+		// an explicit restart of the unit wins
+		&changedRunner{Notify: notifyResource{Service: "worker.service", Action: "try-restart"}},
+	}
+
+	services, err := runRunners(t.Context(), runners)
+	require.NoError(t, err)
+	require.Equal(t, []notifyResource{
+		{Service: "app.service", Action: "try-restart"},
+		{Service: "worker.service", Action: "restart"},
+	}, services)
+
+	mock := newMockServiceNotifier()
+	require.NoError(t, notifyServices(t.Context(), mock, services))
+	require.Equal(t, []string{"app.service"}, mock.tryRestartCalled)
+	require.Equal(t, []string{"worker.service"}, mock.restartCalled)
+}
+
+func TestRunRunners_SkipsServicesStartedAfterNotify(t *testing.T) {
+	mock := newMockServiceManager()
+	mock.services["apache2"] = true
+
+	runners := []runner{
+		&changedRunner{Notify: notifyResource{Service: "nginx"}},
+		&changedRunner{Notify: notifyResource{Service: "apache2"}},
+		// started after the change, so already has it
+		&serviceResource{Name: "nginx", State: "running", manager: mock},
+		// already running, so still needs a restart
+		&serviceResource{Name: "apache2", State: "running", manager: mock},
+		&serviceResource{Name: "mysql", State: "running", manager: mock},
+		// changed after mysql was started
+		&changedRunner{Notify: notifyResource{Service: "mysql"}},
+	}
+
+	services, err := runRunners(t.Context(), runners)
+	require.NoError(t, err)
+	require.Equal(t, []notifyResource{{Service: "apache2"}, {Service: "mysql"}}, services)
+	require.Equal(t, []string{"nginx", "mysql"}, mock.startCalled)
+}
+
+func TestRunRunners_SkipsUnitStartedAfterChange(t *testing.T) {
+	mock := newMockServiceManager()
+
+	unit, _ := newTestSystemdUnit(t, "app.service")
+	unit.Contents = ptr("[Service]\nExecStart=/usr/local/bin/app\n")
+
+	runners := []runner{
+		unit,
+		&changedRunner{Notify: notifyResource{Service: "app", Action: "reload"}},
+		// the unit notifies itself as app.service, which is the same service
+		&serviceResource{Name: "app", State: "running", manager: mock},
+	}
+
+	// both notifications are for the same service
+	services, err := runRunners(t.Context(), runners[:2])
+	require.NoError(t, err)
+	require.Equal(t, []notifyResource{{Service: "app.service", Action: "try-restart"}}, services)
+
+	unit.Contents = ptr("[Service]\nExecStart=/usr/local/bin/app --verbose\n")
+	services, err = runRunners(t.Context(), runners)
+	require.NoError(t, err)
+	require.Empty(t, services)
+	require.Equal(t, []string{"app"}, mock.startCalled)
+}
+
+func TestRunRunners_SkipsServicesStoppedAfterNotify(t *testing.T) {
+	mock := newMockServiceManager()
+	mock.services["nginx"] = true
+
+	runners := []runner{
+		&changedRunner{Notify: notifyResource{Service: "nginx"}},
+		&serviceResource{Name: "nginx", State: "stopped", manager: mock},
+	}
+
+	services, err := runRunners(t.Context(), runners)
+	require.NoError(t, err)
+	require.Empty(t, services)
+}
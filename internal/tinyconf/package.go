@@ -1,24 +1,213 @@
 package tinyconf
 
 import (
+	"cmp"
 	"context"
 	"fmt"
 	"log/slog"
 	"os/exec"
+	"strconv"
+	"strings"
+	"time"
 )
 
-// TODO: support version
 type packageResource struct {
-	Name    string         `json:"name" validate:"required"`
-	State   string         `json:"state" validate:"required,oneof=installed absent"`
-	Notify  notifyResource `json:"notify"`
+	Name  string `json:"name" validate:"required"`
+	State string `json:"state" validate:"required,oneof=installed latest absent"`
+	// exact version, or a prefix when it ends with *
+	Version *string `json:"version" validate:"excluded_unless=State installed"`
+	// hold the package at its installed version
+	Hold *bool `json:"hold"`
+	// apt, dnf, yum, apk, pacman, zypper, pip, npm, or gem. the system package manager
+	// is detected from /etc/os-release when not set
+	Provider *string `json:"provider" validate:"omitempty,oneof=apt dnf yum apk pacman zypper pip npm gem"`
+	// path to a virtualenv for pip packages. uses the system python3 when not set
+	Virtualenv *string `json:"virtualenv" validate:"excluded_unless=Provider pip"`
+	// install prefix for npm packages. uses the npm global prefix when not set
+	Prefix *string `json:"prefix" validate:"excluded_unless=Provider npm"`
+	// always, never, or the max age of the cache, such as 24h.
+	// overrides the global update_cache
+	UpdateCache *string `json:"update_cache" validate:"omitempty,update_cache"`
+	// debconf answers set before installing. apt only
+	Preseed []debconfSelection `json:"preseed" validate:"dive"`
+	Notify  notifyResource     `json:"notify"`
 	manager packageManager
+	// global update_cache
+	defaultUpdateCache string
+	// set when a packageBatch installed or removed the package
+	batchChanged bool
 }
 
 type packageManager interface {
 	IsInstalled(context.Context, string) (bool, error)
-	Install(context.Context, string) error
-	Uninstall(context.Context, string) error
+	// install or uninstall all of the packages in one transaction
+	Install(context.Context, ...string) error
+	Uninstall(context.Context, ...string) error
+	// returns an empty string if the package is not installed
+	InstalledVersion(context.Context, string) (string, error)
+	// returns the version that would be installed, or an empty string if none is available
+	CandidateVersion(context.Context, string) (string, error)
+	// installs, upgrades, or downgrades to the version
+	InstallVersion(context.Context, string, string) error
+	Upgrade(context.Context, string) error
+	IsHeld(context.Context, string) (bool, error)
+	SetHold(context.Context, string, bool) error
+	// updates the package cache if needed by the policy.
+	// the cache is updated at most once per run
+	UpdateCache(context.Context, string) error
+}
+
+// the update_cache policy for the package
+func (s *packageResource) updateCachePolicy() string {
+	if s.UpdateCache != nil {
+		return *s.UpdateCache
+	}
+
+	if s.defaultUpdateCache != "" {
+		return s.defaultUpdateCache
+	}
+
+	return "always"
+}
+
+// returns whether the cache should be updated. age is only called
+// when the policy is a max age
+func cacheNeedsUpdate(policy string, age func() (time.Duration, error)) (bool, error) {
+	switch policy {
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	}
+
+	maxAge, err := time.ParseDuration(policy)
+	if err != nil {
+		return false, fmt.Errorf("invalid update_cache %s %w", policy, err)
+	}
+
+	current, err := age()
+	if err != nil {
+		return false, err
+	}
+
+	return current > maxAge, nil
+}
+
+// used to validate update_cache
+func validUpdateCache(policy string) bool {
+	_, err := cacheNeedsUpdate(policy, func() (time.Duration, error) { return 0, nil })
+	return err == nil
+}
+
+// versions ending with * are a prefix match
+func versionMatches(installed string, version string) bool {
+	if prefix, ok := strings.CutSuffix(version, "*"); ok {
+		return strings.HasPrefix(installed, prefix)
+	}
+
+	return installed == version
+}
+
+// compares versions using the dpkg algorithm, returning -1, 0, or 1. this is close
+// enough for the other package managers, which use the same epoch:version-release form.
+func compareVersions(a string, b string) int {
+	epochA, restA := splitVersionEpoch(a)
+	epochB, restB := splitVersionEpoch(b)
+	if epochA != epochB {
+		return cmp.Compare(epochA, epochB)
+	}
+
+	upstreamA, revisionA := splitVersionRevision(restA)
+	upstreamB, revisionB := splitVersionRevision(restB)
+	if c := compareVersionPart(upstreamA, upstreamB); c != 0 {
+		return c
+	}
+
+	return compareVersionPart(revisionA, revisionB)
+}
+
+func splitVersionEpoch(version string) (int, string) {
+	epoch, rest, ok := strings.Cut(version, ":")
+	if !ok {
+		return 0, version
+	}
+
+	n, err := strconv.Atoi(epoch)
+	if err != nil {
+		return 0, version
+	}
+
+	return n, rest
+}
+
+func splitVersionRevision(version string) (string, string) {
+	if i := strings.LastIndex(version, "-"); i != -1 {
+		return version[:i], version[i+1:]
+	}
+
+	return version, ""
+}
+
+// ~ sorts before everything, even the end of the string, and letters sort before other characters
+func versionCharOrder(c byte) int {
+	switch {
+	case c == '~':
+		return -1
+	case c >= '0' && c <= '9':
+		return 0
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		return int(c)
+	default:
+		return int(c) + 256
+	}
+}
+
+func isVersionDigit(s string) bool {
+	return s != "" && s[0] >= '0' && s[0] <= '9'
+}
+
+// compares alternating non digit and digit parts
+func compareVersionPart(a string, b string) int {
+	for a != "" || b != "" {
+		for (a != "" && !isVersionDigit(a)) || (b != "" && !isVersionDigit(b)) {
+			// the end of the string sorts as 0, the same as a digit
+			var ac, bc int
+			if a != "" {
+				ac = versionCharOrder(a[0])
+			}
+			if b != "" {
+				bc = versionCharOrder(b[0])
+			}
+			if ac != bc {
+				return cmp.Compare(ac, bc)
+			}
+			// equal orders are the same non digit, so neither is at the end
+			a, b = a[1:], b[1:]
+		}
+
+		a = strings.TrimLeft(a, "0")
+		b = strings.TrimLeft(b, "0")
+
+		firstDiff := 0
+		for isVersionDigit(a) && isVersionDigit(b) {
+			if firstDiff == 0 {
+				firstDiff = cmp.Compare(a[0], b[0])
+			}
+			a, b = a[1:], b[1:]
+		}
+
+		if isVersionDigit(a) {
+			return 1
+		}
+		if isVersionDigit(b) {
+			return -1
+		}
+		if firstDiff != 0 {
+			return firstDiff
+		}
+	}
+
+	return 0
 }
 
 func (s *packageResource) Run(ctx context.Context) (string, error) {
@@ -27,6 +216,9 @@ func (s *packageResource) Run(ctx context.Context) (string, error) {
 	}
 
 	tasks := []func() (bool, error){
+		func() (bool, error) {
+			return s.preseed(ctx)
+		},
 		func() (bool, error) {
 			isInstalled, err := s.manager.IsInstalled(ctx, s.Name)
 			if err != nil {
@@ -35,12 +227,31 @@ func (s *packageResource) Run(ctx context.Context) (string, error) {
 
 			switch s.State {
 			case "installed":
+				if s.Version != nil {
+					return s.ensureVersion(ctx, *s.Version)
+				}
+
 				if isInstalled {
 					return false, nil
 				}
 
+				if err := s.manager.UpdateCache(ctx, s.updateCachePolicy()); err != nil {
+					return false, err
+				}
+
 				slog.Info("installing package", "name", s.Name)
 				return true, s.manager.Install(ctx, s.Name)
+			case "latest":
+				if err := s.manager.UpdateCache(ctx, s.updateCachePolicy()); err != nil {
+					return false, err
+				}
+
+				if !isInstalled {
+					slog.Info("installing package", "name", s.Name)
+					return true, s.manager.Install(ctx, s.Name)
+				}
+
+				return s.upgrade(ctx)
 			case "absent":
 				if !isInstalled {
 					return false, nil
@@ -52,6 +263,23 @@ func (s *packageResource) Run(ctx context.Context) (string, error) {
 				return false, fmt.Errorf("unexpected package state %s", s.State)
 			}
 		},
+		func() (bool, error) {
+			if s.Hold == nil || s.State == "absent" {
+				return false, nil
+			}
+
+			isHeld, err := s.manager.IsHeld(ctx, s.Name)
+			if err != nil {
+				return false, fmt.Errorf("failed to get hold status for %s %w", s.Name, err)
+			}
+
+			if isHeld == *s.Hold {
+				return false, nil
+			}
+
+			slog.Info("changing package hold", "name", s.Name, "hold", *s.Hold)
+			return true, s.manager.SetHold(ctx, s.Name, *s.Hold)
+		},
 	}
 
 	// use runTasks in case we add some debugging/logging/etc
@@ -60,14 +288,150 @@ func (s *packageResource) Run(ctx context.Context) (string, error) {
 		return "", err
 	}
 
-	if changed {
+	if changed || s.batchChanged {
+		s.batchChanged = false
 		return s.Notify.Service, nil
 	}
 
 	return "", nil
 }
 
-type aptPackageManager struct{}
+// installs and removes consecutive packages in one call to the package manager.
+// it runs before the packages, which then only need to handle versions and holds.
+type packageBatch struct {
+	packages []*packageResource
+}
+
+func (b *packageBatch) Run(ctx context.Context) (string, error) {
+	manager := b.packages[0].manager
+	if isNil(manager) {
+		manager = &aptPackageManager{}
+	}
+
+	var install, uninstall []*packageResource
+	for _, p := range b.packages {
+		// versions and upgrades are handled by the package
+		if p.Version != nil || p.State == "latest" {
+			continue
+		}
+
+		isInstalled, err := manager.IsInstalled(ctx, p.Name)
+		if err != nil {
+			return "", fmt.Errorf("failed to get status for %s %w", p.Name, err)
+		}
+
+		switch {
+		case p.State == "installed" && !isInstalled:
+			install = append(install, p)
+		case p.State == "absent" && isInstalled:
+			uninstall = append(uninstall, p)
+		}
+	}
+
+	for _, p := range install {
+		if err := manager.UpdateCache(ctx, p.updateCachePolicy()); err != nil {
+			return "", err
+		}
+
+		if _, err := p.preseed(ctx); err != nil {
+			return "", err
+		}
+	}
+
+	if len(install) > 0 {
+		names := packageNames(install)
+		slog.Info("installing packages", "names", names)
+		if err := manager.Install(ctx, names...); err != nil {
+			return "", err
+		}
+	}
+
+	if len(uninstall) > 0 {
+		names := packageNames(uninstall)
+		slog.Info("uninstalling packages", "names", names)
+		if err := manager.Uninstall(ctx, names...); err != nil {
+			return "", err
+		}
+	}
+
+	for _, p := range append(install, uninstall...) {
+		p.batchChanged = true
+	}
+
+	// the packages send their own notifications
+	return "", nil
+}
+
+func packageNames(packages []*packageResource) []string {
+	names := make([]string, 0, len(packages))
+	for _, p := range packages {
+		names = append(names, p.Name)
+	}
+
+	return names
+}
+
+// sets the debconf answers before the package is installed
+func (s *packageResource) preseed(ctx context.Context) (bool, error) {
+	if len(s.Preseed) == 0 || s.State == "absent" {
+		return false, nil
+	}
+
+	preseeder, ok := s.manager.(debconfPreseeder)
+	if !ok {
+		return false, fmt.Errorf("package %s uses preseed, which is only supported by apt", s.Name)
+	}
+
+	return preseeder.Preseed(ctx, s.Name, s.Preseed)
+}
+
+func (s *packageResource) ensureVersion(ctx context.Context, version string) (bool, error) {
+	installed, err := s.manager.InstalledVersion(ctx, s.Name)
+	if err != nil {
+		return false, fmt.Errorf("failed to get installed version for %s %w", s.Name, err)
+	}
+
+	if installed != "" && versionMatches(installed, version) {
+		return false, nil
+	}
+
+	if err := s.manager.UpdateCache(ctx, s.updateCachePolicy()); err != nil {
+		return false, err
+	}
+
+	slog.Info("installing package version", "name", s.Name, "version", version, "installed", installed)
+	return true, s.manager.InstallVersion(ctx, s.Name, version)
+}
+
+func (s *packageResource) upgrade(ctx context.Context) (bool, error) {
+	installed, err := s.manager.InstalledVersion(ctx, s.Name)
+	if err != nil {
+		return false, fmt.Errorf("failed to get installed version for %s %w", s.Name, err)
+	}
+
+	candidate, err := s.manager.CandidateVersion(ctx, s.Name)
+	if err != nil {
+		return false, fmt.Errorf("failed to get candidate version for %s %w", s.Name, err)
+	}
+
+	// the installed version may be newer, such as from backports or a local build
+	if candidate == "" || compareVersions(candidate, installed) <= 0 {
+		return false, nil
+	}
+
+	slog.Info("upgrading package", "name", s.Name, "from", installed, "to", candidate)
+	return true, s.manager.Upgrade(ctx, s.Name)
+}
+
+type aptPackageManager struct {
+	cacheUpdated bool
+	// set when a repository changes
+	cacheStale bool
+}
+
+func (a *aptPackageManager) InvalidateCache() {
+	a.cacheStale = true
+}
 
 // TODO: clean this up. It got messy as I ran into some unexpected results
 // while testing installing and uninstalling multiple times
@@ -92,39 +456,157 @@ func (a *aptPackageManager) IsInstalled(ctx context.Context, packageName string)
 	return status == "install ok installed", nil
 }
 
-func (a *aptPackageManager) Install(ctx context.Context, packageName string) error {
-	// TODO: have an option whether to run update or not? for now
-	// always run it when we have to install something
-	// could check timestamp of /var/lib/apt/periodic/update-success-stamp
-
-	updateCmd := exec.CommandContext(ctx, "apt", "update")
-	updateCmd.Env = []string{"DEBIAN_FRONTEND=noninteractive"}
-	// for now, we won't consider this fatal
-	_ = updateCmd.Run()
+func (a *aptPackageManager) Install(ctx context.Context, packageNames ...string) error {
+	names := strings.Join(packageNames, " ")
 
-	cmd := exec.CommandContext(ctx, "apt", "install", "-y", packageName)
+	cmd := exec.CommandContext(ctx, "apt", append([]string{"install", "-y"}, packageNames...)...)
 	cmd.Env = []string{"DEBIAN_FRONTEND=noninteractive"}
 	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to install package %s (output: %s): %w", packageName, string(output), err)
+		return fmt.Errorf("failed to install package %s (output: %s): %w", names, string(output), err)
 	}
 
 	// Fix any broken dependencies - meta-packages like apache2 can leave deps in a broken state
 	fixCmd := exec.CommandContext(ctx, "apt", "install", "-f", "-y")
 	fixCmd.Env = []string{"DEBIAN_FRONTEND=noninteractive"}
 	if output, err := fixCmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to fix dependencies for %s (output: %s): %w", packageName, string(output), err)
+		return fmt.Errorf("failed to fix dependencies for %s (output: %s): %w", names, string(output), err)
 	}
 
 	return nil
 }
 
-func (a *aptPackageManager) Uninstall(ctx context.Context, packageName string) error {
+func (a *aptPackageManager) Uninstall(ctx context.Context, packageNames ...string) error {
 	// purge??
-	cmd := exec.CommandContext(ctx, "apt", "remove", "-y", packageName)
+	cmd := exec.CommandContext(ctx, "apt", append([]string{"remove", "-y"}, packageNames...)...)
+	cmd.Env = []string{"DEBIAN_FRONTEND=noninteractive"}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to uninstall package %s (output: %s): %w", strings.Join(packageNames, " "), string(output), err)
+	}
+
+	return nil
+}
+
+func (a *aptPackageManager) InstalledVersion(ctx context.Context, packageName string) (string, error) {
+	cmd := exec.CommandContext(ctx, "dpkg-query", "-W", "-f=${Status}\t${Version}", packageName)
+	output, err := cmd.Output()
+	if err != nil {
+		// exit code 1 means package not installed
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to check package %s version: %w", packageName, err)
+	}
+
+	status, version, _ := strings.Cut(string(output), "\t")
+	if status != "install ok installed" {
+		return "", nil
+	}
+
+	return strings.TrimSpace(version), nil
+}
+
+func (a *aptPackageManager) CandidateVersion(ctx context.Context, packageName string) (string, error) {
+	cmd := exec.CommandContext(ctx, "apt-cache", "policy", packageName)
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to get policy for package %s: %w", packageName, err)
+	}
+
+	return parseAptCandidate(string(output)), nil
+}
+
+// parses the candidate version from apt-cache policy output
+func parseAptCandidate(output string) string {
+	for line := range strings.Lines(output) {
+		if candidate, ok := strings.CutPrefix(strings.TrimSpace(line), "Candidate:"); ok {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "(none)" {
+				return ""
+			}
+			return candidate
+		}
+	}
+
+	return ""
+}
+
+func (a *aptPackageManager) InstallVersion(ctx context.Context, packageName string, version string) error {
+	// allow changing held packages, as the version is pinned in the config
+	cmd := exec.CommandContext(ctx, "apt", "install", "-y", "--allow-downgrades", "--allow-change-held-packages", packageName+"="+version)
+	cmd.Env = []string{"DEBIAN_FRONTEND=noninteractive"}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to install package %s version %s (output: %s): %w", packageName, version, string(output), err)
+	}
+
+	return nil
+}
+
+func (a *aptPackageManager) Upgrade(ctx context.Context, packageName string) error {
+	// allow changing held packages, as latest is requested in the config
+	cmd := exec.CommandContext(ctx, "apt", "install", "-y", "--only-upgrade", "--allow-change-held-packages", packageName)
+	cmd.Env = []string{"DEBIAN_FRONTEND=noninteractive"}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to upgrade package %s (output: %s): %w", packageName, string(output), err)
+	}
+
+	return nil
+}
+
+func (a *aptPackageManager) IsHeld(ctx context.Context, packageName string) (bool, error) {
+	cmd := exec.CommandContext(ctx, "apt-mark", "showhold", packageName)
+	output, err := cmd.Output()
+	if err != nil {
+		return false, fmt.Errorf("failed to check hold for package %s: %w", packageName, err)
+	}
+
+	return strings.TrimSpace(string(output)) == packageName, nil
+}
+
+func (a *aptPackageManager) SetHold(ctx context.Context, packageName string, hold bool) error {
+	action := "unhold"
+	if hold {
+		action = "hold"
+	}
+
+	cmd := exec.CommandContext(ctx, "apt-mark", action, packageName)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to %s package %s (output: %s): %w", action, packageName, string(output), err)
+	}
+
+	return nil
+}
+
+func (a *aptPackageManager) UpdateCache(ctx context.Context, policy string) error {
+	if a.cacheUpdated && !a.cacheStale {
+		return nil
+	}
+
+	needed, err := cacheNeedsUpdate(policy, aptCacheAge)
+	if err != nil {
+		return err
+	}
+
+	// a stale cache is updated regardless of its age
+	if !needed && !(a.cacheStale && policy != "never") {
+		return nil
+	}
+
+	slog.Info("updating apt cache")
+	cmd := exec.CommandContext(ctx, "apt-get", "update")
 	cmd.Env = []string{"DEBIAN_FRONTEND=noninteractive"}
 	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to uninstall package %s (output: %s): %w", packageName, string(output), err)
+		return fmt.Errorf("failed to update apt cache (output: %s): %w", string(output), err)
 	}
 
+	a.cacheUpdated = true
+	a.cacheStale = false
+
 	return nil
 }
+
+// returns the time since the apt cache was last updated
+func aptCacheAge() (time.Duration, error) {
+	// the stamp is only written by apt when update succeeds, but
+	// requires the apt periodic hooks to be installed
+	return pathAge("/var/lib/apt/periodic/update-success-stamp", "/var/lib/apt/lists")
+}
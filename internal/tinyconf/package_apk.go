@@ -0,0 +1,164 @@
+package tinyconf
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// apkPackageManager manages packages using apk on Alpine.
+// holds are constraints in the world file.
+type apkPackageManager struct {
+	executor commandExecutor
+	// defaults to /etc/apk/world
+	worldFile    string
+	cacheUpdated bool
+}
+
+func (a *apkPackageManager) run(ctx context.Context, action string, args ...string) ([]byte, error) {
+	output, err := a.executor.Run(ctx, "apk", args...)
+	if err != nil {
+		return output, fmt.Errorf("failed to %s (output: %s): %w", action, string(output), err)
+	}
+
+	return output, nil
+}
+
+// returns the version from apk list output, such as
+// nginx-1.24.0-r7 x86_64 {nginx} (BSD-2-Clause) [installed]
+func parseApkList(output string, packageName string) string {
+	for line := range strings.Lines(output) {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if version, ok := trimPackageName(fields[0], packageName); ok {
+			return version
+		}
+	}
+
+	return ""
+}
+
+func (a *apkPackageManager) IsInstalled(ctx context.Context, packageName string) (bool, error) {
+	version, err := a.InstalledVersion(ctx, packageName)
+	return version != "", err
+}
+
+func (a *apkPackageManager) InstalledVersion(ctx context.Context, packageName string) (string, error) {
+	output, err := a.run(ctx, "check package "+packageName+" version", "list", "--installed", packageName)
+	if err != nil {
+		return "", err
+	}
+
+	return parseApkList(string(output), packageName), nil
+}
+
+func (a *apkPackageManager) CandidateVersion(ctx context.Context, packageName string) (string, error) {
+	// lists the newest available version
+	output, err := a.run(ctx, "get candidate version for package "+packageName, "list", packageName)
+	if err != nil {
+		return "", err
+	}
+
+	return parseApkList(string(output), packageName), nil
+}
+
+func (a *apkPackageManager) Install(ctx context.Context, packageNames ...string) error {
+	_, err := a.run(ctx, "install package "+strings.Join(packageNames, " "),
+		append([]string{"add"}, packageNames...)...)
+	return err
+}
+
+// returns the world constraint for a version. versions ending with * are a fuzzy match
+func apkConstraint(packageName string, version string) string {
+	if prefix, ok := strings.CutSuffix(version, "*"); ok {
+		return packageName + "~" + prefix
+	}
+
+	return packageName + "=" + version
+}
+
+func (a *apkPackageManager) InstallVersion(ctx context.Context, packageName string, version string) error {
+	_, err := a.run(ctx, fmt.Sprintf("install package %s version %s", packageName, version),
+		"add", apkConstraint(packageName, version))
+	return err
+}
+
+func (a *apkPackageManager) Upgrade(ctx context.Context, packageName string) error {
+	_, err := a.run(ctx, "upgrade package "+packageName, "add", "--upgrade", packageName)
+	return err
+}
+
+func (a *apkPackageManager) Uninstall(ctx context.Context, packageNames ...string) error {
+	_, err := a.run(ctx, "uninstall package "+strings.Join(packageNames, " "),
+		append([]string{"del"}, packageNames...)...)
+	return err
+}
+
+func (a *apkPackageManager) IsHeld(ctx context.Context, packageName string) (bool, error) {
+	worldFile := a.worldFile
+	if worldFile == "" {
+		worldFile = "/etc/apk/world"
+	}
+
+	data, err := os.ReadFile(worldFile)
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s %w", worldFile, err)
+	}
+
+	// a held package has a version constraint
+	for _, entry := range strings.Fields(string(data)) {
+		if rest, ok := strings.CutPrefix(entry, packageName); ok && strings.ContainsAny(rest[:min(1, len(rest))], "=~<>") {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (a *apkPackageManager) SetHold(ctx context.Context, packageName string, hold bool) error {
+	if !hold {
+		// replaces the constraint in the world file
+		_, err := a.run(ctx, "unhold package "+packageName, "add", packageName)
+		return err
+	}
+
+	version, err := a.InstalledVersion(ctx, packageName)
+	if err != nil {
+		return err
+	}
+
+	if version == "" {
+		return fmt.Errorf("failed to hold package %s: not installed", packageName)
+	}
+
+	_, err = a.run(ctx, "hold package "+packageName, "add", apkConstraint(packageName, version))
+	return err
+}
+
+func (a *apkPackageManager) UpdateCache(ctx context.Context, policy string) error {
+	if a.cacheUpdated {
+		return nil
+	}
+
+	needed, err := cacheNeedsUpdate(policy, func() (time.Duration, error) {
+		return pathAge("/var/cache/apk")
+	})
+	if err != nil || !needed {
+		return err
+	}
+
+	slog.Info("updating package cache", "provider", "apk")
+	if _, err := a.run(ctx, "update package cache", "update"); err != nil {
+		return err
+	}
+
+	a.cacheUpdated = true
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package tinyconf
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestApkPackageManager_Versions(t *testing.T) {
+	executor := newFakeExecutor()
+	executor.results["apk list --installed nginx"] = fakeResult{output: "nginx-1.24.0-r7 x86_64 {nginx} (BSD-2-Clause) [installed]\n"}
+	executor.results["apk list nginx"] = fakeResult{output: "nginx-1.26.0-r0 x86_64 {nginx} (BSD-2-Clause) [upgradable from: nginx-1.24.0-r7]\n"}
+
+	a := &apkPackageManager{executor: executor}
+	ctx := t.Context()
+
+	version, err := a.InstalledVersion(ctx, "nginx")
+	require.NoError(t, err)
+	require.Equal(t, "1.24.0-r7", version)
+
+	version, err = a.CandidateVersion(ctx, "nginx")
+	require.NoError(t, err)
+	require.Equal(t, "1.26.0-r0", version)
+
+	installed, err := a.IsInstalled(ctx, "curl")
+	require.NoError(t, err)
+	require.False(t, installed)
+}
+
+func TestApkPackageManager_Commands(t *testing.T) {
+	executor := newFakeExecutor()
+	executor.results["apk list --installed nginx"] = fakeResult{output: "nginx-1.24.0-r7 x86_64 {nginx} (BSD-2-Clause) [installed]\n"}
+
+	a := &apkPackageManager{executor: executor}
+	ctx := t.Context()
+
+	require.NoError(t, a.Install(ctx, "nginx", "curl"))
+	require.NoError(t, a.InstallVersion(ctx, "nginx", "1.24.0-r7"))
+	require.NoError(t, a.InstallVersion(ctx, "nginx", "1.24*"))
+	require.NoError(t, a.Upgrade(ctx, "nginx"))
+	require.NoError(t, a.Uninstall(ctx, "curl"))
+	require.NoError(t, a.SetHold(ctx, "nginx", true))
+	require.NoError(t, a.SetHold(ctx, "nginx", false))
+	require.NoError(t, a.UpdateCache(ctx, "always"))
+	require.NoError(t, a.UpdateCache(ctx, "always"))
+
+	require.Equal(t, []string{
+		"apk add nginx curl",
+		"apk add nginx=1.24.0-r7",
+		"apk add nginx~1.24",
+		"apk add --upgrade nginx",
+		"apk del curl",
+		"apk list --installed nginx",
+		"apk add nginx=1.24.0-r7",
+		"apk add nginx",
+		"apk update",
+	}, executor.calls)
+}
+
+func TestApkPackageManager_IsHeld(t *testing.T) {
+	world := filepath.Join(t.TempDir(), "world")
+	require.NoError(t, os.WriteFile(world, []byte("alpine-base\nnginx=1.24.0-r7\nnginx-mod-stream\n"), 0o644))
+
+	a := &apkPackageManager{executor: newFakeExecutor(), worldFile: world}
+	ctx := t.Context()
+
+	held, err := a.IsHeld(ctx, "nginx")
+	require.NoError(t, err)
+	require.True(t, held)
+
+	held, err = a.IsHeld(ctx, "nginx-mod-stream")
+	require.NoError(t, err)
+	require.False(t, held)
+
+	held, err = a.IsHeld(ctx, "alpine")
+	require.NoError(t, err)
+	require.False(t, held)
+}
@@ -0,0 +1,123 @@
+package tinyconf
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// dnfPackageManager manages packages using dnf or yum. holds require the versionlock plugin.
+type dnfPackageManager struct {
+	// dnf or yum
+	binary       string
+	executor     commandExecutor
+	cacheUpdated bool
+}
+
+func (d *dnfPackageManager) run(ctx context.Context, action string, args ...string) ([]byte, error) {
+	output, err := d.executor.Run(ctx, d.binary, args...)
+	if err != nil {
+		return output, fmt.Errorf("failed to %s (output: %s): %w", action, string(output), err)
+	}
+
+	return output, nil
+}
+
+func (d *dnfPackageManager) IsInstalled(ctx context.Context, packageName string) (bool, error) {
+	version, err := d.InstalledVersion(ctx, packageName)
+	return version != "", err
+}
+
+func (d *dnfPackageManager) InstalledVersion(ctx context.Context, packageName string) (string, error) {
+	return rpmInstalledVersion(ctx, d.executor, packageName)
+}
+
+func (d *dnfPackageManager) CandidateVersion(ctx context.Context, packageName string) (string, error) {
+	output, err := d.run(ctx, "get candidate version for package "+packageName,
+		"repoquery", "-q", "--latest-limit", "1", "--qf", "%{version}-%{release}\n", packageName)
+	if err != nil {
+		return "", err
+	}
+
+	// the last line is the newest version
+	lines := strings.Fields(string(output))
+	if len(lines) == 0 {
+		return "", nil
+	}
+
+	return lines[len(lines)-1], nil
+}
+
+func (d *dnfPackageManager) Install(ctx context.Context, packageNames ...string) error {
+	_, err := d.run(ctx, "install package "+strings.Join(packageNames, " "),
+		append([]string{"install", "-y"}, packageNames...)...)
+	return err
+}
+
+func (d *dnfPackageManager) InstallVersion(ctx context.Context, packageName string, version string) error {
+	// dnf handles downgrades with install. * is passed along as a glob
+	_, err := d.run(ctx, fmt.Sprintf("install package %s version %s", packageName, version),
+		"install", "-y", packageName+"-"+version)
+	return err
+}
+
+func (d *dnfPackageManager) Upgrade(ctx context.Context, packageName string) error {
+	_, err := d.run(ctx, "upgrade package "+packageName, "upgrade", "-y", packageName)
+	return err
+}
+
+func (d *dnfPackageManager) Uninstall(ctx context.Context, packageNames ...string) error {
+	_, err := d.run(ctx, "uninstall package "+strings.Join(packageNames, " "),
+		append([]string{"remove", "-y"}, packageNames...)...)
+	return err
+}
+
+func (d *dnfPackageManager) IsHeld(ctx context.Context, packageName string) (bool, error) {
+	output, err := d.run(ctx, "list version locks", "versionlock", "list")
+	if err != nil {
+		return false, err
+	}
+
+	// entries are name-[epoch:]version-release.*
+	for _, entry := range strings.Fields(string(output)) {
+		if _, ok := trimPackageName(entry, packageName); ok {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (d *dnfPackageManager) SetHold(ctx context.Context, packageName string, hold bool) error {
+	action := "delete"
+	if hold {
+		action = "add"
+	}
+
+	_, err := d.run(ctx, fmt.Sprintf("%s version lock for package %s", action, packageName), "versionlock", action, packageName)
+	return err
+}
+
+func (d *dnfPackageManager) UpdateCache(ctx context.Context, policy string) error {
+	if d.cacheUpdated {
+		return nil
+	}
+
+	needed, err := cacheNeedsUpdate(policy, func() (time.Duration, error) {
+		return pathAge("/var/cache/" + d.binary)
+	})
+	if err != nil || !needed {
+		return err
+	}
+
+	slog.Info("updating package cache", "provider", d.binary)
+	if _, err := d.run(ctx, "update package cache", "makecache"); err != nil {
+		return err
+	}
+
+	d.cacheUpdated = true
+
+	return nil
+}
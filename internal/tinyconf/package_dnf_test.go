@@ -0,0 +1,93 @@
+package tinyconf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDnfPackageManager_InstalledVersion(t *testing.T) {
+	executor := newFakeExecutor()
+	executor.results["rpm -q --qf %{VERSION}-%{RELEASE} nginx"] = fakeResult{output: "1.24.0-1.el9"}
+	executor.results["rpm -q --qf %{VERSION}-%{RELEASE} curl"] = fakeResult{
+		output: "package curl is not installed",
+		err:    fakeExitError(1),
+	}
+
+	d := &dnfPackageManager{binary: "dnf", executor: executor}
+	ctx := t.Context()
+
+	version, err := d.InstalledVersion(ctx, "nginx")
+	require.NoError(t, err)
+	require.Equal(t, "1.24.0-1.el9", version)
+
+	installed, err := d.IsInstalled(ctx, "curl")
+	require.NoError(t, err)
+	require.False(t, installed)
+}
+
+func TestDnfPackageManager_Commands(t *testing.T) {
+	executor := newFakeExecutor()
+	d := &dnfPackageManager{binary: "yum", executor: executor}
+	ctx := t.Context()
+
+	require.NoError(t, d.Install(ctx, "nginx", "curl"))
+	require.NoError(t, d.InstallVersion(ctx, "nginx", "1.24*"))
+	require.NoError(t, d.Upgrade(ctx, "nginx"))
+	require.NoError(t, d.Uninstall(ctx, "curl"))
+	require.NoError(t, d.SetHold(ctx, "nginx", true))
+	require.NoError(t, d.SetHold(ctx, "nginx", false))
+	require.NoError(t, d.UpdateCache(ctx, "always"))
+	require.NoError(t, d.UpdateCache(ctx, "always"))
+
+	require.Equal(t, []string{
+		"yum install -y nginx curl",
+		"yum install -y nginx-1.24*",
+		"yum upgrade -y nginx",
+		"yum remove -y curl",
+		"yum versionlock add nginx",
+		"yum versionlock delete nginx",
+		"yum makecache",
+	}, executor.calls)
+}
+
+func TestDnfPackageManager_CandidateVersion(t *testing.T) {
+	executor := newFakeExecutor()
+	executor.results["dnf repoquery -q --latest-limit 1 --qf %{version}-%{release}\n nginx"] = fakeResult{output: "1.26.0-1.el9\n"}
+
+	d := &dnfPackageManager{binary: "dnf", executor: executor}
+
+	version, err := d.CandidateVersion(t.Context(), "nginx")
+	require.NoError(t, err)
+	require.Equal(t, "1.26.0-1.el9", version)
+}
+
+func TestDnfPackageManager_IsHeld(t *testing.T) {
+	executor := newFakeExecutor()
+	executor.results["dnf versionlock list"] = fakeResult{output: "nginx-1:1.24.0-1.el9.*\nnginx-mod-http-perl-1:1.24.0-1.el9.*\n"}
+
+	d := &dnfPackageManager{binary: "dnf", executor: executor}
+	ctx := t.Context()
+
+	held, err := d.IsHeld(ctx, "nginx")
+	require.NoError(t, err)
+	require.True(t, held)
+
+	held, err = d.IsHeld(ctx, "nginx-mod")
+	require.NoError(t, err)
+	require.False(t, held)
+}
+
+func TestDnfPackageManager_ErrorIncludesOutput(t *testing.T) {
+	executor := newFakeExecutor()
+	executor.results["dnf install -y nope"] = fakeResult{
+		output: "No match for argument: nope",
+		err:    fakeExitError(1),
+	}
+
+	d := &dnfPackageManager{binary: "dnf", executor: executor}
+
+	err := d.Install(t.Context(), "nope")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "No match for argument")
+}
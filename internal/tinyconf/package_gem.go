@@ -0,0 +1,112 @@
+package tinyconf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// gemPackageManager manages ruby gems. gem keeps multiple versions
+// of a package installed, and the newest is used as the installed version.
+type gemPackageManager struct {
+	executor commandExecutor
+}
+
+func (g *gemPackageManager) run(ctx context.Context, action string, args ...string) ([]byte, error) {
+	output, err := g.executor.Run(ctx, "gem", args...)
+	if err != nil {
+		return output, fmt.Errorf("failed to %s (output: %s): %w", action, string(output), err)
+	}
+
+	return output, nil
+}
+
+// returns the newest version from gem list output, such as
+// rake (13.2.1, 13.0.6)
+func parseGemList(output string, packageName string) string {
+	for line := range strings.Lines(output) {
+		name, versions, ok := strings.Cut(strings.TrimSpace(line), " (")
+		if !ok || name != packageName {
+			continue
+		}
+
+		versions = strings.TrimSuffix(versions, ")")
+		newest, _, _ := strings.Cut(versions, ",")
+		// default: 13.2.1
+		newest = strings.TrimPrefix(newest, "default: ")
+
+		return strings.TrimSpace(newest)
+	}
+
+	return ""
+}
+
+func (g *gemPackageManager) IsInstalled(ctx context.Context, packageName string) (bool, error) {
+	version, err := g.InstalledVersion(ctx, packageName)
+	return version != "", err
+}
+
+func (g *gemPackageManager) InstalledVersion(ctx context.Context, packageName string) (string, error) {
+	output, err := g.run(ctx, "check package "+packageName+" version", "list", "--local", "--exact", packageName)
+	if err != nil {
+		return "", err
+	}
+
+	return parseGemList(string(output), packageName), nil
+}
+
+func (g *gemPackageManager) CandidateVersion(ctx context.Context, packageName string) (string, error) {
+	output, err := g.run(ctx, "get candidate version for package "+packageName, "list", "--remote", "--exact", packageName)
+	if err != nil {
+		return "", err
+	}
+
+	return parseGemList(string(output), packageName), nil
+}
+
+func (g *gemPackageManager) Install(ctx context.Context, packageNames ...string) error {
+	_, err := g.run(ctx, "install package "+strings.Join(packageNames, " "),
+		append([]string{"install", "--no-document"}, packageNames...)...)
+	return err
+}
+
+// versions ending with * use a pessimistic constraint, so 1.2* is ~> 1.2.0
+func (g *gemPackageManager) InstallVersion(ctx context.Context, packageName string, version string) error {
+	if prefix, ok := strings.CutSuffix(version, "*"); ok {
+		version = "~> " + strings.TrimSuffix(prefix, ".") + ".0"
+	}
+
+	_, err := g.run(ctx, fmt.Sprintf("install package %s version %s", packageName, version),
+		"install", "--no-document", packageName, "--version", version)
+	return err
+}
+
+func (g *gemPackageManager) Upgrade(ctx context.Context, packageName string) error {
+	_, err := g.run(ctx, "upgrade package "+packageName, "update", "--no-document", packageName)
+	return err
+}
+
+func (g *gemPackageManager) Uninstall(ctx context.Context, packageNames ...string) error {
+	// all versions and their executables
+	_, err := g.run(ctx, "uninstall package "+strings.Join(packageNames, " "),
+		append([]string{"uninstall", "--all", "--executables"}, packageNames...)...)
+	return err
+}
+
+func (g *gemPackageManager) IsHeld(ctx context.Context, packageName string) (bool, error) {
+	return false, nil
+}
+
+func (g *gemPackageManager) SetHold(ctx context.Context, packageName string, hold bool) error {
+	if !hold {
+		return nil
+	}
+
+	return errors.New("gem does not support holding packages, use version instead")
+}
+
+// gem always uses the source directly
+func (g *gemPackageManager) UpdateCache(ctx context.Context, policy string) error {
+	return nil
+}
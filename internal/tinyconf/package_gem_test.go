@@ -0,0 +1,62 @@
+package tinyconf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseGemList(t *testing.T) {
+	for output, expected := range map[string]string{
+		"rake (13.2.1, 13.0.6)\n":                 "13.2.1",
+		"\n*** LOCAL GEMS ***\n\nrake (13.2.1)\n": "13.2.1",
+		"rake (default: 13.0.6)\n":                "13.0.6",
+		"rake-compiler (1.2.7)\n":                 "",
+		"":                                        "",
+	} {
+		require.Equal(t, expected, parseGemList(output, "rake"), output)
+	}
+}
+
+func TestGemPackageManager_Versions(t *testing.T) {
+	executor := newFakeExecutor()
+	executor.results["gem list --local --exact rake"] = fakeResult{output: "rake (13.0.6)\n"}
+	executor.results["gem list --remote --exact rake"] = fakeResult{output: "rake (13.2.1)\n"}
+
+	g := &gemPackageManager{executor: executor}
+	ctx := t.Context()
+
+	version, err := g.InstalledVersion(ctx, "rake")
+	require.NoError(t, err)
+	require.Equal(t, "13.0.6", version)
+
+	version, err = g.CandidateVersion(ctx, "rake")
+	require.NoError(t, err)
+	require.Equal(t, "13.2.1", version)
+
+	installed, err := g.IsInstalled(ctx, "bundler")
+	require.NoError(t, err)
+	require.False(t, installed)
+}
+
+func TestGemPackageManager_Commands(t *testing.T) {
+	executor := newFakeExecutor()
+	g := &gemPackageManager{executor: executor}
+	ctx := t.Context()
+
+	require.NoError(t, g.Install(ctx, "rake", "bundler"))
+	require.NoError(t, g.InstallVersion(ctx, "rake", "13.0.6"))
+	require.NoError(t, g.InstallVersion(ctx, "bundler", "2.5*"))
+	require.NoError(t, g.Upgrade(ctx, "rake"))
+	require.NoError(t, g.Uninstall(ctx, "bundler"))
+	require.NoError(t, g.UpdateCache(ctx, "always"))
+	require.Error(t, g.SetHold(ctx, "rake", true))
+
+	require.Equal(t, []string{
+		"gem install --no-document rake bundler",
+		"gem install --no-document rake --version 13.0.6",
+		"gem install --no-document bundler --version ~> 2.5.0",
+		"gem update --no-document rake",
+		"gem uninstall --all --executables bundler",
+	}, executor.calls)
+}
@@ -0,0 +1,124 @@
+package tinyconf
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// npmPackageManager manages global node packages using npm, optionally in a prefix.
+type npmPackageManager struct {
+	executor commandExecutor
+	// uses the npm global prefix if empty
+	prefix string
+}
+
+func (n *npmPackageManager) args(args ...string) []string {
+	out := []string{args[0], "--global"}
+	if n.prefix != "" {
+		out = append(out, "--prefix", n.prefix)
+	}
+
+	return append(out, args[1:]...)
+}
+
+func (n *npmPackageManager) run(ctx context.Context, action string, args ...string) ([]byte, error) {
+	output, err := n.executor.Run(ctx, "npm", n.args(args...)...)
+	if err != nil {
+		return output, fmt.Errorf("failed to %s (output: %s): %w", action, string(output), err)
+	}
+
+	return output, nil
+}
+
+// returns the version from npm ls --json output
+func parseNpmList(output []byte, packageName string) (string, error) {
+	// skip any warnings before the json
+	if i := bytes.IndexByte(output, '{'); i > 0 {
+		output = output[i:]
+	}
+
+	var list struct {
+		Dependencies map[string]struct {
+			Version string `json:"version"`
+		} `json:"dependencies"`
+	}
+
+	if err := json.Unmarshal(output, &list); err != nil {
+		return "", fmt.Errorf("unexpected npm ls output %w", err)
+	}
+
+	return list.Dependencies[packageName].Version, nil
+}
+
+func (n *npmPackageManager) IsInstalled(ctx context.Context, packageName string) (bool, error) {
+	version, err := n.InstalledVersion(ctx, packageName)
+	return version != "", err
+}
+
+func (n *npmPackageManager) InstalledVersion(ctx context.Context, packageName string) (string, error) {
+	output, err := n.executor.Run(ctx, "npm", n.args("ls", "--depth=0", "--json", packageName)...)
+	// exit code 1 means package not installed, but the output is still valid
+	if err != nil && exitCode(err) != 1 {
+		return "", fmt.Errorf("failed to check package %s version (output: %s): %w", packageName, string(output), err)
+	}
+
+	return parseNpmList(output, packageName)
+}
+
+func (n *npmPackageManager) CandidateVersion(ctx context.Context, packageName string) (string, error) {
+	output, err := n.executor.Run(ctx, "npm", "view", packageName, "version")
+	if err != nil {
+		return "", fmt.Errorf("failed to get candidate version for package %s (output: %s): %w", packageName, string(output), err)
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
+
+func (n *npmPackageManager) Install(ctx context.Context, packageNames ...string) error {
+	_, err := n.run(ctx, "install package "+strings.Join(packageNames, " "),
+		append([]string{"install"}, packageNames...)...)
+	return err
+}
+
+// versions ending with * use a semver x-range
+func (n *npmPackageManager) InstallVersion(ctx context.Context, packageName string, version string) error {
+	if prefix, ok := strings.CutSuffix(version, "*"); ok {
+		version = strings.TrimSuffix(prefix, ".") + ".x"
+	}
+
+	_, err := n.run(ctx, fmt.Sprintf("install package %s version %s", packageName, version),
+		"install", packageName+"@"+version)
+	return err
+}
+
+func (n *npmPackageManager) Upgrade(ctx context.Context, packageName string) error {
+	_, err := n.run(ctx, "upgrade package "+packageName, "install", packageName+"@latest")
+	return err
+}
+
+func (n *npmPackageManager) Uninstall(ctx context.Context, packageNames ...string) error {
+	_, err := n.run(ctx, "uninstall package "+strings.Join(packageNames, " "),
+		append([]string{"uninstall"}, packageNames...)...)
+	return err
+}
+
+func (n *npmPackageManager) IsHeld(ctx context.Context, packageName string) (bool, error) {
+	return false, nil
+}
+
+func (n *npmPackageManager) SetHold(ctx context.Context, packageName string, hold bool) error {
+	if !hold {
+		return nil
+	}
+
+	return errors.New("npm does not support holding packages, use version instead")
+}
+
+// npm always uses the registry directly
+func (n *npmPackageManager) UpdateCache(ctx context.Context, policy string) error {
+	return nil
+}
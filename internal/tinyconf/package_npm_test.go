@@ -0,0 +1,62 @@
+package tinyconf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNpmPackageManager_Versions(t *testing.T) {
+	executor := newFakeExecutor()
+	executor.results["npm ls --global --depth=0 --json typescript"] = fakeResult{output: `{"name": "lib", "dependencies": {"typescript": {"version": "5.4.5", "overridden": false}}}`}
+	// npm exits 1 when the package is not installed
+	executor.results["npm ls --global --depth=0 --json pnpm"] = fakeResult{output: "npm warn config production Use `--omit=dev` instead.\n{}\n", err: fakeExitError(1)}
+	executor.results["npm view typescript version"] = fakeResult{output: "5.6.2\n"}
+
+	n := &npmPackageManager{executor: executor}
+	ctx := t.Context()
+
+	version, err := n.InstalledVersion(ctx, "typescript")
+	require.NoError(t, err)
+	require.Equal(t, "5.4.5", version)
+
+	installed, err := n.IsInstalled(ctx, "pnpm")
+	require.NoError(t, err)
+	require.False(t, installed)
+
+	version, err = n.CandidateVersion(ctx, "typescript")
+	require.NoError(t, err)
+	require.Equal(t, "5.6.2", version)
+}
+
+func TestNpmPackageManager_InstalledVersionError(t *testing.T) {
+	executor := newFakeExecutor()
+	executor.results["npm ls --global --depth=0 --json typescript"] = fakeResult{output: "npm error", err: fakeExitError(2)}
+
+	n := &npmPackageManager{executor: executor}
+
+	_, err := n.InstalledVersion(t.Context(), "typescript")
+	require.Error(t, err)
+}
+
+func TestNpmPackageManager_Commands(t *testing.T) {
+	executor := newFakeExecutor()
+	n := &npmPackageManager{executor: executor, prefix: "/opt/node"}
+	ctx := t.Context()
+
+	require.NoError(t, n.Install(ctx, "typescript", "pnpm"))
+	require.NoError(t, n.InstallVersion(ctx, "typescript", "5.4.5"))
+	require.NoError(t, n.InstallVersion(ctx, "pnpm", "9*"))
+	require.NoError(t, n.Upgrade(ctx, "typescript"))
+	require.NoError(t, n.Uninstall(ctx, "pnpm"))
+	require.NoError(t, n.UpdateCache(ctx, "always"))
+	require.Error(t, n.SetHold(ctx, "typescript", true))
+
+	require.Equal(t, []string{
+		"npm install --global --prefix /opt/node typescript pnpm",
+		"npm install --global --prefix /opt/node typescript@5.4.5",
+		"npm install --global --prefix /opt/node pnpm@9.x",
+		"npm install --global --prefix /opt/node typescript@latest",
+		"npm uninstall --global --prefix /opt/node pnpm",
+	}, executor.calls)
+}
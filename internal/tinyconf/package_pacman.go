@@ -0,0 +1,122 @@
+package tinyconf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// pacmanPackageManager manages packages using pacman on Arch.
+// pacman can only install the version in the sync database, so
+// versions and holds are not supported.
+type pacmanPackageManager struct {
+	executor     commandExecutor
+	cacheUpdated bool
+}
+
+func (p *pacmanPackageManager) run(ctx context.Context, action string, args ...string) ([]byte, error) {
+	output, err := p.executor.Run(ctx, "pacman", args...)
+	if err != nil {
+		return output, fmt.Errorf("failed to %s (output: %s): %w", action, string(output), err)
+	}
+
+	return output, nil
+}
+
+func (p *pacmanPackageManager) IsInstalled(ctx context.Context, packageName string) (bool, error) {
+	version, err := p.InstalledVersion(ctx, packageName)
+	return version != "", err
+}
+
+func (p *pacmanPackageManager) InstalledVersion(ctx context.Context, packageName string) (string, error) {
+	output, err := p.executor.Run(ctx, "pacman", "-Q", packageName)
+	if err != nil {
+		// exit code 1 means package not installed
+		if exitCode(err) == 1 {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to check package %s version (output: %s): %w", packageName, string(output), err)
+	}
+
+	// name version
+	fields := strings.Fields(string(output))
+	if len(fields) != 2 || fields[0] != packageName {
+		return "", fmt.Errorf("unexpected output checking package %s version: %s", packageName, string(output))
+	}
+
+	return fields[1], nil
+}
+
+func (p *pacmanPackageManager) CandidateVersion(ctx context.Context, packageName string) (string, error) {
+	output, err := p.executor.Run(ctx, "pacman", "-Si", packageName)
+	if err != nil {
+		// exit code 1 means package not found
+		if exitCode(err) == 1 {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get candidate version for package %s (output: %s): %w", packageName, string(output), err)
+	}
+
+	return fieldValue(string(output), "Version"), nil
+}
+
+func (p *pacmanPackageManager) Install(ctx context.Context, packageNames ...string) error {
+	_, err := p.run(ctx, "install package "+strings.Join(packageNames, " "),
+		append([]string{"-S", "--noconfirm", "--needed"}, packageNames...)...)
+	return err
+}
+
+func (p *pacmanPackageManager) InstallVersion(ctx context.Context, packageName string, version string) error {
+	return errors.New("pacman does not support installing a specific version")
+}
+
+func (p *pacmanPackageManager) Upgrade(ctx context.Context, packageName string) error {
+	_, err := p.run(ctx, "upgrade package "+packageName, "-S", "--noconfirm", packageName)
+	return err
+}
+
+func (p *pacmanPackageManager) Uninstall(ctx context.Context, packageNames ...string) error {
+	_, err := p.run(ctx, "uninstall package "+strings.Join(packageNames, " "),
+		append([]string{"-R", "--noconfirm"}, packageNames...)...)
+	return err
+}
+
+func (p *pacmanPackageManager) IsHeld(ctx context.Context, packageName string) (bool, error) {
+	// holds are IgnorePkg in pacman.conf, which we do not manage
+	return false, nil
+}
+
+func (p *pacmanPackageManager) SetHold(ctx context.Context, packageName string, hold bool) error {
+	if !hold {
+		return nil
+	}
+
+	return errors.New("pacman does not support holding packages, use IgnorePkg in pacman.conf")
+}
+
+func (p *pacmanPackageManager) UpdateCache(ctx context.Context, policy string) error {
+	if p.cacheUpdated {
+		return nil
+	}
+
+	needed, err := cacheNeedsUpdate(policy, func() (time.Duration, error) {
+		return pathAge("/var/lib/pacman/sync")
+	})
+	if err != nil || !needed {
+		return err
+	}
+
+	// arch does not support partial upgrades, so refreshing the sync database
+	// without upgrading can break packages installed afterwards
+	slog.Info("updating package cache and upgrading system", "provider", "pacman")
+	if _, err := p.run(ctx, "update package cache", "-Syu", "--noconfirm"); err != nil {
+		return err
+	}
+
+	p.cacheUpdated = true
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package tinyconf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPacmanPackageManager_Versions(t *testing.T) {
+	executor := newFakeExecutor()
+	executor.results["pacman -Q nginx"] = fakeResult{output: "nginx 1.24.0-1\n"}
+	executor.results["pacman -Q curl"] = fakeResult{
+		output: "error: package 'curl' was not found",
+		err:    fakeExitError(1),
+	}
+	executor.results["pacman -Si nginx"] = fakeResult{output: "Repository      : extra\nName            : nginx\nVersion         : 1.26.0-1\n"}
+
+	p := &pacmanPackageManager{executor: executor}
+	ctx := t.Context()
+
+	version, err := p.InstalledVersion(ctx, "nginx")
+	require.NoError(t, err)
+	require.Equal(t, "1.24.0-1", version)
+
+	installed, err := p.IsInstalled(ctx, "curl")
+	require.NoError(t, err)
+	require.False(t, installed)
+
+	version, err = p.CandidateVersion(ctx, "nginx")
+	require.NoError(t, err)
+	require.Equal(t, "1.26.0-1", version)
+}
+
+func TestPacmanPackageManager_Commands(t *testing.T) {
+	executor := newFakeExecutor()
+	p := &pacmanPackageManager{executor: executor}
+	ctx := t.Context()
+
+	require.NoError(t, p.Install(ctx, "nginx", "curl"))
+	require.NoError(t, p.Upgrade(ctx, "nginx"))
+	require.NoError(t, p.Uninstall(ctx, "curl"))
+	require.NoError(t, p.UpdateCache(ctx, "always"))
+	require.NoError(t, p.UpdateCache(ctx, "always"))
+
+	require.Equal(t, []string{
+		"pacman -S --noconfirm --needed nginx curl",
+		"pacman -S --noconfirm nginx",
+		"pacman -R --noconfirm curl",
+		"pacman -Syu --noconfirm",
+	}, executor.calls)
+}
+
+func TestPacmanPackageManager_Unsupported(t *testing.T) {
+	p := &pacmanPackageManager{executor: newFakeExecutor()}
+	ctx := t.Context()
+
+	require.Error(t, p.InstallVersion(ctx, "nginx", "1.24.0-1"))
+	require.Error(t, p.SetHold(ctx, "nginx", true))
+	require.NoError(t, p.SetHold(ctx, "nginx", false))
+}
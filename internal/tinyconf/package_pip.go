@@ -0,0 +1,151 @@
+package tinyconf
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// pipPackageManager manages python packages using pip, optionally in a virtualenv.
+type pipPackageManager struct {
+	executor commandExecutor
+	// path to a virtualenv. uses the system python3 if empty
+	virtualenv string
+}
+
+func (p *pipPackageManager) run(ctx context.Context, action string, args ...string) ([]byte, error) {
+	python := "python3"
+	if p.virtualenv != "" {
+		python = filepath.Join(p.virtualenv, "bin", "python")
+	}
+
+	output, err := p.executor.Run(ctx, python, append([]string{"-m", "pip", "--disable-pip-version-check"}, args...)...)
+	if err != nil {
+		return output, fmt.Errorf("failed to %s (output: %s): %w", action, string(output), err)
+	}
+
+	return output, nil
+}
+
+var pipNameSeparators = regexp.MustCompile(`[-_.]+`)
+
+// names are case insensitive and treat -, _, and . the same
+func normalizePipName(name string) string {
+	return strings.ToLower(pipNameSeparators.ReplaceAllString(name, "-"))
+}
+
+// returns the version from pip list --format=json output. the output includes
+// stderr, which can have warnings such as "Ignoring invalid distribution", so
+// only the line with the json list is parsed.
+func parsePipList(output []byte, packageName string) (string, error) {
+	var packages []struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+
+	list := ""
+	for line := range strings.Lines(string(output)) {
+		if strings.HasPrefix(line, "[") {
+			list = line
+			break
+		}
+	}
+
+	if err := json.Unmarshal([]byte(list), &packages); err != nil {
+		return "", fmt.Errorf("unexpected pip list output %w", err)
+	}
+
+	for _, p := range packages {
+		if normalizePipName(p.Name) == normalizePipName(packageName) {
+			return p.Version, nil
+		}
+	}
+
+	return "", nil
+}
+
+func (p *pipPackageManager) IsInstalled(ctx context.Context, packageName string) (bool, error) {
+	version, err := p.InstalledVersion(ctx, packageName)
+	return version != "", err
+}
+
+func (p *pipPackageManager) InstalledVersion(ctx context.Context, packageName string) (string, error) {
+	output, err := p.run(ctx, "list python packages", "list", "--format=json")
+	if err != nil {
+		return "", err
+	}
+
+	return parsePipList(output, packageName)
+}
+
+func (p *pipPackageManager) CandidateVersion(ctx context.Context, packageName string) (string, error) {
+	output, err := p.run(ctx, "get candidate version for package "+packageName, "index", "versions", packageName)
+	if err != nil {
+		return "", err
+	}
+
+	return parsePipIndexVersions(output, packageName), nil
+}
+
+// returns the latest version from pip index versions output, such as
+// requests (2.32.3). the output includes stderr, which always starts with
+// a warning that the command is experimental, so the line for the package is found by name.
+func parsePipIndexVersions(output []byte, packageName string) string {
+	for line := range strings.Lines(string(output)) {
+		name, version, ok := strings.Cut(strings.TrimSpace(line), " (")
+		if ok && normalizePipName(name) == normalizePipName(packageName) {
+			return strings.TrimSuffix(version, ")")
+		}
+	}
+
+	return ""
+}
+
+func (p *pipPackageManager) Install(ctx context.Context, packageNames ...string) error {
+	_, err := p.run(ctx, "install package "+strings.Join(packageNames, " "),
+		append([]string{"install"}, packageNames...)...)
+	return err
+}
+
+// versions ending with * use a PEP 440 prefix match
+func (p *pipPackageManager) InstallVersion(ctx context.Context, packageName string, version string) error {
+	if prefix, ok := strings.CutSuffix(version, "*"); ok {
+		version = strings.TrimSuffix(prefix, ".") + ".*"
+	}
+
+	_, err := p.run(ctx, fmt.Sprintf("install package %s version %s", packageName, version),
+		"install", packageName+"=="+version)
+	return err
+}
+
+func (p *pipPackageManager) Upgrade(ctx context.Context, packageName string) error {
+	_, err := p.run(ctx, "upgrade package "+packageName, "install", "--upgrade", packageName)
+	return err
+}
+
+func (p *pipPackageManager) Uninstall(ctx context.Context, packageNames ...string) error {
+	_, err := p.run(ctx, "uninstall package "+strings.Join(packageNames, " "),
+		append([]string{"uninstall", "-y"}, packageNames...)...)
+	return err
+}
+
+func (p *pipPackageManager) IsHeld(ctx context.Context, packageName string) (bool, error) {
+	return false, nil
+}
+
+func (p *pipPackageManager) SetHold(ctx context.Context, packageName string, hold bool) error {
+	if !hold {
+		return nil
+	}
+
+	return errors.New("pip does not support holding packages, use version instead")
+}
+
+// pip always uses the index directly
+func (p *pipPackageManager) UpdateCache(ctx context.Context, policy string) error {
+	return nil
+}
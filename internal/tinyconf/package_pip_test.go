@@ -0,0 +1,80 @@
+package tinyconf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPipPackageManager_Versions(t *testing.T) {
+	executor := newFakeExecutor()
+	executor.results["python3 -m pip --disable-pip-version-check list --format=json"] = fakeResult{output: "WARNING: Ignoring invalid distribution ~equests (/usr/lib/python3/dist-packages)\n" +
+		`[{"name": "pip", "version": "24.0"}, {"name": "Flask_Login", "version": "0.6.3"}]` + "\n"}
+	executor.results["python3 -m pip --disable-pip-version-check index versions flask-login"] = fakeResult{output: "WARNING: pip index is currently an experimental command. It may be removed/changed in a future release without prior warning.\n" +
+		"Flask-Login (0.7.0)\nAvailable versions: 0.7.0, 0.6.3\n  INSTALLED: 0.6.3\n  LATEST:    0.7.0\n"}
+
+	p := &pipPackageManager{executor: executor}
+	ctx := t.Context()
+
+	version, err := p.InstalledVersion(ctx, "flask-login")
+	require.NoError(t, err)
+	require.Equal(t, "0.6.3", version)
+
+	installed, err := p.IsInstalled(ctx, "requests")
+	require.NoError(t, err)
+	require.False(t, installed)
+
+	version, err = p.CandidateVersion(ctx, "flask-login")
+	require.NoError(t, err)
+	require.Equal(t, "0.7.0", version)
+}
+
+func TestPipPackageManager_Commands(t *testing.T) {
+	executor := newFakeExecutor()
+	p := &pipPackageManager{executor: executor, virtualenv: "/opt/app/venv"}
+	ctx := t.Context()
+
+	require.NoError(t, p.Install(ctx, "requests", "flask"))
+	require.NoError(t, p.InstallVersion(ctx, "requests", "2.32.3"))
+	require.NoError(t, p.InstallVersion(ctx, "flask", "3.0*"))
+	require.NoError(t, p.Upgrade(ctx, "requests"))
+	require.NoError(t, p.Uninstall(ctx, "flask"))
+	require.NoError(t, p.UpdateCache(ctx, "always"))
+	require.NoError(t, p.SetHold(ctx, "requests", false))
+	require.Error(t, p.SetHold(ctx, "requests", true))
+
+	require.Equal(t, []string{
+		"/opt/app/venv/bin/python -m pip --disable-pip-version-check install requests flask",
+		"/opt/app/venv/bin/python -m pip --disable-pip-version-check install requests==2.32.3",
+		"/opt/app/venv/bin/python -m pip --disable-pip-version-check install flask==3.0.*",
+		"/opt/app/venv/bin/python -m pip --disable-pip-version-check install --upgrade requests",
+		"/opt/app/venv/bin/python -m pip --disable-pip-version-check uninstall -y flask",
+	}, executor.calls)
+}
+
+func TestGetRunners_PipVirtualenvs(t *testing.T) {
+	cfg, err := configFromBytes([]byte(`
+resources:
+  - type: package
+    name: requests
+    state: installed
+    provider: pip
+    virtualenv: /opt/one
+  - type: package
+    name: flask
+    state: installed
+    provider: pip
+    virtualenv: /opt/two
+`))
+	require.NoError(t, err)
+
+	runners, err := cfg.getRunners()
+	require.NoError(t, err)
+
+	// different virtualenvs are different managers, so are not batched
+	require.Len(t, runners, 2)
+	one := runners[0].(*packageResource).manager.(*pipPackageManager)
+	two := runners[1].(*packageResource).manager.(*pipPackageManager)
+	require.Equal(t, "/opt/one", one.virtualenv)
+	require.Equal(t, "/opt/two", two.virtualenv)
+}
@@ -0,0 +1,198 @@
+package tinyconf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// supported package providers
+var packageProviders = []string{"apt", "dnf", "yum", "apk", "pacman", "zypper", "pip", "npm", "gem"}
+
+// os-release IDs for each provider. ID_LIKE is checked as well, so
+// derivatives usually do not need to be listed
+var osReleaseProviders = map[string]string{
+	"debian":   "apt",
+	"ubuntu":   "apt",
+	"fedora":   "dnf",
+	"rhel":     "dnf",
+	"centos":   "dnf",
+	"alpine":   "apk",
+	"arch":     "pacman",
+	"suse":     "zypper",
+	"opensuse": "zypper",
+	"sles":     "zypper",
+}
+
+// returns the package provider for the host. defaults to apt if it can not be determined.
+func detectPackageProvider() string {
+	data, err := os.ReadFile("/etc/os-release")
+	if err != nil {
+		return "apt"
+	}
+
+	if provider := packageProviderFromOSRelease(string(data)); provider != "" {
+		return dnfOrYum(provider, exec.LookPath)
+	}
+
+	return "apt"
+}
+
+// older releases, such as CentOS 7, only have yum
+func dnfOrYum(provider string, lookPath func(string) (string, error)) string {
+	if provider != "dnf" {
+		return provider
+	}
+
+	if _, err := lookPath("dnf"); err != nil {
+		if _, err := lookPath("yum"); err == nil {
+			return "yum"
+		}
+	}
+
+	return provider
+}
+
+// returns the provider based on ID and ID_LIKE in /etc/os-release formatted input,
+// or an empty string if it is unknown
+func packageProviderFromOSRelease(input string) string {
+	var ids []string
+
+	for line := range strings.Lines(input) {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+
+		value = strings.Trim(value, `"'`)
+
+		switch key {
+		case "ID":
+			// ID is more specific than ID_LIKE
+			ids = append([]string{value}, ids...)
+		case "ID_LIKE":
+			ids = append(ids, strings.Fields(value)...)
+		}
+	}
+
+	for _, id := range ids {
+		if provider, ok := osReleaseProviders[id]; ok {
+			return provider
+		}
+
+		// opensuse-leap, opensuse-tumbleweed, etc
+		if strings.HasPrefix(id, "opensuse") {
+			return "zypper"
+		}
+	}
+
+	return ""
+}
+
+// location is the virtualenv for pip or the prefix for npm, and is ignored by other providers
+func newPackageManager(provider string, location string) (packageManager, error) {
+	executor := &osCommandExecutor{}
+
+	switch provider {
+	case "apt":
+		return &aptPackageManager{}, nil
+	case "dnf", "yum":
+		return &dnfPackageManager{binary: provider, executor: executor}, nil
+	case "apk":
+		return &apkPackageManager{executor: executor}, nil
+	case "pacman":
+		return &pacmanPackageManager{executor: executor}, nil
+	case "zypper":
+		return &zypperPackageManager{executor: executor}, nil
+	case "pip":
+		return &pipPackageManager{executor: executor, virtualenv: location}, nil
+	case "npm":
+		return &npmPackageManager{executor: executor, prefix: location}, nil
+	case "gem":
+		return &gemPackageManager{executor: executor}, nil
+	default:
+		// should be caught by validation
+		return nil, fmt.Errorf("unknown package provider %s, expected one of %s", provider, strings.Join(packageProviders, ", "))
+	}
+}
+
+// runs commands for package managers. replaced in tests.
+type commandExecutor interface {
+	// returns the combined output
+	Run(context.Context, string, ...string) ([]byte, error)
+}
+
+type osCommandExecutor struct{}
+
+func (o *osCommandExecutor) Run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return exec.CommandContext(ctx, name, args...).CombinedOutput()
+}
+
+// returns the exit code of a command error, or -1 if the command did not exit
+func exitCode(err error) int {
+	var exitErr interface{ ExitCode() int }
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+
+	return -1
+}
+
+// returns the time since the first existing path was modified
+func pathAge(paths ...string) (time.Duration, error) {
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return 0, fmt.Errorf("failed to stat %s %w", path, err)
+		}
+
+		return time.Since(info.ModTime()), nil
+	}
+
+	// never updated
+	return math.MaxInt64, nil
+}
+
+// returns the version from name-version formatted input, as used by apk
+func trimPackageName(nameVersion string, name string) (string, bool) {
+	version, ok := strings.CutPrefix(nameVersion, name+"-")
+	if !ok || version == "" || !strings.ContainsAny(version[:1], "0123456789") {
+		return "", false
+	}
+
+	return version, true
+}
+
+// returns the value of a "Key : value" line, as used by pacman and zypper
+func fieldValue(output string, key string) string {
+	for line := range strings.Lines(output) {
+		k, v, ok := strings.Cut(line, ":")
+		if ok && strings.TrimSpace(k) == key {
+			return strings.TrimSpace(v)
+		}
+	}
+
+	return ""
+}
+
+// returns the installed version of a package using rpm, or an empty string if it is not installed
+func rpmInstalledVersion(ctx context.Context, executor commandExecutor, packageName string) (string, error) {
+	output, err := executor.Run(ctx, "rpm", "-q", "--qf", "%{VERSION}-%{RELEASE}", packageName)
+	if err != nil {
+		// exit code 1 means package not installed
+		if exitCode(err) == 1 {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to check package %s version (output: %s): %w", packageName, string(output), err)
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
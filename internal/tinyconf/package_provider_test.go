@@ -0,0 +1,123 @@
+package tinyconf
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeResult struct {
+	output string
+	err    error
+}
+
+// records commands and returns canned results, keyed by the full command line
+type fakeExecutor struct {
+	calls   []string
+	results map[string]fakeResult
+}
+
+func newFakeExecutor() *fakeExecutor {
+	return &fakeExecutor{
+		results: make(map[string]fakeResult),
+	}
+}
+
+func (f *fakeExecutor) Run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	command := strings.Join(append([]string{name}, args...), " ")
+	f.calls = append(f.calls, command)
+	result := f.results[command]
+	return []byte(result.output), result.err
+}
+
+type fakeExitError int
+
+func (e fakeExitError) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
+func (e fakeExitError) ExitCode() int {
+	return int(e)
+}
+
+func TestDnfOrYum(t *testing.T) {
+	lookPath := func(found ...string) func(string) (string, error) {
+		return func(name string) (string, error) {
+			if slices.Contains(found, name) {
+				return "/usr/bin/" + name, nil
+			}
+			return "", exec.ErrNotFound
+		}
+	}
+
+	require.Equal(t, "dnf", dnfOrYum("dnf", lookPath("dnf", "yum")))
+	require.Equal(t, "yum", dnfOrYum("dnf", lookPath("yum")))
+	// not installed yet, or something else is wrong. dnf gives the better error
+	require.Equal(t, "dnf", dnfOrYum("dnf", lookPath()))
+	require.Equal(t, "apt", dnfOrYum("apt", lookPath()))
+}
+
+func TestPackageProviderFromOSRelease(t *testing.T) {
+	for input, expected := range map[string]string{
+		"ID=ubuntu\nID_LIKE=debian\n":                       "apt",
+		"ID=debian\n":                                       "apt",
+		"ID=linuxmint\nID_LIKE=\"ubuntu debian\"\n":         "apt",
+		"ID=\"rocky\"\nID_LIKE=\"rhel centos fedora\"\n":    "dnf",
+		"ID=fedora\n":                                       "dnf",
+		"ID=alpine\n":                                       "apk",
+		"ID=arch\n":                                         "pacman",
+		"ID=manjaro\nID_LIKE=arch\n":                        "pacman",
+		"ID=\"opensuse-leap\"\nID_LIKE=\"suse opensuse\"\n": "zypper",
+		"ID=\"opensuse-tumbleweed\"\n":                      "zypper",
+		"ID=plan9\n":                                        "",
+	} {
+		require.Equal(t, expected, packageProviderFromOSRelease(input), input)
+	}
+}
+
+func TestNewPackageManager(t *testing.T) {
+	for _, provider := range packageProviders {
+		manager, err := newPackageManager(provider, "")
+		require.NoError(t, err)
+		require.NotNil(t, manager)
+	}
+
+	_, err := newPackageManager("brew", "")
+	require.Error(t, err)
+}
+
+func TestGetRunners_PackageProviders(t *testing.T) {
+	cfg, err := configFromBytes([]byte(`
+resources:
+  - type: package
+    name: nginx
+    state: installed
+    provider: dnf
+  - type: package
+    name: curl
+    state: installed
+    provider: dnf
+  - type: package
+    name: jq
+    state: installed
+    provider: apk
+`))
+	require.NoError(t, err)
+
+	runners, err := cfg.getRunners()
+	require.NoError(t, err)
+
+	// the dnf packages share a manager and are batched, apk is separate
+	require.Len(t, runners, 4)
+	batch, ok := runners[0].(*packageBatch)
+	require.True(t, ok)
+	require.Len(t, batch.packages, 2)
+	require.IsType(t, &dnfPackageManager{}, batch.packages[0].manager)
+	require.Same(t, batch.packages[0].manager, batch.packages[1].manager)
+	require.IsType(t, &apkPackageManager{}, runners[3].(*packageResource).manager)
+}
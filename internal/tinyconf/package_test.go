@@ -3,7 +3,10 @@ package tinyconf
 import (
 	"context"
 	"errors"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -12,18 +15,41 @@ import (
 // copy/paste search/replace :)
 
 type mockPackageManager struct {
-	packages         map[string]bool // package name -> installed state
-	isInstalledErr   error
-	installErr       error
-	uninstallErr     error
-	installCalled    []string
-	uninstallCalled  []string
-	isInstalledCalls []string
+	packages             map[string]bool   // package name -> installed state
+	versions             map[string]string // package name -> installed version
+	candidates           map[string]string // package name -> candidate version
+	held                 map[string]bool
+	isInstalledErr       error
+	installErr           error
+	uninstallErr         error
+	installCalled        []string
+	uninstallCalled      []string
+	isInstalledCalls     []string
+	installVersionCalled map[string]string
+	upgradeCalled        []string
+	setHoldCalled        map[string]bool
+	// policies passed to UpdateCache
+	updateCacheCalled []string
+	updateCacheErr    error
+	// number of calls to Install and Uninstall
+	installTransactions   int
+	uninstallTransactions int
+	// question -> value
+	debconf   map[string]string
+	preseeded []string
+	// packages that were preseeded when installed
+	preseededBeforeInstall []string
 }
 
 func newMockPackageManager() *mockPackageManager {
 	return &mockPackageManager{
-		packages: make(map[string]bool),
+		packages:             make(map[string]bool),
+		versions:             make(map[string]string),
+		candidates:           make(map[string]string),
+		held:                 make(map[string]bool),
+		installVersionCalled: make(map[string]string),
+		setHoldCalled:        make(map[string]bool),
+		debconf:              make(map[string]string),
 	}
 }
 
@@ -35,21 +61,86 @@ func (m *mockPackageManager) IsInstalled(ctx context.Context, packageName string
 	return m.packages[packageName], nil
 }
 
-func (m *mockPackageManager) Install(ctx context.Context, packageName string) error {
-	m.installCalled = append(m.installCalled, packageName)
+func (m *mockPackageManager) Install(ctx context.Context, packageNames ...string) error {
+	m.installCalled = append(m.installCalled, packageNames...)
+	m.installTransactions++
 	if m.installErr != nil {
 		return m.installErr
 	}
-	m.packages[packageName] = true
+	for _, packageName := range packageNames {
+		m.packages[packageName] = true
+		m.versions[packageName] = m.candidates[packageName]
+		if slices.Contains(m.preseeded, packageName) {
+			m.preseededBeforeInstall = append(m.preseededBeforeInstall, packageName)
+		}
+	}
 	return nil
 }
 
-func (m *mockPackageManager) Uninstall(ctx context.Context, packageName string) error {
-	m.uninstallCalled = append(m.uninstallCalled, packageName)
+func (m *mockPackageManager) Uninstall(ctx context.Context, packageNames ...string) error {
+	m.uninstallCalled = append(m.uninstallCalled, packageNames...)
+	m.uninstallTransactions++
 	if m.uninstallErr != nil {
 		return m.uninstallErr
 	}
-	m.packages[packageName] = false
+	for _, packageName := range packageNames {
+		m.packages[packageName] = false
+		delete(m.versions, packageName)
+	}
+	return nil
+}
+
+func (m *mockPackageManager) InstalledVersion(ctx context.Context, packageName string) (string, error) {
+	if m.isInstalledErr != nil {
+		return "", m.isInstalledErr
+	}
+	if !m.packages[packageName] {
+		return "", nil
+	}
+	return m.versions[packageName], nil
+}
+
+func (m *mockPackageManager) CandidateVersion(ctx context.Context, packageName string) (string, error) {
+	return m.candidates[packageName], nil
+}
+
+func (m *mockPackageManager) InstallVersion(ctx context.Context, packageName string, version string) error {
+	m.installVersionCalled[packageName] = version
+	if m.installErr != nil {
+		return m.installErr
+	}
+	m.packages[packageName] = true
+	m.versions[packageName] = strings.TrimSuffix(version, "*")
+	return nil
+}
+
+func (m *mockPackageManager) Upgrade(ctx context.Context, packageName string) error {
+	m.upgradeCalled = append(m.upgradeCalled, packageName)
+	m.versions[packageName] = m.candidates[packageName]
+	return nil
+}
+
+func (m *mockPackageManager) UpdateCache(ctx context.Context, policy string) error {
+	m.updateCacheCalled = append(m.updateCacheCalled, policy)
+	return m.updateCacheErr
+}
+
+func (m *mockPackageManager) Preseed(ctx context.Context, packageName string, selections []debconfSelection) (bool, error) {
+	changes, changed := debconfChanges(m.debconf, selections)
+	for _, s := range changes {
+		m.debconf[s.Question] = s.value()
+	}
+	m.preseeded = append(m.preseeded, packageName)
+	return changed, nil
+}
+
+func (m *mockPackageManager) IsHeld(ctx context.Context, packageName string) (bool, error) {
+	return m.held[packageName], nil
+}
+
+func (m *mockPackageManager) SetHold(ctx context.Context, packageName string, hold bool) error {
+	m.setHoldCalled[packageName] = hold
+	m.held[packageName] = hold
 	return nil
 }
 
@@ -427,3 +518,371 @@ func TestPackageResource_Run_StateTransitions(t *testing.T) {
 		})
 	}
 }
+
+func TestPackageResource_Run_PinnedVersion(t *testing.T) {
+	mock := newMockPackageManager()
+	mock.packages["nginx"] = true
+	mock.versions["nginx"] = "1.18.0-0ubuntu1"
+
+	p := &packageResource{
+		Name:    "nginx",
+		State:   "installed",
+		Version: ptr("1.24.0"),
+		Notify: notifyResource{
+			Service: "nginx",
+		},
+		manager: mock,
+	}
+
+	ctx := t.Context()
+
+	service, err := p.Run(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "nginx", service)
+	require.Equal(t, "1.24.0", mock.installVersionCalled["nginx"])
+
+	mock.installVersionCalled = make(map[string]string)
+
+	service, err = p.Run(ctx)
+	require.NoError(t, err)
+	require.Empty(t, service)
+	require.Empty(t, mock.installVersionCalled)
+}
+
+func TestPackageResource_Run_PinnedVersionPrefix(t *testing.T) {
+	mock := newMockPackageManager()
+	mock.packages["nginx"] = true
+	mock.versions["nginx"] = "1.24.0-2ubuntu7"
+
+	p := &packageResource{
+		Name:    "nginx",
+		State:   "installed",
+		Version: ptr("1.24*"),
+		manager: mock,
+	}
+
+	_, err := p.Run(t.Context())
+	require.NoError(t, err)
+	require.Empty(t, mock.installVersionCalled)
+
+	p.Version = ptr("1.26*")
+
+	_, err = p.Run(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, "1.26*", mock.installVersionCalled["nginx"])
+}
+
+func TestPackageResource_Run_Latest(t *testing.T) {
+	mock := newMockPackageManager()
+	mock.packages["nginx"] = true
+	mock.versions["nginx"] = "1.24.0"
+	mock.candidates["nginx"] = "1.26.0"
+
+	p := &packageResource{
+		Name:  "nginx",
+		State: "latest",
+		Notify: notifyResource{
+			Service: "nginx",
+		},
+		manager: mock,
+	}
+
+	ctx := t.Context()
+
+	service, err := p.Run(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "nginx", service)
+	require.Equal(t, []string{"nginx"}, mock.upgradeCalled)
+
+	service, err = p.Run(ctx)
+	require.NoError(t, err)
+	require.Empty(t, service)
+	require.Len(t, mock.upgradeCalled, 1)
+}
+
+func TestPackageResource_Run_LatestInstalledIsNewer(t *testing.T) {
+	mock := newMockPackageManager()
+	mock.packages["nginx"] = true
+	mock.versions["nginx"] = "1.26.0-1~bpo12+1"
+	mock.candidates["nginx"] = "1.22.1-9"
+
+	p := &packageResource{
+		Name:    "nginx",
+		State:   "latest",
+		manager: mock,
+	}
+
+	service, err := p.Run(t.Context())
+	require.NoError(t, err)
+	require.Empty(t, service)
+	require.Empty(t, mock.upgradeCalled)
+}
+
+func TestCompareVersions(t *testing.T) {
+	for _, tc := range []struct {
+		a, b     string
+		expected int
+	}{
+		{"1.0", "1.0", 0},
+		{"1.0", "1.1", -1},
+		{"1.10", "1.9", 1},
+		{"1.0~rc1", "1.0", -1},
+		{"1.0", "1.0+deb12u1", -1},
+		{"1:1.0", "2.0", 1},
+		{"1.24.0-1", "1.24.0-2", -1},
+		{"1.26.0-1~bpo12+1", "1.26.0-1", -1},
+		{"1.2.3-r7", "1.2.3-r10", -1},
+		{"2.0.1-1.el9", "2.0.1-1.el9", 0},
+		{"1.0a", "1.0", 1},
+		{"001.2", "1.2", 0},
+	} {
+		require.Equal(t, tc.expected, compareVersions(tc.a, tc.b), "%s %s", tc.a, tc.b)
+		require.Equal(t, -tc.expected, compareVersions(tc.b, tc.a), "%s %s", tc.b, tc.a)
+	}
+}
+
+func TestPackageResource_Run_LatestNotInstalled(t *testing.T) {
+	mock := newMockPackageManager()
+	mock.candidates["nginx"] = "1.26.0"
+
+	p := &packageResource{
+		Name:    "nginx",
+		State:   "latest",
+		manager: mock,
+	}
+
+	_, err := p.Run(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, []string{"nginx"}, mock.installCalled)
+	require.Empty(t, mock.upgradeCalled)
+}
+
+func TestPackageResource_Run_Hold(t *testing.T) {
+	mock := newMockPackageManager()
+	mock.packages["nginx"] = true
+
+	p := &packageResource{
+		Name:    "nginx",
+		State:   "installed",
+		Hold:    ptr(true),
+		manager: mock,
+	}
+
+	ctx := t.Context()
+
+	_, err := p.Run(ctx)
+	require.NoError(t, err)
+	require.True(t, mock.setHoldCalled["nginx"])
+
+	mock.setHoldCalled = make(map[string]bool)
+
+	_, err = p.Run(ctx)
+	require.NoError(t, err)
+	require.Empty(t, mock.setHoldCalled)
+
+	p.Hold = ptr(false)
+
+	_, err = p.Run(ctx)
+	require.NoError(t, err)
+	require.False(t, mock.held["nginx"])
+}
+
+func TestParseAptCandidate(t *testing.T) {
+	output := `nginx:
+  Installed: 1.24.0-2ubuntu7
+  Candidate: 1.24.0-2ubuntu7.1
+  Version table:
+     1.24.0-2ubuntu7.1 500
+`
+	require.Equal(t, "1.24.0-2ubuntu7.1", parseAptCandidate(output))
+	require.Empty(t, parseAptCandidate("foo:\n  Installed: (none)\n  Candidate: (none)\n"))
+}
+
+func TestPackageBatch_Run(t *testing.T) {
+	mock := newMockPackageManager()
+	mock.packages["nginx"] = true
+	mock.packages["telnet"] = true
+
+	packages := []*packageResource{
+		{Name: "nginx", State: "installed", manager: mock},
+		{Name: "curl", State: "installed", Notify: notifyResource{Service: "curl-service"}, manager: mock},
+		{Name: "jq", State: "installed", manager: mock},
+		{Name: "telnet", State: "absent", Notify: notifyResource{Service: "telnet-service"}, manager: mock},
+		{Name: "pinned", State: "installed", Version: ptr("1.0"), manager: mock},
+	}
+
+	runners := []runner{&packageBatch{packages: packages}}
+	for _, p := range packages {
+		runners = append(runners, p)
+	}
+
+	services, err := runRunners(t.Context(), runners)
+	require.NoError(t, err)
+
+	// one transaction for installs and one for removals
+	require.Equal(t, 1, mock.installTransactions)
+	require.Equal(t, []string{"curl", "jq"}, mock.installCalled)
+	require.Equal(t, 1, mock.uninstallTransactions)
+	require.Equal(t, []string{"telnet"}, mock.uninstallCalled)
+
+	// versions are still handled by the package
+	require.Equal(t, "1.0", mock.installVersionCalled["pinned"])
+
+	// each package reports its own change
+	require.Equal(t, []notifyResource{{Service: "curl-service"}, {Service: "telnet-service"}}, services)
+
+	services, err = runRunners(t.Context(), runners)
+	require.NoError(t, err)
+	require.Empty(t, services)
+	require.Equal(t, 1, mock.installTransactions)
+}
+
+func TestPackageResource_Run_Preseed(t *testing.T) {
+	mock := newMockPackageManager()
+	mock.debconf["postfix/mailname"] = "old.example.com"
+
+	resource := &packageResource{
+		Name:  "postfix",
+		State: "installed",
+		Preseed: []debconfSelection{
+			{Question: "postfix/main_mailer_type", Type: "select", Value: "Internet Site"},
+			{Question: "postfix/mailname", Type: "string", Value: "mail.example.com"},
+		},
+		Notify:  notifyResource{Service: "postfix"},
+		manager: mock,
+	}
+
+	service, err := resource.Run(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, "postfix", service)
+	require.Equal(t, []string{"postfix"}, mock.preseededBeforeInstall)
+	require.Equal(t, "mail.example.com", mock.debconf["postfix/mailname"])
+
+	service, err = resource.Run(t.Context())
+	require.NoError(t, err)
+	require.Empty(t, service)
+
+	// a changed answer for an installed package is a change
+	resource.Preseed[1].Value = "smtp.example.com"
+	service, err = resource.Run(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, "postfix", service)
+	require.Equal(t, 1, mock.installTransactions)
+}
+
+func TestPackageBatch_Run_Preseed(t *testing.T) {
+	mock := newMockPackageManager()
+
+	packages := []*packageResource{
+		{Name: "tzdata", State: "installed", Preseed: []debconfSelection{{Question: "tzdata/Areas", Type: "select", Value: "Etc"}}, manager: mock},
+		{Name: "curl", State: "installed", manager: mock},
+	}
+
+	_, err := (&packageBatch{packages: packages}).Run(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, []string{"tzdata"}, mock.preseededBeforeInstall)
+}
+
+func TestPackageResource_Run_PreseedUnsupported(t *testing.T) {
+	resource := &packageResource{
+		Name:    "requests",
+		State:   "installed",
+		Preseed: []debconfSelection{{Question: "requests/foo", Type: "string", Value: "bar"}},
+		manager: &pipPackageManager{executor: newFakeExecutor()},
+	}
+
+	_, err := resource.Run(t.Context())
+	require.Error(t, err)
+}
+
+func TestBatchPackages(t *testing.T) {
+	file := &fileResource{Path: "/tmp/file"}
+	a := &packageResource{Name: "a", State: "installed"}
+	b := &packageResource{Name: "b", State: "installed"}
+	c := &packageResource{Name: "c", State: "installed"}
+
+	runners := batchPackages([]runner{a, b, file, c})
+	require.Equal(t, []runner{&packageBatch{packages: []*packageResource{a, b}}, a, b, file, c}, runners)
+}
+
+func TestPackageResource_Run_UpdateCachePolicy(t *testing.T) {
+	mock := newMockPackageManager()
+
+	p := &packageResource{
+		Name:               "nginx",
+		State:              "installed",
+		defaultUpdateCache: "24h",
+		manager:            mock,
+	}
+
+	_, err := p.Run(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, []string{"24h"}, mock.updateCacheCalled)
+
+	// no install, so no update
+	_, err = p.Run(t.Context())
+	require.NoError(t, err)
+	require.Len(t, mock.updateCacheCalled, 1)
+
+	other := &packageResource{
+		Name:               "curl",
+		State:              "installed",
+		UpdateCache:        ptr("never"),
+		defaultUpdateCache: "24h",
+		manager:            mock,
+	}
+
+	_, err = other.Run(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, []string{"24h", "never"}, mock.updateCacheCalled)
+}
+
+func TestPackageResource_Run_UpdateCacheError(t *testing.T) {
+	mock := newMockPackageManager()
+	mock.updateCacheErr = errors.New("apt-get update failed")
+
+	p := &packageResource{
+		Name:    "nginx",
+		State:   "installed",
+		manager: mock,
+	}
+
+	_, err := p.Run(t.Context())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "apt-get update failed")
+	require.Empty(t, mock.installCalled)
+}
+
+func TestCacheNeedsUpdate(t *testing.T) {
+	age := func(d time.Duration) func() (time.Duration, error) {
+		return func() (time.Duration, error) {
+			return d, nil
+		}
+	}
+
+	needed, err := cacheNeedsUpdate("always", nil)
+	require.NoError(t, err)
+	require.True(t, needed)
+
+	needed, err = cacheNeedsUpdate("never", nil)
+	require.NoError(t, err)
+	require.False(t, needed)
+
+	needed, err = cacheNeedsUpdate("24h", age(time.Hour))
+	require.NoError(t, err)
+	require.False(t, needed)
+
+	needed, err = cacheNeedsUpdate("24h", age(48*time.Hour))
+	require.NoError(t, err)
+	require.True(t, needed)
+
+	_, err = cacheNeedsUpdate("sometimes", nil)
+	require.Error(t, err)
+}
+
+func TestAptPackageManager_UpdateCache_OncePerRun(t *testing.T) {
+	a := &aptPackageManager{cacheUpdated: true}
+
+	// would run apt-get update if the cache had not been updated
+	require.NoError(t, a.UpdateCache(t.Context(), "always"))
+}
@@ -0,0 +1,118 @@
+package tinyconf
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// zypperPackageManager manages packages using zypper on SUSE.
+// holds are package locks.
+type zypperPackageManager struct {
+	executor     commandExecutor
+	cacheUpdated bool
+}
+
+func (z *zypperPackageManager) run(ctx context.Context, action string, args ...string) ([]byte, error) {
+	output, err := z.executor.Run(ctx, "zypper", append([]string{"--non-interactive"}, args...)...)
+	if err != nil {
+		return output, fmt.Errorf("failed to %s (output: %s): %w", action, string(output), err)
+	}
+
+	return output, nil
+}
+
+func (z *zypperPackageManager) IsInstalled(ctx context.Context, packageName string) (bool, error) {
+	version, err := z.InstalledVersion(ctx, packageName)
+	return version != "", err
+}
+
+func (z *zypperPackageManager) InstalledVersion(ctx context.Context, packageName string) (string, error) {
+	return rpmInstalledVersion(ctx, z.executor, packageName)
+}
+
+func (z *zypperPackageManager) CandidateVersion(ctx context.Context, packageName string) (string, error) {
+	// the version is the newest available, not the installed version
+	output, err := z.run(ctx, "get candidate version for package "+packageName, "info", packageName)
+	if err != nil {
+		return "", err
+	}
+
+	return fieldValue(string(output), "Version"), nil
+}
+
+func (z *zypperPackageManager) Install(ctx context.Context, packageNames ...string) error {
+	_, err := z.run(ctx, "install package "+strings.Join(packageNames, " "),
+		append([]string{"install"}, packageNames...)...)
+	return err
+}
+
+func (z *zypperPackageManager) InstallVersion(ctx context.Context, packageName string, version string) error {
+	// --oldpackage allows downgrades
+	_, err := z.run(ctx, fmt.Sprintf("install package %s version %s", packageName, version),
+		"install", "--oldpackage", packageName+"="+version)
+	return err
+}
+
+func (z *zypperPackageManager) Upgrade(ctx context.Context, packageName string) error {
+	_, err := z.run(ctx, "upgrade package "+packageName, "update", packageName)
+	return err
+}
+
+func (z *zypperPackageManager) Uninstall(ctx context.Context, packageNames ...string) error {
+	_, err := z.run(ctx, "uninstall package "+strings.Join(packageNames, " "),
+		append([]string{"remove"}, packageNames...)...)
+	return err
+}
+
+func (z *zypperPackageManager) IsHeld(ctx context.Context, packageName string) (bool, error) {
+	output, err := z.run(ctx, "list package locks", "locks")
+	if err != nil {
+		return false, err
+	}
+
+	// # | Name  | Type    | Repository
+	// 1 | nginx | package | (any)
+	for line := range strings.Lines(string(output)) {
+		columns := strings.Split(line, "|")
+		if len(columns) > 1 && strings.TrimSpace(columns[1]) == packageName {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (z *zypperPackageManager) SetHold(ctx context.Context, packageName string, hold bool) error {
+	action := "removelock"
+	if hold {
+		action = "addlock"
+	}
+
+	_, err := z.run(ctx, fmt.Sprintf("%s for package %s", action, packageName), action, packageName)
+	return err
+}
+
+func (z *zypperPackageManager) UpdateCache(ctx context.Context, policy string) error {
+	if z.cacheUpdated {
+		return nil
+	}
+
+	needed, err := cacheNeedsUpdate(policy, func() (time.Duration, error) {
+		return pathAge("/var/cache/zypp/raw")
+	})
+	if err != nil || !needed {
+		return err
+	}
+
+	slog.Info("updating package cache", "provider", "zypper")
+	if _, err := z.run(ctx, "update package cache", "refresh"); err != nil {
+		return err
+	}
+
+	z.cacheUpdated = true
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package tinyconf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestZypperPackageManager_Versions(t *testing.T) {
+	executor := newFakeExecutor()
+	executor.results["rpm -q --qf %{VERSION}-%{RELEASE} nginx"] = fakeResult{output: "1.24.0-150500.1.1"}
+	executor.results["zypper --non-interactive info nginx"] = fakeResult{output: "Information for package nginx:\n-------------------------------\nRepository     : Main Repository\nName           : nginx\nVersion        : 1.26.0-150600.1.1\n"}
+
+	z := &zypperPackageManager{executor: executor}
+	ctx := t.Context()
+
+	version, err := z.InstalledVersion(ctx, "nginx")
+	require.NoError(t, err)
+	require.Equal(t, "1.24.0-150500.1.1", version)
+
+	version, err = z.CandidateVersion(ctx, "nginx")
+	require.NoError(t, err)
+	require.Equal(t, "1.26.0-150600.1.1", version)
+}
+
+func TestZypperPackageManager_Commands(t *testing.T) {
+	executor := newFakeExecutor()
+	z := &zypperPackageManager{executor: executor}
+	ctx := t.Context()
+
+	require.NoError(t, z.Install(ctx, "nginx", "curl"))
+	require.NoError(t, z.InstallVersion(ctx, "nginx", "1.24.0"))
+	require.NoError(t, z.Upgrade(ctx, "nginx"))
+	require.NoError(t, z.Uninstall(ctx, "curl"))
+	require.NoError(t, z.SetHold(ctx, "nginx", true))
+	require.NoError(t, z.SetHold(ctx, "nginx", false))
+	require.NoError(t, z.UpdateCache(ctx, "always"))
+	require.NoError(t, z.UpdateCache(ctx, "always"))
+
+	require.Equal(t, []string{
+		"zypper --non-interactive install nginx curl",
+		"zypper --non-interactive install --oldpackage nginx=1.24.0",
+		"zypper --non-interactive update nginx",
+		"zypper --non-interactive remove curl",
+		"zypper --non-interactive addlock nginx",
+		"zypper --non-interactive removelock nginx",
+		"zypper --non-interactive refresh",
+	}, executor.calls)
+}
+
+func TestZypperPackageManager_IsHeld(t *testing.T) {
+	executor := newFakeExecutor()
+	executor.results["zypper --non-interactive locks"] = fakeResult{output: "\n# | Name  | Type    | Repository\n--+-------+---------+-----------\n1 | nginx | package | (any)\n"}
+
+	z := &zypperPackageManager{executor: executor}
+	ctx := t.Context()
+
+	held, err := z.IsHeld(ctx, "nginx")
+	require.NoError(t, err)
+	require.True(t, held)
+
+	held, err = z.IsHeld(ctx, "curl")
+	require.NoError(t, err)
+	require.False(t, held)
+}
@@ -5,34 +5,69 @@ import (
 	"fmt"
 	"log/slog"
 	"os/exec"
+	"slices"
 	"strings"
 )
 
 type serviceNotifier interface {
 	Restart(context.Context, string) error
+	// restarts the service only if it is running
+	TryRestart(context.Context, string) error
+	Reload(context.Context, string) error
+	// reloads if the service supports it, otherwise restarts
+	ReloadOrRestart(context.Context, string) error
 }
 
 // this is not idempotent - caller should dedup services
-func notifyServices(ctx context.Context, notifier serviceNotifier, services []string) error {
+func notifyServices(ctx context.Context, notifier serviceNotifier, services []notifyResource) error {
 	if isNil(notifier) {
 		notifier = &systemdServiceManager{}
 	}
 
 	for _, service := range services {
-		slog.Info("restarting service", "name", service)
-		if err := notifier.Restart(ctx, service); err != nil {
+		var err error
+		switch service.Action {
+		case "reload":
+			slog.Info("reloading service", "name", service.Service)
+			err = notifier.Reload(ctx, service.Service)
+		case "reload-or-restart":
+			slog.Info("reloading or restarting service", "name", service.Service)
+			err = notifier.ReloadOrRestart(ctx, service.Service)
+		case "try-restart":
+			slog.Info("restarting service if running", "name", service.Service)
+			err = notifier.TryRestart(ctx, service.Service)
+		default:
+			slog.Info("restarting service", "name", service.Service)
+			err = notifier.Restart(ctx, service.Service)
+		}
+		if err != nil {
 			return err
 		}
+
+		if service.Healthcheck != nil {
+			if err := service.Healthcheck.wait(ctx, service.Service); err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
 }
 
 type serviceResource struct {
-	Name    string         `json:"name" validate:"required"`
-	State   string         `json:"state" validate:"required,oneof=running stopped"`
-	Notify  notifyResource `json:"notify"`
-	manager serviceManager
+	Name  string `json:"name" validate:"required"`
+	State string `json:"state" validate:"required,oneof=running stopped"`
+	// start the service at boot. unchanged when not set
+	Enabled *bool `json:"enabled"`
+	// masked services can not be started. when not set, a masked
+	// service is unmasked if it should be running
+	Masked *bool `json:"masked"`
+	// checked after the service is started
+	Healthcheck *healthcheck   `json:"healthcheck"`
+	Notify      notifyResource `json:"notify"`
+	manager     serviceManager
+	// set when the last run started or stopped the service
+	transitioned bool
 }
 
 // for testing
@@ -40,6 +75,18 @@ type serviceManager interface {
 	IsRunning(context.Context, string) (bool, error)
 	Start(context.Context, string) error
 	Stop(context.Context, string) error
+	IsEnabled(context.Context, string) (bool, error)
+	Enable(context.Context, string) error
+	Disable(context.Context, string) error
+	IsMasked(context.Context, string) (bool, error)
+	Mask(context.Context, string) error
+	Unmask(context.Context, string) error
+}
+
+// implemented by service managers where some services are started by other units
+// and can not be enabled or disabled themselves, such as static systemd units
+type staticServiceChecker interface {
+	IsStatic(context.Context, string) (bool, error)
 }
 
 func (s *serviceResource) Run(ctx context.Context) (string, error) {
@@ -47,7 +94,75 @@ func (s *serviceResource) Run(ctx context.Context) (string, error) {
 		s.manager = &systemdServiceManager{}
 	}
 
+	if s.Masked != nil && *s.Masked {
+		if s.State == "running" {
+			return "", fmt.Errorf("service %s can not be masked and running", s.Name)
+		}
+		if s.Enabled != nil && *s.Enabled {
+			return "", fmt.Errorf("service %s can not be masked and enabled", s.Name)
+		}
+	}
+
+	s.transitioned = false
+
 	tasks := []func() (bool, error){
+		func() (bool, error) {
+			// masking is done after the service is stopped
+			if s.Masked != nil && *s.Masked {
+				return false, nil
+			}
+
+			if s.Masked == nil && s.State != "running" {
+				return false, nil
+			}
+
+			isMasked, err := s.manager.IsMasked(ctx, s.Name)
+			if err != nil {
+				return false, fmt.Errorf("failed to get mask status for %s %w", s.Name, err)
+			}
+
+			if !isMasked {
+				return false, nil
+			}
+
+			slog.Info("unmasking service", "name", s.Name)
+			return true, s.manager.Unmask(ctx, s.Name)
+		},
+		// enabled before starting, as runit and s6 only supervise a service,
+		// and so can only start it, once it is linked into the scan directory
+		func() (bool, error) {
+			if s.Enabled == nil {
+				return false, nil
+			}
+
+			isEnabled, err := s.manager.IsEnabled(ctx, s.Name)
+			if err != nil {
+				return false, fmt.Errorf("failed to get enabled status for %s %w", s.Name, err)
+			}
+
+			if isEnabled == *s.Enabled {
+				return false, nil
+			}
+
+			if checker, ok := s.manager.(staticServiceChecker); ok {
+				static, err := checker.IsStatic(ctx, s.Name)
+				if err != nil {
+					return false, fmt.Errorf("failed to get enabled status for %s %w", s.Name, err)
+				}
+				if static {
+					slog.Info("service can not be enabled or disabled", "name", s.Name)
+					return false, nil
+				}
+			}
+
+			if *s.Enabled {
+				slog.Info("enabling service", "name", s.Name)
+				return true, s.manager.Enable(ctx, s.Name)
+			}
+
+			slog.Info("disabling service", "name", s.Name)
+			return true, s.manager.Disable(ctx, s.Name)
+		},
 		func() (bool, error) {
 			isRunning, err := s.manager.IsRunning(ctx, s.Name)
 			if err != nil {
@@ -61,19 +176,46 @@ func (s *serviceResource) Run(ctx context.Context) (string, error) {
 				}
 
 				slog.Info("starting service", "name", s.Name)
-				return true, s.manager.Start(ctx, s.Name)
+				s.transitioned = true
+				if err := s.manager.Start(ctx, s.Name); err != nil {
+					return true, err
+				}
+
+				if s.Healthcheck != nil {
+					return true, s.Healthcheck.wait(ctx, s.Name)
+				}
+
+				return true, nil
 			case "stopped":
 				if !isRunning {
 					return false, nil
 				}
 
 				slog.Info("stopping service", "name", s.Name)
+				s.transitioned = true
 				return true, s.manager.Stop(ctx, s.Name)
 			default:
 				// validation should catch this, but in case
 				return false, fmt.Errorf("unexpected service state %s", s.State)
 			}
 		},
+		func() (bool, error) {
+			if s.Masked == nil || !*s.Masked {
+				return false, nil
+			}
+
+			isMasked, err := s.manager.IsMasked(ctx, s.Name)
+			if err != nil {
+				return false, fmt.Errorf("failed to get mask status for %s %w", s.Name, err)
+			}
+
+			if isMasked {
+				return false, nil
+			}
+
+			slog.Info("masking service", "name", s.Name)
+			return true, s.manager.Mask(ctx, s.Name)
+		},
 	}
 
 	// use runTasks in case we add some debugging/logging/etc
@@ -89,25 +231,45 @@ func (s *serviceResource) Run(ctx context.Context) (string, error) {
 	return "", nil
 }
 
-type systemdServiceManager struct{}
+type systemdServiceManager struct {
+	// set when a unit file changes
+	daemonReloadNeeded bool
+}
 
-// TODO: clean this up. It got messy as I ran into some unexpected results
-// while testing installing and uninstalling multiple times
+func (s *systemdServiceManager) NeedDaemonReload() {
+	s.daemonReloadNeeded = true
+}
 
-// For some packages when you uninstall, the service is masked and you have to manually unmask them
-// so let's try to do that automatically.
-// XXX: don't think this is still needed since I "fixed" some thigns in package.go
-func (s *systemdServiceManager) unmaskIfNeeded(ctx context.Context, service string, output []byte, originalErr error) error {
-	if !strings.Contains(string(output), "masked") {
-		return originalErr
+// runs daemon-reload if any unit files changed since the last one, so
+// changes are batched into a single reload before services are started
+func (s *systemdServiceManager) DaemonReload(ctx context.Context) error {
+	if !s.daemonReloadNeeded {
+		return nil
 	}
 
-	slog.Info("unmasking service", "name", service)
-	unmaskCmd := exec.CommandContext(ctx, "systemctl", "unmask", service)
-	if unmaskOutput, unmaskErr := unmaskCmd.CombinedOutput(); unmaskErr != nil {
-		return fmt.Errorf("failed to unmask service %s: (output: %s) %w", service, string(unmaskOutput), unmaskErr)
+	slog.Info("reloading systemd units")
+	cmd := exec.CommandContext(ctx, "systemctl", "daemon-reload")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to reload systemd units: (output: %s) %w", string(output), err)
+	}
+
+	s.daemonReloadNeeded = false
+
+	return nil
+}
+
+func (s *systemdServiceManager) systemctl(ctx context.Context, action string, service string) error {
+	switch action {
+	case "start", "restart", "try-restart", "reload", "reload-or-restart", "enable":
+		if err := s.DaemonReload(ctx); err != nil {
+			return err
+		}
 	}
 
+	cmd := exec.CommandContext(ctx, "systemctl", action, service)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to %s service %s: (output: %s) %w", action, service, string(output), err)
+	}
 	return nil
 }
 
@@ -132,45 +294,88 @@ func (s *systemdServiceManager) IsRunning(ctx context.Context, service string) (
 }
 
 func (s *systemdServiceManager) Start(ctx context.Context, service string) error {
-	cmd := exec.CommandContext(ctx, "systemctl", "start", service)
-	output, err := cmd.CombinedOutput()
+	return s.systemctl(ctx, "start", service)
+}
+
+func (s *systemdServiceManager) Stop(ctx context.Context, service string) error {
+	return s.systemctl(ctx, "stop", service)
+}
+
+func (s *systemdServiceManager) Restart(ctx context.Context, service string) error {
+	return s.systemctl(ctx, "restart", service)
+}
+
+func (s *systemdServiceManager) TryRestart(ctx context.Context, service string) error {
+	return s.systemctl(ctx, "try-restart", service)
+}
+
+func (s *systemdServiceManager) Reload(ctx context.Context, service string) error {
+	return s.systemctl(ctx, "reload", service)
+}
+
+func (s *systemdServiceManager) ReloadOrRestart(ctx context.Context, service string) error {
+	return s.systemctl(ctx, "reload-or-restart", service)
+}
+
+// returns the unit file state from systemctl is-enabled, such as enabled, disabled, static, or masked
+func (s *systemdServiceManager) unitFileState(ctx context.Context, service string) (string, error) {
+	cmd := exec.CommandContext(ctx, "systemctl", "is-enabled", service)
+	output, err := cmd.Output()
+	state := strings.TrimSpace(string(output))
+	// is-enabled exits non-zero for anything that is not enabled, but still prints the state
+	if err != nil && state == "" {
+		return "", fmt.Errorf("failed to check service %s unit file state: %w", service, err)
+	}
+
+	return state, nil
+}
+
+// units that are started at boot, or can not be enabled or disabled directly
+var systemdEnabledStates = []string{"enabled", "enabled-runtime", "alias", "static", "indirect", "generated", "transient"}
+
+// units without an install section, or that are enabled through another unit or a generator.
+// systemctl disable does nothing for these, so they are left alone.
+var systemdStaticStates = []string{"alias", "static", "indirect", "generated", "transient"}
+
+func (s *systemdServiceManager) IsEnabled(ctx context.Context, service string) (bool, error) {
+	state, err := s.unitFileState(ctx, service)
 	if err != nil {
-		// Try unmasking if the service is masked
-		if unmaskErr := s.unmaskIfNeeded(ctx, service, output, nil); unmaskErr == nil {
-			// Service was masked and successfully unmasked, retry
-			retryCmd := exec.CommandContext(ctx, "systemctl", "start", service)
-			if retryOutput, retryErr := retryCmd.CombinedOutput(); retryErr != nil {
-				return fmt.Errorf("failed to start service %s after unmasking: (output: %s) %w", service, string(retryOutput), retryErr)
-			}
-			return nil
-		}
-		return fmt.Errorf("failed to start service %s: (output: %s) %w", service, string(output), err)
+		return false, err
 	}
-	return nil
+
+	return slices.Contains(systemdEnabledStates, state), nil
 }
 
-func (s *systemdServiceManager) Stop(ctx context.Context, service string) error {
-	cmd := exec.CommandContext(ctx, "systemctl", "stop", service)
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to stop service %s: (output: %s) %w", service, string(output), err)
+func (s *systemdServiceManager) IsStatic(ctx context.Context, service string) (bool, error) {
+	state, err := s.unitFileState(ctx, service)
+	if err != nil {
+		return false, err
 	}
-	return nil
+
+	return slices.Contains(systemdStaticStates, state), nil
 }
 
-func (s *systemdServiceManager) Restart(ctx context.Context, service string) error {
-	cmd := exec.CommandContext(ctx, "systemctl", "restart", service)
-	output, err := cmd.CombinedOutput()
+func (s *systemdServiceManager) Enable(ctx context.Context, service string) error {
+	return s.systemctl(ctx, "enable", service)
+}
+
+func (s *systemdServiceManager) Disable(ctx context.Context, service string) error {
+	return s.systemctl(ctx, "disable", service)
+}
+
+func (s *systemdServiceManager) IsMasked(ctx context.Context, service string) (bool, error) {
+	state, err := s.unitFileState(ctx, service)
 	if err != nil {
-		// Try unmasking if the service is masked
-		if unmaskErr := s.unmaskIfNeeded(ctx, service, output, nil); unmaskErr == nil {
-			// Service was masked and successfully unmasked, retry
-			retryCmd := exec.CommandContext(ctx, "systemctl", "restart", service)
-			if retryOutput, retryErr := retryCmd.CombinedOutput(); retryErr != nil {
-				return fmt.Errorf("failed to restart service %s after unmasking: (output: %s) %w", service, string(retryOutput), retryErr)
-			}
-			return nil
-		}
-		return fmt.Errorf("failed to restart service %s: (output: %s) %w", service, string(output), err)
+		return false, err
 	}
-	return nil
+
+	return state == "masked" || state == "masked-runtime", nil
+}
+
+func (s *systemdServiceManager) Mask(ctx context.Context, service string) error {
+	return s.systemctl(ctx, "mask", service)
+}
+
+func (s *systemdServiceManager) Unmask(ctx context.Context, service string) error {
+	return s.systemctl(ctx, "unmask", service)
 }
@@ -0,0 +1,87 @@
+package tinyconf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// runsvdir scans for new services every 5 seconds
+const supervisorStartTimeout = 15 * time.Second
+
+// manages services and sends notifications using an init system
+type initSystem interface {
+	serviceManager
+	serviceNotifier
+}
+
+// returns the init system for the host, checking paths under root.
+// defaults to systemd if it can not be determined.
+func detectInitSystem(root string) string {
+	exists := func(path string) bool {
+		_, err := os.Stat(filepath.Join(root, path))
+		return err == nil
+	}
+
+	switch {
+	// the same check as sd_booted
+	case exists("/run/systemd/system"):
+		return "systemd"
+	case exists("/run/openrc"):
+		return "openrc"
+	case exists("/run/runit") || exists("/etc/runit/runsvdir"):
+		return "runit"
+	// s6-svscan keeps its control files in the scan directory
+	case exists("/run/service/.s6-svscan") || exists("/service/.s6-svscan"):
+		return "s6"
+	case exists("/etc/init.d"):
+		return "sysv"
+	default:
+		return "systemd"
+	}
+}
+
+// unknown init systems use systemd, but should be caught by validation
+func newInitSystem(name string) initSystem {
+	executor := &osCommandExecutor{}
+
+	switch name {
+	case "openrc":
+		return &openrcServiceManager{executor: executor}
+	case "runit":
+		return &runitServiceManager{executor: executor}
+	case "s6":
+		return &s6ServiceManager{executor: executor}
+	case "sysv":
+		return &sysvServiceManager{executor: executor}
+	default:
+		return &systemdServiceManager{}
+	}
+}
+
+// waits for the supervisor to create path, such as supervise/ok, after a service is
+// linked into the scan directory. commands sent before then fail as the service is not supervised yet.
+func waitForSupervisor(ctx context.Context, service string, path string) error {
+	ctx, cancel := context.WithTimeout(ctx, supervisorStartTimeout)
+	defer cancel()
+
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("service %s was not supervised after %s, %s does not exist", service, supervisorStartTimeout, path)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// masking is a systemd feature
+func maskUnsupported() error {
+	return errors.New("masking services is only supported by systemd")
+}
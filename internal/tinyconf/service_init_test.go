@@ -0,0 +1,36 @@
+package tinyconf
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectInitSystem(t *testing.T) {
+	for expected, paths := range map[string][]string{
+		"systemd": {"run/systemd/system", "etc/init.d"},
+		"openrc":  {"run/openrc", "etc/init.d"},
+		"runit":   {"etc/runit/runsvdir"},
+		"s6":      {"run/service/.s6-svscan", "etc/init.d"},
+		"sysv":    {"etc/init.d"},
+	} {
+		root := t.TempDir()
+		for _, path := range paths {
+			require.NoError(t, os.MkdirAll(filepath.Join(root, path), 0755))
+		}
+
+		require.Equal(t, expected, detectInitSystem(root), expected)
+	}
+
+	require.Equal(t, "systemd", detectInitSystem(t.TempDir()))
+}
+
+func TestNewInitSystem(t *testing.T) {
+	require.IsType(t, &systemdServiceManager{}, newInitSystem("systemd"))
+	require.IsType(t, &openrcServiceManager{}, newInitSystem("openrc"))
+	require.IsType(t, &runitServiceManager{}, newInitSystem("runit"))
+	require.IsType(t, &s6ServiceManager{}, newInitSystem("s6"))
+	require.IsType(t, &sysvServiceManager{}, newInitSystem("sysv"))
+}
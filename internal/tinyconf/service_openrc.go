@@ -0,0 +1,121 @@
+package tinyconf
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+// openrcServiceManager manages services using rc-service and rc-update, as used by Alpine.
+// services are enabled in the default runlevel.
+type openrcServiceManager struct {
+	executor commandExecutor
+}
+
+func (o *openrcServiceManager) rcService(ctx context.Context, action string, service string) error {
+	output, err := o.executor.Run(ctx, "rc-service", service, action)
+	if err != nil {
+		return fmt.Errorf("failed to %s service %s: (output: %s) %w", action, service, string(output), err)
+	}
+	return nil
+}
+
+func (o *openrcServiceManager) IsRunning(ctx context.Context, service string) (bool, error) {
+	output, err := o.executor.Run(ctx, "rc-service", service, "status")
+	if err != nil {
+		// status exits 3 when stopped. other codes are for crashed or inactive
+		// services, or services that do not exist, which can not just be started
+		if exitCode(err) == 3 {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check service %s status: (output: %s) %w", service, string(output), err)
+	}
+
+	return true, nil
+}
+
+func (o *openrcServiceManager) Start(ctx context.Context, service string) error {
+	return o.rcService(ctx, "start", service)
+}
+
+func (o *openrcServiceManager) Stop(ctx context.Context, service string) error {
+	return o.rcService(ctx, "stop", service)
+}
+
+func (o *openrcServiceManager) Restart(ctx context.Context, service string) error {
+	return o.rcService(ctx, "restart", service)
+}
+
+// --ifstarted skips the restart if the service is not started
+func (o *openrcServiceManager) TryRestart(ctx context.Context, service string) error {
+	output, err := o.executor.Run(ctx, "rc-service", "--ifstarted", service, "restart")
+	if err != nil {
+		return fmt.Errorf("failed to restart service %s: (output: %s) %w", service, string(output), err)
+	}
+	return nil
+}
+
+func (o *openrcServiceManager) Reload(ctx context.Context, service string) error {
+	return o.rcService(ctx, "reload", service)
+}
+
+// not all init scripts support reload
+func (o *openrcServiceManager) ReloadOrRestart(ctx context.Context, service string) error {
+	if err := o.Reload(ctx, service); err != nil {
+		slog.Info("reload failed, restarting service", "name", service, "error", err)
+		return o.Restart(ctx, service)
+	}
+	return nil
+}
+
+func (o *openrcServiceManager) IsEnabled(ctx context.Context, service string) (bool, error) {
+	output, err := o.executor.Run(ctx, "rc-update", "show", "default")
+	if err != nil {
+		return false, fmt.Errorf("failed to list services in the default runlevel: (output: %s) %w", string(output), err)
+	}
+
+	return parseRcUpdateShow(string(output), service), nil
+}
+
+// returns whether the service is in the default runlevel in rc-update show output, such as
+// sshd | boot default
+func parseRcUpdateShow(output string, service string) bool {
+	for line := range strings.Lines(output) {
+		name, runlevels, ok := strings.Cut(line, "|")
+		if ok && strings.TrimSpace(name) == service {
+			return slices.Contains(strings.Fields(runlevels), "default")
+		}
+	}
+
+	return false
+}
+
+func (o *openrcServiceManager) Enable(ctx context.Context, service string) error {
+	output, err := o.executor.Run(ctx, "rc-update", "add", service, "default")
+	if err != nil {
+		return fmt.Errorf("failed to enable service %s: (output: %s) %w", service, string(output), err)
+	}
+	return nil
+}
+
+func (o *openrcServiceManager) Disable(ctx context.Context, service string) error {
+	output, err := o.executor.Run(ctx, "rc-update", "del", service, "default")
+	if err != nil {
+		return fmt.Errorf("failed to disable service %s: (output: %s) %w", service, string(output), err)
+	}
+	return nil
+}
+
+func (o *openrcServiceManager) IsMasked(ctx context.Context, service string) (bool, error) {
+	return false, nil
+}
+
+func (o *openrcServiceManager) Mask(ctx context.Context, service string) error {
+	return maskUnsupported()
+}
+
+func (o *openrcServiceManager) Unmask(ctx context.Context, service string) error {
+	return nil
+}
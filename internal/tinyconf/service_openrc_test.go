@@ -0,0 +1,87 @@
+package tinyconf
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRcUpdateShow(t *testing.T) {
+	output := `             crond |      default
+          networking | boot
+               sshd | boot default
+`
+	require.True(t, parseRcUpdateShow(output, "crond"))
+	require.True(t, parseRcUpdateShow(output, "sshd"))
+	require.False(t, parseRcUpdateShow(output, "networking"))
+	require.False(t, parseRcUpdateShow(output, "nginx"))
+}
+
+func TestOpenrcServiceManager_IsRunning(t *testing.T) {
+	executor := newFakeExecutor()
+	executor.results["rc-service nginx status"] = fakeResult{output: " * status: stopped\n", err: fakeExitError(3)}
+	executor.results["rc-service crond status"] = fakeResult{output: " * status: started\n"}
+
+	o := &openrcServiceManager{executor: executor}
+
+	running, err := o.IsRunning(t.Context(), "nginx")
+	require.NoError(t, err)
+	require.False(t, running)
+
+	running, err = o.IsRunning(t.Context(), "crond")
+	require.NoError(t, err)
+	require.True(t, running)
+
+	executor.results["rc-service missing status"] = fakeResult{output: " * rc-service: service `missing' does not exist\n", err: fakeExitError(1)}
+	_, err = o.IsRunning(t.Context(), "missing")
+	require.Error(t, err)
+}
+
+func TestOpenrcServiceManager_Commands(t *testing.T) {
+	executor := newFakeExecutor()
+	executor.results["rc-update show default"] = fakeResult{output: "  crond | default\n"}
+	executor.results["rc-service nginx reload"] = fakeResult{output: "reload not supported", err: fakeExitError(1)}
+
+	o := &openrcServiceManager{executor: executor}
+	ctx := t.Context()
+
+	enabled, err := o.IsEnabled(ctx, "nginx")
+	require.NoError(t, err)
+	require.False(t, enabled)
+
+	require.NoError(t, o.Enable(ctx, "nginx"))
+	require.NoError(t, o.Start(ctx, "nginx"))
+	require.NoError(t, o.ReloadOrRestart(ctx, "nginx"))
+	require.NoError(t, o.TryRestart(ctx, "nginx"))
+	require.NoError(t, o.Stop(ctx, "nginx"))
+	require.NoError(t, o.Disable(ctx, "nginx"))
+
+	require.Equal(t, []string{
+		"rc-update show default",
+		"rc-update add nginx default",
+		"rc-service nginx start",
+		"rc-service nginx reload",
+		"rc-service nginx restart",
+		"rc-service --ifstarted nginx restart",
+		"rc-service nginx stop",
+		"rc-update del nginx default",
+	}, executor.calls)
+
+	masked, err := o.IsMasked(ctx, "nginx")
+	require.NoError(t, err)
+	require.False(t, masked)
+	require.Error(t, o.Mask(ctx, "nginx"))
+	require.NoError(t, o.Unmask(ctx, "nginx"))
+}
+
+func TestOpenrcServiceManager_StartError(t *testing.T) {
+	executor := newFakeExecutor()
+	executor.results["rc-service nginx start"] = fakeResult{output: "nginx: failed", err: errors.New("exit status 1")}
+
+	o := &openrcServiceManager{executor: executor}
+
+	err := o.Start(t.Context(), "nginx")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "nginx: failed")
+}
@@ -0,0 +1,155 @@
+package tinyconf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// service definitions
+const defaultRunitSvDir = "/etc/sv"
+
+// directories runsvdir supervises, in the order they are checked.
+// Void uses /var/service and Debian uses /etc/service
+var runitServiceDirs = []string{"/var/service", "/etc/service", "/service"}
+
+// runitServiceManager manages services using sv. services are enabled by
+// linking their definition in /etc/sv into the supervised directory.
+type runitServiceManager struct {
+	executor commandExecutor
+	// for testing
+	svDir      string
+	serviceDir string
+}
+
+func (r *runitServiceManager) definition(service string) string {
+	dir := r.svDir
+	if dir == "" {
+		dir = defaultRunitSvDir
+	}
+
+	return filepath.Join(dir, service)
+}
+
+// returns the path of the service in the supervised directory
+func (r *runitServiceManager) supervised(service string) string {
+	dir := r.serviceDir
+	if dir == "" {
+		dir = runitServiceDirs[len(runitServiceDirs)-1]
+		for _, d := range runitServiceDirs {
+			if _, err := os.Stat(d); err == nil {
+				dir = d
+				break
+			}
+		}
+	}
+
+	return filepath.Join(dir, service)
+}
+
+func (r *runitServiceManager) sv(ctx context.Context, action string, service string) error {
+	output, err := r.executor.Run(ctx, "sv", action, r.supervised(service))
+	if err != nil {
+		return fmt.Errorf("failed to %s service %s: (output: %s) %w", action, service, string(output), err)
+	}
+	return nil
+}
+
+func (r *runitServiceManager) IsRunning(ctx context.Context, service string) (bool, error) {
+	output, err := r.executor.Run(ctx, "sv", "status", r.supervised(service))
+
+	// run: nginx: (pid 123) 45s; down: nginx: 3s, normally up
+	// fail means the service is not supervised
+	status := string(output)
+	switch {
+	case strings.HasPrefix(status, "run:"):
+		return true, nil
+	case strings.HasPrefix(status, "down:"), strings.HasPrefix(status, "fail:"):
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("failed to check service %s status: (output: %s) %w", service, status, err)
+	}
+
+	return false, fmt.Errorf("unexpected status for service %s: %s", service, status)
+}
+
+func (r *runitServiceManager) Start(ctx context.Context, service string) error {
+	return r.sv(ctx, "start", service)
+}
+
+func (r *runitServiceManager) Stop(ctx context.Context, service string) error {
+	return r.sv(ctx, "stop", service)
+}
+
+func (r *runitServiceManager) Restart(ctx context.Context, service string) error {
+	return r.sv(ctx, "restart", service)
+}
+
+func (r *runitServiceManager) TryRestart(ctx context.Context, service string) error {
+	return r.sv(ctx, "try-restart", service)
+}
+
+// sends HUP to the service
+func (r *runitServiceManager) Reload(ctx context.Context, service string) error {
+	return r.sv(ctx, "reload", service)
+}
+
+// runit can always send HUP, so there is nothing to fall back to
+func (r *runitServiceManager) ReloadOrRestart(ctx context.Context, service string) error {
+	return r.Reload(ctx, service)
+}
+
+func (r *runitServiceManager) IsEnabled(ctx context.Context, service string) (bool, error) {
+	path := r.supervised(service)
+	if _, err := os.Lstat(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to stat %s %w", path, err)
+	}
+
+	return true, nil
+}
+
+func (r *runitServiceManager) Enable(ctx context.Context, service string) error {
+	definition := r.definition(service)
+	if _, err := os.Stat(definition); err != nil {
+		return fmt.Errorf("failed to enable service %s %w", service, err)
+	}
+
+	path := r.supervised(service)
+	slog.Info("linking service", "name", service, "path", path)
+	if err := os.Symlink(definition, path); err != nil {
+		return fmt.Errorf("failed to enable service %s %w", service, err)
+	}
+
+	// sv needs runsv to be running for the service
+	return waitForSupervisor(ctx, service, filepath.Join(path, "supervise", "ok"))
+}
+
+// runsvdir stops the service when the link is removed
+func (r *runitServiceManager) Disable(ctx context.Context, service string) error {
+	path := r.supervised(service)
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to disable service %s %w", service, err)
+	}
+	return nil
+}
+
+func (r *runitServiceManager) IsMasked(ctx context.Context, service string) (bool, error) {
+	return false, nil
+}
+
+func (r *runitServiceManager) Mask(ctx context.Context, service string) error {
+	return maskUnsupported()
+}
+
+func (r *runitServiceManager) Unmask(ctx context.Context, service string) error {
+	return nil
+}
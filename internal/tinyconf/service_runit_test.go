@@ -0,0 +1,128 @@
+package tinyconf
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunitServiceManager_IsRunning(t *testing.T) {
+	serviceDir := t.TempDir()
+
+	executor := newFakeExecutor()
+	executor.results["sv status "+filepath.Join(serviceDir, "nginx")] = fakeResult{output: "run: " + filepath.Join(serviceDir, "nginx") + ": (pid 123) 45s\n"}
+	executor.results["sv status "+filepath.Join(serviceDir, "crond")] = fakeResult{output: "down: " + filepath.Join(serviceDir, "crond") + ": 3s, normally up\n", err: fakeExitError(3)}
+	executor.results["sv status "+filepath.Join(serviceDir, "sshd")] = fakeResult{output: "fail: sshd: unable to change to service directory: file does not exist\n", err: fakeExitError(1)}
+	executor.results["sv status "+filepath.Join(serviceDir, "bad")] = fakeResult{output: "warning: bad: unable to open supervise/ok\n", err: fakeExitError(1)}
+
+	r := &runitServiceManager{executor: executor, serviceDir: serviceDir}
+	ctx := t.Context()
+
+	running, err := r.IsRunning(ctx, "nginx")
+	require.NoError(t, err)
+	require.True(t, running)
+
+	running, err = r.IsRunning(ctx, "crond")
+	require.NoError(t, err)
+	require.False(t, running)
+
+	running, err = r.IsRunning(ctx, "sshd")
+	require.NoError(t, err)
+	require.False(t, running)
+
+	_, err = r.IsRunning(ctx, "bad")
+	require.Error(t, err)
+}
+
+func TestRunitServiceManager_EnableDisable(t *testing.T) {
+	svDir := t.TempDir()
+	serviceDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(svDir, "nginx", "supervise"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(svDir, "nginx", "supervise", "ok"), nil, 0600))
+
+	r := &runitServiceManager{executor: newFakeExecutor(), svDir: svDir, serviceDir: serviceDir}
+	ctx := t.Context()
+
+	enabled, err := r.IsEnabled(ctx, "nginx")
+	require.NoError(t, err)
+	require.False(t, enabled)
+
+	require.NoError(t, r.Enable(ctx, "nginx"))
+
+	target, err := os.Readlink(filepath.Join(serviceDir, "nginx"))
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(svDir, "nginx"), target)
+
+	enabled, err = r.IsEnabled(ctx, "nginx")
+	require.NoError(t, err)
+	require.True(t, enabled)
+
+	require.NoError(t, r.Disable(ctx, "nginx"))
+
+	enabled, err = r.IsEnabled(ctx, "nginx")
+	require.NoError(t, err)
+	require.False(t, enabled)
+
+	// no definition to link
+	require.Error(t, r.Enable(ctx, "crond"))
+}
+
+func TestServiceResource_Run_RunitEnableThenStart(t *testing.T) {
+	svDir := t.TempDir()
+	serviceDir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(svDir, "nginx"), 0755))
+
+	path := filepath.Join(serviceDir, "nginx")
+	executor := newFakeExecutor()
+	executor.results["sv status "+path] = fakeResult{output: "down: " + path + ": 0s, normally up\n", err: fakeExitError(3)}
+
+	resource := &serviceResource{
+		Name:    "nginx",
+		State:   "running",
+		Enabled: ptr(true),
+		manager: &runitServiceManager{executor: executor, svDir: svDir, serviceDir: serviceDir},
+	}
+
+	// runsv starts supervising the service some time after it is linked
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		_ = os.Mkdir(filepath.Join(svDir, "nginx", "supervise"), 0755)
+		_ = os.WriteFile(filepath.Join(svDir, "nginx", "supervise", "ok"), nil, 0600)
+	}()
+
+	_, err := resource.Run(t.Context())
+	require.NoError(t, err)
+
+	target, err := os.Readlink(path)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(svDir, "nginx"), target)
+	require.Equal(t, []string{"sv status " + path, "sv start " + path}, executor.calls)
+}
+
+func TestRunitServiceManager_Commands(t *testing.T) {
+	serviceDir := t.TempDir()
+	executor := newFakeExecutor()
+
+	r := &runitServiceManager{executor: executor, serviceDir: serviceDir}
+	ctx := t.Context()
+
+	require.NoError(t, r.Start(ctx, "nginx"))
+	require.NoError(t, r.ReloadOrRestart(ctx, "nginx"))
+	require.NoError(t, r.Restart(ctx, "nginx"))
+	require.NoError(t, r.TryRestart(ctx, "nginx"))
+	require.NoError(t, r.Stop(ctx, "nginx"))
+
+	path := filepath.Join(serviceDir, "nginx")
+	require.Equal(t, []string{
+		"sv start " + path,
+		"sv reload " + path,
+		"sv restart " + path,
+		"sv try-restart " + path,
+		"sv stop " + path,
+	}, executor.calls)
+
+	require.Error(t, r.Mask(ctx, "nginx"))
+}
@@ -0,0 +1,190 @@
+package tinyconf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// service definitions
+const defaultS6SvDir = "/etc/s6/sv"
+
+// scan directories s6-svscan supervises, in the order they are checked.
+// s6-linux-init uses /run/service
+var s6ScanDirs = []string{"/run/service", "/service"}
+
+// s6ServiceManager manages services using s6-svc. services are enabled by
+// linking their definition in /etc/s6/sv into the scan directory.
+type s6ServiceManager struct {
+	executor commandExecutor
+	// for testing
+	svDir   string
+	scanDir string
+}
+
+func (s *s6ServiceManager) definition(service string) string {
+	dir := s.svDir
+	if dir == "" {
+		dir = defaultS6SvDir
+	}
+
+	return filepath.Join(dir, service)
+}
+
+func (s *s6ServiceManager) scanDirectory() string {
+	if s.scanDir != "" {
+		return s.scanDir
+	}
+
+	for _, d := range s6ScanDirs {
+		if _, err := os.Stat(d); err == nil {
+			return d
+		}
+	}
+
+	return s6ScanDirs[len(s6ScanDirs)-1]
+}
+
+// returns the path of the service in the scan directory
+func (s *s6ServiceManager) supervised(service string) string {
+	return filepath.Join(s.scanDirectory(), service)
+}
+
+func (s *s6ServiceManager) svc(ctx context.Context, action string, flag string, service string) error {
+	output, err := s.executor.Run(ctx, "s6-svc", flag, s.supervised(service))
+	if err != nil {
+		return fmt.Errorf("failed to %s service %s: (output: %s) %w", action, service, string(output), err)
+	}
+	return nil
+}
+
+// tells s6-svscan to pick up added and removed services
+func (s *s6ServiceManager) rescan(ctx context.Context) error {
+	dir := s.scanDirectory()
+	output, err := s.executor.Run(ctx, "s6-svscanctl", "-an", dir)
+	if err != nil {
+		return fmt.Errorf("failed to rescan %s: (output: %s) %w", dir, string(output), err)
+	}
+	return nil
+}
+
+func (s *s6ServiceManager) IsRunning(ctx context.Context, service string) (bool, error) {
+	// services that are not in the scan directory are not supervised
+	enabled, err := s.IsEnabled(ctx, service)
+	if err != nil || !enabled {
+		return false, err
+	}
+
+	// prints true or false
+	output, err := s.executor.Run(ctx, "s6-svstat", "-u", s.supervised(service))
+	if err != nil {
+		// exits 1 when s6-supervise is not running for the service yet
+		if exitCode(err) == 1 {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check service %s status: (output: %s) %w", service, string(output), err)
+	}
+
+	switch strings.TrimSpace(string(output)) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	default:
+		return false, fmt.Errorf("unexpected status for service %s: %s", service, string(output))
+	}
+}
+
+func (s *s6ServiceManager) Start(ctx context.Context, service string) error {
+	return s.svc(ctx, "start", "-u", service)
+}
+
+func (s *s6ServiceManager) Stop(ctx context.Context, service string) error {
+	return s.svc(ctx, "stop", "-d", service)
+}
+
+// -r only signals a service that is up, so stopped services are started instead
+func (s *s6ServiceManager) Restart(ctx context.Context, service string) error {
+	running, err := s.IsRunning(ctx, service)
+	if err != nil {
+		return err
+	}
+
+	if !running {
+		return s.Start(ctx, service)
+	}
+
+	return s.TryRestart(ctx, service)
+}
+
+func (s *s6ServiceManager) TryRestart(ctx context.Context, service string) error {
+	return s.svc(ctx, "restart", "-r", service)
+}
+
+// sends HUP to the service
+func (s *s6ServiceManager) Reload(ctx context.Context, service string) error {
+	return s.svc(ctx, "reload", "-h", service)
+}
+
+// s6 can always send HUP, so there is nothing to fall back to
+func (s *s6ServiceManager) ReloadOrRestart(ctx context.Context, service string) error {
+	return s.Reload(ctx, service)
+}
+
+func (s *s6ServiceManager) IsEnabled(ctx context.Context, service string) (bool, error) {
+	path := s.supervised(service)
+	if _, err := os.Lstat(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to stat %s %w", path, err)
+	}
+
+	return true, nil
+}
+
+func (s *s6ServiceManager) Enable(ctx context.Context, service string) error {
+	definition := s.definition(service)
+	if _, err := os.Stat(definition); err != nil {
+		return fmt.Errorf("failed to enable service %s %w", service, err)
+	}
+
+	path := s.supervised(service)
+	slog.Info("linking service", "name", service, "path", path)
+	if err := os.Symlink(definition, path); err != nil {
+		return fmt.Errorf("failed to enable service %s %w", service, err)
+	}
+
+	if err := s.rescan(ctx); err != nil {
+		return err
+	}
+
+	// s6-svc needs s6-supervise to be running for the service
+	return waitForSupervisor(ctx, service, filepath.Join(path, "supervise", "control"))
+}
+
+// s6-svscan stops the service when the link is removed and it rescans
+func (s *s6ServiceManager) Disable(ctx context.Context, service string) error {
+	path := s.supervised(service)
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to disable service %s %w", service, err)
+	}
+
+	return s.rescan(ctx)
+}
+
+func (s *s6ServiceManager) IsMasked(ctx context.Context, service string) (bool, error) {
+	return false, nil
+}
+
+func (s *s6ServiceManager) Mask(ctx context.Context, service string) error {
+	return maskUnsupported()
+}
+
+func (s *s6ServiceManager) Unmask(ctx context.Context, service string) error {
+	return nil
+}
@@ -0,0 +1,154 @@
+package tinyconf
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestS6ServiceManager_IsRunning(t *testing.T) {
+	scanDir := t.TempDir()
+	for _, service := range []string{"nginx", "crond", "sshd", "bad"} {
+		require.NoError(t, os.Mkdir(filepath.Join(scanDir, service), 0755))
+	}
+
+	executor := newFakeExecutor()
+	executor.results["s6-svstat -u "+filepath.Join(scanDir, "nginx")] = fakeResult{output: "true\n"}
+	executor.results["s6-svstat -u "+filepath.Join(scanDir, "crond")] = fakeResult{output: "false\n"}
+	executor.results["s6-svstat -u "+filepath.Join(scanDir, "sshd")] = fakeResult{output: "s6-svstat: fatal: unable to read status: supervisor not listening\n", err: fakeExitError(1)}
+	executor.results["s6-svstat -u "+filepath.Join(scanDir, "bad")] = fakeResult{output: "s6-svstat: fatal: unable to read status: Permission denied\n", err: fakeExitError(111)}
+
+	s := &s6ServiceManager{executor: executor, scanDir: scanDir}
+	ctx := t.Context()
+
+	running, err := s.IsRunning(ctx, "nginx")
+	require.NoError(t, err)
+	require.True(t, running)
+
+	running, err = s.IsRunning(ctx, "crond")
+	require.NoError(t, err)
+	require.False(t, running)
+
+	running, err = s.IsRunning(ctx, "sshd")
+	require.NoError(t, err)
+	require.False(t, running)
+
+	_, err = s.IsRunning(ctx, "bad")
+	require.Error(t, err)
+
+	// not in the scan directory, so not supervised
+	running, err = s.IsRunning(ctx, "mysql")
+	require.NoError(t, err)
+	require.False(t, running)
+	require.NotContains(t, executor.calls, "s6-svstat -u "+filepath.Join(scanDir, "mysql"))
+}
+
+func TestS6ServiceManager_EnableDisable(t *testing.T) {
+	svDir := t.TempDir()
+	scanDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(svDir, "nginx", "supervise"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(svDir, "nginx", "supervise", "control"), nil, 0600))
+
+	executor := newFakeExecutor()
+	s := &s6ServiceManager{executor: executor, svDir: svDir, scanDir: scanDir}
+	ctx := t.Context()
+
+	enabled, err := s.IsEnabled(ctx, "nginx")
+	require.NoError(t, err)
+	require.False(t, enabled)
+
+	require.NoError(t, s.Enable(ctx, "nginx"))
+
+	target, err := os.Readlink(filepath.Join(scanDir, "nginx"))
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(svDir, "nginx"), target)
+
+	enabled, err = s.IsEnabled(ctx, "nginx")
+	require.NoError(t, err)
+	require.True(t, enabled)
+
+	require.NoError(t, s.Disable(ctx, "nginx"))
+
+	enabled, err = s.IsEnabled(ctx, "nginx")
+	require.NoError(t, err)
+	require.False(t, enabled)
+
+	require.Equal(t, []string{
+		"s6-svscanctl -an " + scanDir,
+		"s6-svscanctl -an " + scanDir,
+	}, executor.calls)
+
+	// no definition to link
+	require.Error(t, s.Enable(ctx, "crond"))
+}
+
+func TestServiceResource_Run_S6EnableThenStart(t *testing.T) {
+	svDir := t.TempDir()
+	scanDir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(svDir, "nginx"), 0755))
+
+	path := filepath.Join(scanDir, "nginx")
+	executor := newFakeExecutor()
+	executor.results["s6-svstat -u "+path] = fakeResult{output: "false\n"}
+
+	resource := &serviceResource{
+		Name:    "nginx",
+		State:   "running",
+		Enabled: ptr(true),
+		manager: &s6ServiceManager{executor: executor, svDir: svDir, scanDir: scanDir},
+	}
+
+	// s6-supervise is started by the rescan, some time after the link is made
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		_ = os.Mkdir(filepath.Join(svDir, "nginx", "supervise"), 0755)
+		_ = os.WriteFile(filepath.Join(svDir, "nginx", "supervise", "control"), nil, 0600)
+	}()
+
+	_, err := resource.Run(t.Context())
+	require.NoError(t, err)
+
+	require.Equal(t, []string{
+		"s6-svscanctl -an " + scanDir,
+		"s6-svstat -u " + path,
+		"s6-svc -u " + path,
+	}, executor.calls)
+}
+
+func TestS6ServiceManager_Commands(t *testing.T) {
+	scanDir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(scanDir, "nginx"), 0755))
+	path := filepath.Join(scanDir, "nginx")
+
+	executor := newFakeExecutor()
+	executor.results["s6-svstat -u "+path] = fakeResult{output: "true\n"}
+
+	s := &s6ServiceManager{executor: executor, scanDir: scanDir}
+	ctx := t.Context()
+
+	require.NoError(t, s.Start(ctx, "nginx"))
+	require.NoError(t, s.ReloadOrRestart(ctx, "nginx"))
+	require.NoError(t, s.Restart(ctx, "nginx"))
+	require.NoError(t, s.TryRestart(ctx, "nginx"))
+	require.NoError(t, s.Stop(ctx, "nginx"))
+
+	// stopped services are started rather than signalled
+	executor.results["s6-svstat -u "+path] = fakeResult{output: "false\n"}
+	require.NoError(t, s.Restart(ctx, "nginx"))
+
+	require.Equal(t, []string{
+		"s6-svc -u " + path,
+		"s6-svc -h " + path,
+		"s6-svstat -u " + path,
+		"s6-svc -r " + path,
+		"s6-svc -r " + path,
+		"s6-svc -d " + path,
+		"s6-svstat -u " + path,
+		"s6-svc -u " + path,
+	}, executor.calls)
+
+	require.Error(t, s.Mask(ctx, "nginx"))
+}
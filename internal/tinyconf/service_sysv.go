@@ -0,0 +1,149 @@
+package tinyconf
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+)
+
+// sysvServiceManager manages init scripts using service. services are
+// enabled with update-rc.d on Debian, or chkconfig on Red Hat.
+type sysvServiceManager struct {
+	executor commandExecutor
+	// update-rc.d or chkconfig. detected when not set
+	rcTool string
+	// for testing
+	etcDir string
+}
+
+func (s *sysvServiceManager) service(ctx context.Context, action string, service string) error {
+	output, err := s.executor.Run(ctx, "service", service, action)
+	if err != nil {
+		return fmt.Errorf("failed to %s service %s: (output: %s) %w", action, service, string(output), err)
+	}
+	return nil
+}
+
+func (s *sysvServiceManager) IsRunning(ctx context.Context, service string) (bool, error) {
+	output, err := s.executor.Run(ctx, "service", service, "status")
+	if err != nil {
+		// LSB status exits 3 when stopped, and 1-2 when dead but a pid or lock file exists
+		switch exitCode(err) {
+		case 1, 2, 3:
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check service %s status: (output: %s) %w", service, string(output), err)
+	}
+
+	return true, nil
+}
+
+func (s *sysvServiceManager) Start(ctx context.Context, service string) error {
+	return s.service(ctx, "start", service)
+}
+
+func (s *sysvServiceManager) Stop(ctx context.Context, service string) error {
+	return s.service(ctx, "stop", service)
+}
+
+func (s *sysvServiceManager) Restart(ctx context.Context, service string) error {
+	return s.service(ctx, "restart", service)
+}
+
+// try-restart is optional in LSB init scripts, so the status is checked instead
+func (s *sysvServiceManager) TryRestart(ctx context.Context, service string) error {
+	running, err := s.IsRunning(ctx, service)
+	if err != nil || !running {
+		return err
+	}
+	return s.Restart(ctx, service)
+}
+
+func (s *sysvServiceManager) Reload(ctx context.Context, service string) error {
+	return s.service(ctx, "reload", service)
+}
+
+// LSB force-reload reloads if the script supports it, otherwise restarts
+func (s *sysvServiceManager) ReloadOrRestart(ctx context.Context, service string) error {
+	return s.service(ctx, "force-reload", service)
+}
+
+// a service is enabled when it has a start link in any multi-user runlevel
+func (s *sysvServiceManager) IsEnabled(ctx context.Context, service string) (bool, error) {
+	dir := s.etcDir
+	if dir == "" {
+		dir = "/etc"
+	}
+
+	// Red Hat uses /etc/rc.d/rcN.d, with /etc/rcN.d as links to them
+	matches, err := filepath.Glob(filepath.Join(dir, "rc[2345].d", "S[0-9][0-9]"+service))
+	if err != nil {
+		return false, fmt.Errorf("failed to check if service %s is enabled %w", service, err)
+	}
+
+	return len(matches) > 0, nil
+}
+
+// returns the command used to enable and disable services
+func (s *sysvServiceManager) tool() string {
+	if s.rcTool == "" {
+		s.rcTool = "chkconfig"
+		if _, err := exec.LookPath("update-rc.d"); err == nil {
+			s.rcTool = "update-rc.d"
+		}
+	}
+
+	return s.rcTool
+}
+
+func (s *sysvServiceManager) setEnabled(ctx context.Context, service string, enabled bool) error {
+	tool := s.tool()
+
+	var args []string
+	switch {
+	case tool == "update-rc.d" && enabled:
+		// defaults creates the links if this is the first time
+		if output, err := s.executor.Run(ctx, "update-rc.d", service, "defaults"); err != nil {
+			return fmt.Errorf("failed to enable service %s: (output: %s) %w", service, string(output), err)
+		}
+		args = []string{service, "enable"}
+	case tool == "update-rc.d":
+		args = []string{service, "disable"}
+	case enabled:
+		args = []string{service, "on"}
+	default:
+		args = []string{service, "off"}
+	}
+
+	action := "disable"
+	if enabled {
+		action = "enable"
+	}
+
+	if output, err := s.executor.Run(ctx, tool, args...); err != nil {
+		return fmt.Errorf("failed to %s service %s: (output: %s) %w", action, service, string(output), err)
+	}
+
+	return nil
+}
+
+func (s *sysvServiceManager) Enable(ctx context.Context, service string) error {
+	return s.setEnabled(ctx, service, true)
+}
+
+func (s *sysvServiceManager) Disable(ctx context.Context, service string) error {
+	return s.setEnabled(ctx, service, false)
+}
+
+func (s *sysvServiceManager) IsMasked(ctx context.Context, service string) (bool, error) {
+	return false, nil
+}
+
+func (s *sysvServiceManager) Mask(ctx context.Context, service string) error {
+	return maskUnsupported()
+}
+
+func (s *sysvServiceManager) Unmask(ctx context.Context, service string) error {
+	return nil
+}
@@ -0,0 +1,99 @@
+package tinyconf
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSysvServiceManager_IsRunning(t *testing.T) {
+	executor := newFakeExecutor()
+	executor.results["service nginx status"] = fakeResult{output: "nginx is not running", err: fakeExitError(3)}
+	executor.results["service missing status"] = fakeResult{output: "missing: unrecognized service", err: fakeExitError(4)}
+
+	s := &sysvServiceManager{executor: executor}
+	ctx := t.Context()
+
+	running, err := s.IsRunning(ctx, "nginx")
+	require.NoError(t, err)
+	require.False(t, running)
+
+	running, err = s.IsRunning(ctx, "cron")
+	require.NoError(t, err)
+	require.True(t, running)
+
+	_, err = s.IsRunning(ctx, "missing")
+	require.Error(t, err)
+}
+
+func TestSysvServiceManager_IsEnabled(t *testing.T) {
+	etcDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(etcDir, "rc2.d"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(etcDir, "rc6.d"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(etcDir, "rc2.d", "S01nginx"), nil, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(etcDir, "rc2.d", "K01cron"), nil, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(etcDir, "rc6.d", "S01ssh"), nil, 0644))
+
+	s := &sysvServiceManager{executor: newFakeExecutor(), etcDir: etcDir}
+
+	for service, expected := range map[string]bool{
+		"nginx":        true,
+		"cron":         false,
+		"ssh":          false,
+		"nginx-extras": false,
+	} {
+		enabled, err := s.IsEnabled(t.Context(), service)
+		require.NoError(t, err)
+		require.Equal(t, expected, enabled, service)
+	}
+}
+
+func TestSysvServiceManager_Commands(t *testing.T) {
+	executor := newFakeExecutor()
+	s := &sysvServiceManager{executor: executor, rcTool: "update-rc.d"}
+	ctx := t.Context()
+
+	require.NoError(t, s.Enable(ctx, "nginx"))
+	require.NoError(t, s.Start(ctx, "nginx"))
+	require.NoError(t, s.Reload(ctx, "nginx"))
+	require.NoError(t, s.ReloadOrRestart(ctx, "nginx"))
+	require.NoError(t, s.Stop(ctx, "nginx"))
+	require.NoError(t, s.Disable(ctx, "nginx"))
+
+	require.Equal(t, []string{
+		"update-rc.d nginx defaults",
+		"update-rc.d nginx enable",
+		"service nginx start",
+		"service nginx reload",
+		"service nginx force-reload",
+		"service nginx stop",
+		"update-rc.d nginx disable",
+	}, executor.calls)
+
+	executor = newFakeExecutor()
+	executor.results["service apache2 status"] = fakeResult{err: fakeExitError(3)}
+	s = &sysvServiceManager{executor: executor}
+
+	// only running services are restarted
+	require.NoError(t, s.TryRestart(ctx, "nginx"))
+	require.NoError(t, s.TryRestart(ctx, "apache2"))
+
+	require.Equal(t, []string{
+		"service nginx status",
+		"service nginx restart",
+		"service apache2 status",
+	}, executor.calls)
+
+	executor = newFakeExecutor()
+	s = &sysvServiceManager{executor: executor, rcTool: "chkconfig"}
+
+	require.NoError(t, s.Enable(ctx, "nginx"))
+	require.NoError(t, s.Disable(ctx, "nginx"))
+
+	require.Equal(t, []string{
+		"chkconfig nginx on",
+		"chkconfig nginx off",
+	}, executor.calls)
+}
@@ -21,11 +21,18 @@ type mockServiceManager struct {
 	startCalled    []string
 	stopCalled     []string
 	isRunningCalls []string
+	enabled        map[string]bool
+	masked         map[string]bool
+	static         map[string]bool
+	unmaskCalled   []string
 }
 
 func newMockServiceManager() *mockServiceManager {
 	return &mockServiceManager{
 		services: make(map[string]bool),
+		enabled:  make(map[string]bool),
+		masked:   make(map[string]bool),
+		static:   make(map[string]bool),
 	}
 }
 
@@ -55,6 +62,42 @@ func (m *mockServiceManager) Stop(ctx context.Context, service string) error {
 	return nil
 }
 
+func (m *mockServiceManager) IsEnabled(ctx context.Context, service string) (bool, error) {
+	return m.enabled[service], nil
+}
+
+func (m *mockServiceManager) Enable(ctx context.Context, service string) error {
+	if m.masked[service] {
+		return errors.New("unit is masked")
+	}
+	m.enabled[service] = true
+	return nil
+}
+
+func (m *mockServiceManager) Disable(ctx context.Context, service string) error {
+	m.enabled[service] = false
+	return nil
+}
+
+func (m *mockServiceManager) IsStatic(ctx context.Context, service string) (bool, error) {
+	return m.static[service], nil
+}
+
+func (m *mockServiceManager) IsMasked(ctx context.Context, service string) (bool, error) {
+	return m.masked[service], nil
+}
+
+func (m *mockServiceManager) Mask(ctx context.Context, service string) error {
+	m.masked[service] = true
+	return nil
+}
+
+func (m *mockServiceManager) Unmask(ctx context.Context, service string) error {
+	m.unmaskCalled = append(m.unmaskCalled, service)
+	m.masked[service] = false
+	return nil
+}
+
 func TestServiceResource_Run_StartStoppedService(t *testing.T) {
 	mock := newMockServiceManager()
 	mock.services["nginx"] = false
@@ -341,3 +384,153 @@ func TestServiceResource_Run_NoNotifyOnNoChange(t *testing.T) {
 	require.NoError(t, err)
 	require.Empty(t, service)
 }
+
+func TestServiceResource_Run_Enable(t *testing.T) {
+	mock := newMockServiceManager()
+	mock.services["nginx"] = true
+
+	resource := &serviceResource{
+		Name:    "nginx",
+		State:   "running",
+		Enabled: ptr(true),
+		Notify:  notifyResource{Service: "notified"},
+		manager: mock,
+	}
+
+	service, err := resource.Run(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, "notified", service)
+	require.True(t, mock.enabled["nginx"])
+	require.Empty(t, mock.startCalled)
+
+	service, err = resource.Run(t.Context())
+	require.NoError(t, err)
+	require.Empty(t, service)
+}
+
+func TestServiceResource_Run_Disable(t *testing.T) {
+	mock := newMockServiceManager()
+	mock.enabled["nginx"] = true
+
+	resource := &serviceResource{
+		Name:    "nginx",
+		State:   "stopped",
+		Enabled: ptr(false),
+		manager: mock,
+	}
+
+	_, err := resource.Run(t.Context())
+	require.NoError(t, err)
+	require.False(t, mock.enabled["nginx"])
+}
+
+func TestServiceResource_Run_DisableStatic(t *testing.T) {
+	mock := newMockServiceManager()
+	// static units are reported as enabled, as they are started by other units
+	mock.enabled["systemd-tmpfiles-clean"] = true
+	mock.static["systemd-tmpfiles-clean"] = true
+
+	resource := &serviceResource{
+		Name:    "systemd-tmpfiles-clean",
+		State:   "stopped",
+		Enabled: ptr(false),
+		Notify:  notifyResource{Service: "other"},
+		manager: mock,
+	}
+
+	for range 2 {
+		service, err := resource.Run(t.Context())
+		require.NoError(t, err)
+		require.Empty(t, service)
+		require.True(t, mock.enabled["systemd-tmpfiles-clean"])
+	}
+}
+
+func TestServiceResource_Run_EnabledNotSet(t *testing.T) {
+	mock := newMockServiceManager()
+	mock.services["nginx"] = true
+
+	resource := &serviceResource{Name: "nginx", State: "running", manager: mock}
+
+	service, err := resource.Run(t.Context())
+	require.NoError(t, err)
+	require.Empty(t, service)
+	require.False(t, mock.enabled["nginx"])
+}
+
+func TestServiceResource_Run_UnmaskToStart(t *testing.T) {
+	mock := newMockServiceManager()
+	mock.masked["nginx"] = true
+
+	resource := &serviceResource{
+		Name:    "nginx",
+		State:   "running",
+		Enabled: ptr(true),
+		manager: mock,
+	}
+
+	_, err := resource.Run(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, []string{"nginx"}, mock.unmaskCalled)
+	require.True(t, mock.services["nginx"])
+	require.True(t, mock.enabled["nginx"])
+}
+
+func TestServiceResource_Run_StoppedStaysMasked(t *testing.T) {
+	mock := newMockServiceManager()
+	mock.masked["nginx"] = true
+
+	resource := &serviceResource{Name: "nginx", State: "stopped", manager: mock}
+
+	service, err := resource.Run(t.Context())
+	require.NoError(t, err)
+	require.Empty(t, service)
+	require.True(t, mock.masked["nginx"])
+
+	// explicitly unmask
+	resource.Masked = ptr(false)
+	_, err = resource.Run(t.Context())
+	require.NoError(t, err)
+	require.False(t, mock.masked["nginx"])
+}
+
+func TestServiceResource_Run_Mask(t *testing.T) {
+	mock := newMockServiceManager()
+	mock.services["nginx"] = true
+	mock.enabled["nginx"] = true
+
+	resource := &serviceResource{
+		Name:    "nginx",
+		State:   "stopped",
+		Enabled: ptr(false),
+		Masked:  ptr(true),
+		Notify:  notifyResource{Service: "notified"},
+		manager: mock,
+	}
+
+	service, err := resource.Run(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, "notified", service)
+	require.False(t, mock.services["nginx"])
+	require.False(t, mock.enabled["nginx"])
+	require.True(t, mock.masked["nginx"])
+
+	service, err = resource.Run(t.Context())
+	require.NoError(t, err)
+	require.Empty(t, service)
+}
+
+func TestServiceResource_Run_MaskedAndRunning(t *testing.T) {
+	mock := newMockServiceManager()
+
+	resource := &serviceResource{Name: "nginx", State: "running", Masked: ptr(true), manager: mock}
+
+	_, err := resource.Run(t.Context())
+	require.Error(t, err)
+	require.Empty(t, mock.startCalled)
+
+	resource = &serviceResource{Name: "nginx", State: "stopped", Enabled: ptr(true), Masked: ptr(true), manager: mock}
+
+	_, err = resource.Run(t.Context())
+	require.Error(t, err)
+}
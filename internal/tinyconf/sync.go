@@ -0,0 +1,375 @@
+package tinyconf
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// syncResource mirrors a source directory into a destination directory.
+type syncResource struct {
+	Source      string  `json:"source" validate:"required"`
+	Destination string  `json:"destination" validate:"required"`
+	Owner       *string `json:"owner"`
+	Group       *string `json:"group"`
+	// mode for files. defaults to the mode of the source file
+	Mode *os.FileMode `json:"mode"`
+	// mode for directories. defaults to 0755 for new directories
+	DirMode *os.FileMode `json:"dir_mode"`
+	// glob patterns matched against the path relative to source as well as the base name.
+	// excluded paths are left alone in the destination
+	Exclude []string `json:"exclude"`
+	// remove anything in destination that is not in source
+	Delete bool           `json:"delete"`
+	Notify notifyResource `json:"notify"`
+}
+
+// summary of what changed during a sync
+type syncChanges struct {
+	created []string
+	updated []string
+	deleted []string
+}
+
+func (c *syncChanges) changed() bool {
+	return len(c.created)+len(c.updated)+len(c.deleted) > 0
+}
+
+func (s *syncResource) Run(ctx context.Context) (string, error) {
+	userID, groupID, err := getUserAndGroup("sync "+s.Destination, s.Owner, s.Group)
+	if err != nil {
+		return "", err
+	}
+
+	source := filepath.Clean(s.Source)
+	destination := filepath.Clean(s.Destination)
+
+	srcInfo, err := os.Stat(source)
+	if err != nil {
+		return "", fmt.Errorf("failed to stat %s %w", source, err)
+	}
+	if !srcInfo.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", source)
+	}
+
+	var changes syncChanges
+
+	tasks := []func() (bool, error){
+		func() (bool, error) {
+			err := s.copyTree(source, destination, userID, groupID, &changes)
+			return changes.changed(), err
+		},
+		func() (bool, error) {
+			if !s.Delete {
+				return false, nil
+			}
+			err := s.deleteExtra(source, destination, &changes)
+			return len(changes.deleted) > 0, err
+		},
+	}
+
+	changed, err := runTasks(tasks)
+	if changed {
+		slog.Info("synced directory", "source", source, "destination", destination,
+			"created", len(changes.created), "updated", len(changes.updated), "deleted", len(changes.deleted))
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if changed {
+		return s.Notify.Service, nil
+	}
+
+	return "", nil
+}
+
+func (s *syncResource) excluded(rel string) (bool, error) {
+	for _, pattern := range s.Exclude {
+		for _, name := range []string{rel, filepath.Base(rel)} {
+			match, err := filepath.Match(pattern, name)
+			if err != nil {
+				return false, fmt.Errorf("invalid exclude pattern %q %w", pattern, err)
+			}
+			if match {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+func (s *syncResource) copyTree(source string, destination string, userID int, groupID int, changes *syncChanges) error {
+	return filepath.WalkDir(source, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(source, path)
+		if err != nil {
+			return err
+		}
+
+		if rel != "." {
+			skip, err := s.excluded(rel)
+			if err != nil {
+				return err
+			}
+			if skip {
+				if entry.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+
+		target := filepath.Join(destination, rel)
+
+		switch {
+		case entry.IsDir():
+			return s.syncDir(target, userID, groupID, changes)
+		case entry.Type()&fs.ModeSymlink != 0:
+			return s.syncSymlink(path, target, changes)
+		case entry.Type().IsRegular():
+			return s.syncFile(path, target, userID, groupID, changes)
+		default:
+			slog.Info("skipping unsupported file type", "path", path)
+			return nil
+		}
+	})
+}
+
+func (s *syncResource) syncDir(target string, userID int, groupID int, changes *syncChanges) error {
+	info, err := os.Lstat(target)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to stat %s %w", target, err)
+		}
+
+		mode := defaultDirMode
+		if s.DirMode != nil {
+			mode = *s.DirMode
+		}
+
+		slog.Info("creating directory", "path", target, "mode", mode)
+		if err := os.Mkdir(target, mode); err != nil {
+			return err
+		}
+		changes.created = append(changes.created, target)
+
+		if info, err = os.Lstat(target); err != nil {
+			return err
+		}
+	}
+
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", target)
+	}
+
+	changed, err := ensureAttributes(target, info, userID, groupID, s.DirMode)
+	if changed && !slices.Contains(changes.created, target) {
+		changes.updated = append(changes.updated, target)
+	}
+
+	return err
+}
+
+func (s *syncResource) syncSymlink(path string, target string, changes *syncChanges) error {
+	link, err := os.Readlink(path)
+	if err != nil {
+		return err
+	}
+
+	existing, err := os.Readlink(target)
+	switch {
+	case err == nil && existing == link:
+		return nil
+	case err == nil:
+		changes.updated = append(changes.updated, target)
+	case errors.Is(err, os.ErrNotExist):
+		changes.created = append(changes.created, target)
+	default:
+		// exists but is not a symlink
+		changes.updated = append(changes.updated, target)
+	}
+
+	slog.Info("creating symlink", "path", target, "target", link)
+	if err := os.RemoveAll(target); err != nil {
+		return fmt.Errorf("failed to remove %s %w", target, err)
+	}
+
+	return os.Symlink(link, target)
+}
+
+func (s *syncResource) syncFile(path string, target string, userID int, groupID int, changes *syncChanges) error {
+	srcInfo, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	mode := srcInfo.Mode().Perm()
+	if s.Mode != nil {
+		mode = *s.Mode
+	}
+
+	info, err := os.Lstat(target)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to stat %s %w", target, err)
+	}
+
+	if err == nil && info.Mode().IsRegular() {
+		same, err := sameContents(path, target)
+		if err != nil {
+			return err
+		}
+
+		if same {
+			changed, err := ensureAttributes(target, info, userID, groupID, &mode)
+			if changed {
+				changes.updated = append(changes.updated, target)
+			}
+			return err
+		}
+
+		changes.updated = append(changes.updated, target)
+		slog.Info("updating file", "path", target)
+	} else {
+		if err == nil && info.IsDir() {
+			return fmt.Errorf("%s is a directory", target)
+		}
+
+		changes.created = append(changes.created, target)
+		slog.Info("creating file", "path", target, "mode", mode)
+	}
+
+	return copyFileAtomic(path, target, mode, userID, groupID)
+}
+
+func fileChecksum(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return nil, fmt.Errorf("failed to read %s %w", path, err)
+	}
+
+	return h.Sum(nil), nil
+}
+
+func sameContents(a string, b string) (bool, error) {
+	sumA, err := fileChecksum(a)
+	if err != nil {
+		return false, err
+	}
+
+	sumB, err := fileChecksum(b)
+	if err != nil {
+		return false, err
+	}
+
+	return bytes.Equal(sumA, sumB), nil
+}
+
+// copies src to a temp file next to dst and renames it into place.
+// -1 for uid or gid leaves it as the current user.
+func copyFileAtomic(src string, dst string, mode os.FileMode, userID int, groupID int) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	file, err := os.CreateTemp(filepath.Dir(dst), ".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file for %s %w", dst, err)
+	}
+
+	defer func() {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+	}()
+
+	if _, err := io.Copy(file, in); err != nil {
+		return fmt.Errorf("failed to write to temp file for %s %w", dst, err)
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close temp file for %s %w", dst, err)
+	}
+
+	if err := os.Chmod(file.Name(), mode); err != nil {
+		return fmt.Errorf("failed to chmod of %s %w", dst, err)
+	}
+
+	if userID != -1 || groupID != -1 {
+		if err := os.Chown(file.Name(), userID, groupID); err != nil {
+			return fmt.Errorf("failed to change ownership of %s %w", dst, err)
+		}
+	}
+
+	if err := os.Rename(file.Name(), dst); err != nil {
+		return fmt.Errorf("failed to rename temp file for %s %w", dst, err)
+	}
+
+	return nil
+}
+
+// removes anything in destination that is not in source. excluded paths are left alone.
+func (s *syncResource) deleteExtra(source string, destination string, changes *syncChanges) error {
+	return filepath.WalkDir(destination, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(destination, path)
+		if err != nil {
+			return err
+		}
+
+		if rel == "." {
+			return nil
+		}
+
+		skip, err := s.excluded(rel)
+		if err != nil {
+			return err
+		}
+		if skip {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if _, err := os.Lstat(filepath.Join(source, rel)); err == nil {
+			return nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+
+		slog.Info("removing path not in source", "path", path)
+		if err := os.RemoveAll(path); err != nil {
+			return fmt.Errorf("failed to remove %s %w", path, err)
+		}
+		changes.deleted = append(changes.deleted, path)
+
+		if entry.IsDir() {
+			return filepath.SkipDir
+		}
+
+		return nil
+	})
+}
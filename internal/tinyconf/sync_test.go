@@ -0,0 +1,252 @@
+package tinyconf
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// creates files relative to dir. directories are created as needed
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(contents), 0o644))
+	}
+}
+
+func TestSyncResource_Run_CopyTree(t *testing.T) {
+	source := t.TempDir()
+	destination := filepath.Join(t.TempDir(), "site")
+
+	writeTree(t, source, map[string]string{
+		"index.html":     "hello",
+		"css/style.css":  "body {}",
+		"js/app/main.js": "console.log(1)",
+	})
+
+	s := &syncResource{
+		Source:      source,
+		Destination: destination,
+		Notify: notifyResource{
+			Service: "test-service",
+		},
+	}
+
+	ctx := t.Context()
+
+	service, err := s.Run(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "test-service", service)
+
+	for name, contents := range map[string]string{
+		"index.html":     "hello",
+		"css/style.css":  "body {}",
+		"js/app/main.js": "console.log(1)",
+	} {
+		data, err := os.ReadFile(filepath.Join(destination, name))
+		require.NoError(t, err)
+		require.Equal(t, contents, string(data))
+	}
+
+	// idempotent
+	service, err = s.Run(ctx)
+	require.NoError(t, err)
+	require.Empty(t, service)
+}
+
+func TestSyncResource_Run_UpdateChangedFile(t *testing.T) {
+	source := t.TempDir()
+	destination := t.TempDir()
+
+	writeTree(t, source, map[string]string{"a.txt": "new", "b.txt": "same"})
+	writeTree(t, destination, map[string]string{"a.txt": "old", "b.txt": "same"})
+
+	s := &syncResource{
+		Source:      source,
+		Destination: destination,
+		Notify: notifyResource{
+			Service: "test-service",
+		},
+	}
+
+	service, err := s.Run(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, "test-service", service)
+
+	data, err := os.ReadFile(filepath.Join(destination, "a.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "new", string(data))
+}
+
+func TestSyncResource_Run_DeleteExtra(t *testing.T) {
+	source := t.TempDir()
+	destination := t.TempDir()
+
+	writeTree(t, source, map[string]string{"keep.txt": "x"})
+	writeTree(t, destination, map[string]string{
+		"keep.txt":        "x",
+		"extra.txt":       "x",
+		"olddir/file.txt": "x",
+		"local.conf":      "x",
+	})
+
+	s := &syncResource{
+		Source:      source,
+		Destination: destination,
+		Delete:      true,
+		Exclude:     []string{"*.conf"},
+		Notify: notifyResource{
+			Service: "test-service",
+		},
+	}
+
+	ctx := t.Context()
+
+	service, err := s.Run(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "test-service", service)
+
+	_, err = os.Stat(filepath.Join(destination, "extra.txt"))
+	require.True(t, os.IsNotExist(err))
+
+	_, err = os.Stat(filepath.Join(destination, "olddir"))
+	require.True(t, os.IsNotExist(err))
+
+	// excluded paths are left alone
+	_, err = os.Stat(filepath.Join(destination, "local.conf"))
+	require.NoError(t, err)
+
+	service, err = s.Run(ctx)
+	require.NoError(t, err)
+	require.Empty(t, service)
+}
+
+func TestSyncResource_Run_NoDeleteByDefault(t *testing.T) {
+	source := t.TempDir()
+	destination := t.TempDir()
+
+	writeTree(t, destination, map[string]string{"extra.txt": "x"})
+
+	s := &syncResource{
+		Source:      source,
+		Destination: destination,
+	}
+
+	service, err := s.Run(t.Context())
+	require.NoError(t, err)
+	require.Empty(t, service)
+
+	_, err = os.Stat(filepath.Join(destination, "extra.txt"))
+	require.NoError(t, err)
+}
+
+func TestSyncResource_Run_Exclude(t *testing.T) {
+	source := t.TempDir()
+	destination := t.TempDir()
+
+	writeTree(t, source, map[string]string{
+		"index.html":      "x",
+		".git/config":     "x",
+		"notes/draft.md":  "x",
+		"notes/final.txt": "x",
+	})
+
+	s := &syncResource{
+		Source:      source,
+		Destination: destination,
+		Exclude:     []string{".git", "*.md"},
+	}
+
+	_, err := s.Run(t.Context())
+	require.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(destination, ".git"))
+	require.True(t, os.IsNotExist(err))
+
+	_, err = os.Stat(filepath.Join(destination, "notes", "draft.md"))
+	require.True(t, os.IsNotExist(err))
+
+	_, err = os.Stat(filepath.Join(destination, "notes", "final.txt"))
+	require.NoError(t, err)
+}
+
+func TestSyncResource_Run_Modes(t *testing.T) {
+	source := t.TempDir()
+	destination := t.TempDir()
+
+	writeTree(t, source, map[string]string{"dir/file.txt": "x"})
+
+	mode := os.FileMode(0o600)
+	dirMode := os.FileMode(0o700)
+
+	s := &syncResource{
+		Source:      source,
+		Destination: destination,
+		Mode:        &mode,
+		DirMode:     &dirMode,
+	}
+
+	ctx := t.Context()
+
+	_, err := s.Run(ctx)
+	require.NoError(t, err)
+
+	info, err := os.Stat(filepath.Join(destination, "dir"))
+	require.NoError(t, err)
+	require.Equal(t, dirMode, info.Mode().Perm())
+
+	info, err = os.Stat(filepath.Join(destination, "dir", "file.txt"))
+	require.NoError(t, err)
+	require.Equal(t, mode, info.Mode().Perm())
+
+	// changing only the mode is still a change
+	newMode := os.FileMode(0o640)
+	s.Mode = &newMode
+	s.Notify.Service = "test-service"
+
+	service, err := s.Run(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "test-service", service)
+
+	info, err = os.Stat(filepath.Join(destination, "dir", "file.txt"))
+	require.NoError(t, err)
+	require.Equal(t, newMode, info.Mode().Perm())
+}
+
+func TestSyncResource_Run_Symlinks(t *testing.T) {
+	source := t.TempDir()
+	destination := t.TempDir()
+
+	writeTree(t, source, map[string]string{"target.txt": "x"})
+	require.NoError(t, os.Symlink("target.txt", filepath.Join(source, "link")))
+
+	s := &syncResource{
+		Source:      source,
+		Destination: destination,
+	}
+
+	_, err := s.Run(t.Context())
+	require.NoError(t, err)
+
+	link, err := os.Readlink(filepath.Join(destination, "link"))
+	require.NoError(t, err)
+	require.Equal(t, "target.txt", link)
+}
+
+func TestSyncResource_Run_ErrorSourceNotDirectory(t *testing.T) {
+	source := writeTestFile(t, "x", 0o644)
+
+	s := &syncResource{
+		Source:      source,
+		Destination: t.TempDir(),
+	}
+
+	_, err := s.Run(t.Context())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "is not a directory")
+}
@@ -0,0 +1,242 @@
+package tinyconf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const defaultSystemdUnitDir = "/etc/systemd/system"
+
+// systemdUnitResource manages a systemd unit file or a drop-in for a unit.
+type systemdUnitResource struct {
+	// such as nginx.service
+	Name string `json:"name" validate:"required,excludesall=/"`
+	// writes <name>.d/<drop_in>.conf instead of the unit file
+	DropIn *string `json:"drop_in" validate:"omitempty,excludesall=/"`
+	// raw contents of the file
+	Contents *string `json:"contents" validate:"excluded_with=Sections"`
+	// section -> key -> value. lists are written as repeated keys
+	Sections map[string]map[string]any `json:"sections"`
+	State    *string                   `json:"state" validate:"omitempty,oneof=present absent"`
+	// defaults to restarting the unit
+	Notify notifyResource `json:"notify"`
+	// told to run daemon-reload when the unit changes
+	reloader daemonReloader
+	// checks the unit file before it is written
+	verifier func(context.Context, string) error
+	// for testing
+	unitDir string
+}
+
+// service managers that can be told unit files changed
+type daemonReloader interface {
+	NeedDaemonReload()
+}
+
+func (u *systemdUnitResource) path() string {
+	dir := u.unitDir
+	if dir == "" {
+		dir = defaultSystemdUnitDir
+	}
+
+	if u.DropIn != nil {
+		return filepath.Join(dir, u.Name+".d", strings.TrimSuffix(*u.DropIn, ".conf")+".conf")
+	}
+
+	return filepath.Join(dir, u.Name)
+}
+
+func (u *systemdUnitResource) Run(ctx context.Context) (string, error) {
+	changed, err := u.apply(ctx)
+	if err != nil || !changed {
+		return "", err
+	}
+
+	if u.Notify.Service != "" {
+		return u.Notify.Service, nil
+	}
+
+	// removed and template units can not be restarted
+	if (u.State != nil && *u.State == "absent") || strings.Contains(u.Name, "@.") {
+		return "", nil
+	}
+
+	return u.Name, nil
+}
+
+// writes or removes the unit, returning whether it changed
+func (u *systemdUnitResource) apply(ctx context.Context) (bool, error) {
+	shouldExist := u.State == nil || *u.State == "present"
+
+	if shouldExist && u.Contents == nil && len(u.Sections) == 0 {
+		return false, fmt.Errorf("systemd unit %s requires contents or sections", u.Name)
+	}
+
+	tasks := []func() (bool, error){
+		func() (bool, error) {
+			path := u.path()
+
+			if !shouldExist {
+				return removeFile(path)
+			}
+
+			contents, err := u.contents()
+			if err != nil {
+				return false, err
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return false, fmt.Errorf("failed to read %s %w", path, err)
+			}
+
+			if err == nil && string(data) == contents {
+				return false, nil
+			}
+
+			// drop-ins can not be checked without the rest of the unit
+			if u.DropIn == nil {
+				if err := u.verify(ctx, contents); err != nil {
+					return false, err
+				}
+			}
+
+			slog.Info("writing systemd unit", "name", u.Name, "path", path)
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				return false, fmt.Errorf("failed to create directory for %s %w", path, err)
+			}
+
+			if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+				return false, fmt.Errorf("failed to write %s %w", path, err)
+			}
+
+			return true, nil
+		},
+	}
+
+	// use runTasks in case we add some debugging/logging/etc
+	changed, err := runTasks(tasks)
+	if err != nil {
+		return false, err
+	}
+
+	if changed && !isNil(u.reloader) {
+		u.reloader.NeedDaemonReload()
+	}
+
+	return changed, nil
+}
+
+func (u *systemdUnitResource) contents() (string, error) {
+	if u.Contents != nil {
+		return *u.Contents, nil
+	}
+
+	return renderUnitSections(u.Sections)
+}
+
+// writes the contents to a temporary unit with the same name and runs the verifier
+func (u *systemdUnitResource) verify(ctx context.Context, contents string) error {
+	verifier := u.verifier
+	if verifier == nil {
+		verifier = systemdAnalyzeVerify
+	}
+
+	dir, err := os.MkdirTemp("", "tinyconf-unit")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, u.Name)
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		return fmt.Errorf("failed to write %s %w", path, err)
+	}
+
+	if err := verifier(ctx, path); err != nil {
+		return fmt.Errorf("invalid systemd unit %s %w", u.Name, err)
+	}
+
+	return nil
+}
+
+// verifies a unit file using systemd-analyze, if it is installed
+func systemdAnalyzeVerify(ctx context.Context, path string) error {
+	if _, err := exec.LookPath("systemd-analyze"); err != nil {
+		return nil
+	}
+
+	output, err := exec.CommandContext(ctx, "systemd-analyze", "verify", path).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("(output: %s): %w", string(output), err)
+	}
+
+	return nil
+}
+
+// renders sections in a stable order, with Unit first and Install last
+func renderUnitSections(sections map[string]map[string]any) (string, error) {
+	names := make([]string, 0, len(sections))
+	for name := range sections {
+		names = append(names, name)
+	}
+
+	rank := func(name string) int {
+		switch name {
+		case "Unit":
+			return 0
+		case "Install":
+			return 2
+		default:
+			return 1
+		}
+	}
+
+	slices.SortFunc(names, func(a, b string) int {
+		if rank(a) != rank(b) {
+			return rank(a) - rank(b)
+		}
+		return strings.Compare(a, b)
+	})
+
+	var b strings.Builder
+	b.WriteString("# managed by tinyconf\n")
+
+	for i, name := range names {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "[%s]\n", name)
+
+		keys := make([]string, 0, len(sections[name]))
+		for key := range sections[name] {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+
+		for _, key := range keys {
+			values, ok := sections[name][key].([]any)
+			if !ok {
+				values = []any{sections[name][key]}
+			}
+
+			// an empty value resets the key, such as ExecStart= in a drop-in
+			for _, v := range values {
+				value, err := iniValue(v)
+				if err != nil {
+					return "", fmt.Errorf("invalid value for %s in section %s %w", key, name, err)
+				}
+				fmt.Fprintf(&b, "%s=%s\n", key, value)
+			}
+		}
+	}
+
+	return b.String(), nil
+}
@@ -0,0 +1,159 @@
+package tinyconf
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type mockDaemonReloader struct {
+	needed int
+}
+
+func (m *mockDaemonReloader) NeedDaemonReload() {
+	m.needed++
+}
+
+func newTestSystemdUnit(t *testing.T, name string) (*systemdUnitResource, *mockDaemonReloader) {
+	t.Helper()
+
+	reloader := &mockDaemonReloader{}
+
+	return &systemdUnitResource{
+		Name:     name,
+		reloader: reloader,
+		verifier: func(context.Context, string) error { return nil },
+		unitDir:  t.TempDir(),
+	}, reloader
+}
+
+func TestSystemdUnitResource_Run_Sections(t *testing.T) {
+	unit, reloader := newTestSystemdUnit(t, "app.service")
+	unit.Sections = map[string]map[string]any{
+		"Install": {"WantedBy": "multi-user.target"},
+		"Service": {
+			"ExecStart":    "/usr/local/bin/app",
+			"ExecStartPre": []any{"/usr/local/bin/app check", "/usr/local/bin/app migrate"},
+			"Restart":      "on-failure",
+			"RestartSec":   float64(5),
+		},
+		"Unit": {"Description": "app"},
+	}
+
+	service, err := unit.Run(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, "app.service", service)
+	require.Equal(t, 1, reloader.needed)
+
+	data, err := os.ReadFile(filepath.Join(unit.unitDir, "app.service"))
+	require.NoError(t, err)
+	require.Equal(t, `# managed by tinyconf
+[Unit]
+Description=app
+
+[Service]
+ExecStart=/usr/local/bin/app
+ExecStartPre=/usr/local/bin/app check
+ExecStartPre=/usr/local/bin/app migrate
+Restart=on-failure
+RestartSec=5
+
+[Install]
+WantedBy=multi-user.target
+`, string(data))
+
+	service, err = unit.Run(t.Context())
+	require.NoError(t, err)
+	require.Empty(t, service)
+	require.Equal(t, 1, reloader.needed)
+}
+
+func TestSystemdUnitResource_Run_DropIn(t *testing.T) {
+	unit, _ := newTestSystemdUnit(t, "nginx.service")
+	unit.DropIn = ptr("override")
+	unit.Sections = map[string]map[string]any{
+		"Service": {"ExecStart": []any{"", "/usr/sbin/nginx -g 'daemon off;'"}},
+	}
+	unit.Notify = notifyResource{Service: "nginx", Action: "reload"}
+	unit.verifier = func(context.Context, string) error {
+		return errors.New("drop-ins should not be verified")
+	}
+
+	service, err := unit.Run(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, "nginx", service)
+
+	data, err := os.ReadFile(filepath.Join(unit.unitDir, "nginx.service.d", "override.conf"))
+	require.NoError(t, err)
+	require.Equal(t, "# managed by tinyconf\n[Service]\nExecStart=\nExecStart=/usr/sbin/nginx -g 'daemon off;'\n", string(data))
+}
+
+func TestSystemdUnitResource_Run_VerifyFails(t *testing.T) {
+	unit, reloader := newTestSystemdUnit(t, "app.service")
+	unit.Contents = ptr("[Service]\nExecStart=relative\n")
+
+	var verified string
+	unit.verifier = func(ctx context.Context, path string) error {
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		verified = string(data)
+		require.Equal(t, "app.service", filepath.Base(path))
+		return errors.New("Executable path is not absolute")
+	}
+
+	_, err := unit.Run(t.Context())
+	require.Error(t, err)
+	require.Equal(t, *unit.Contents, verified)
+	require.Equal(t, 0, reloader.needed)
+
+	_, err = os.Stat(filepath.Join(unit.unitDir, "app.service"))
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestSystemdUnitResource_Run_Absent(t *testing.T) {
+	unit, reloader := newTestSystemdUnit(t, "app.service")
+	unit.Contents = ptr("[Service]\nExecStart=/bin/true\n")
+
+	_, err := unit.Run(t.Context())
+	require.NoError(t, err)
+
+	unit.State = ptr("absent")
+	service, err := unit.Run(t.Context())
+	require.NoError(t, err)
+	require.Empty(t, service)
+	require.Equal(t, 2, reloader.needed)
+
+	_, err = os.Stat(filepath.Join(unit.unitDir, "app.service"))
+	require.True(t, os.IsNotExist(err))
+
+	_, err = unit.Run(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, 2, reloader.needed)
+}
+
+func TestSystemdUnitResource_Run_TemplateNotNotified(t *testing.T) {
+	unit, _ := newTestSystemdUnit(t, "worker@.service")
+	unit.Contents = ptr("[Service]\nExecStart=/usr/local/bin/worker %i\n")
+
+	service, err := unit.Run(t.Context())
+	require.NoError(t, err)
+	require.Empty(t, service)
+}
+
+func TestSystemdUnitResource_Run_RequiresContents(t *testing.T) {
+	unit, _ := newTestSystemdUnit(t, "app.service")
+
+	_, err := unit.Run(t.Context())
+	require.Error(t, err)
+}
+
+func TestSystemdServiceManager_DaemonReloadNotNeeded(t *testing.T) {
+	manager := &systemdServiceManager{}
+
+	// does not run systemctl
+	require.NoError(t, manager.DaemonReload(t.Context()))
+}
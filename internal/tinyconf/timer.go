@@ -0,0 +1,225 @@
+package tinyconf
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+)
+
+// timerResource manages a systemd timer and the service it runs.
+// <name>.service and <name>.timer are written, and the timer is enabled and started.
+type timerResource struct {
+	Name        string  `json:"name" validate:"required,excludesall=/ "`
+	Description *string `json:"description"`
+	// absolute path to the command and its arguments
+	Command string `json:"command" validate:"required_unless=State absent"`
+	// defaults to root
+	User *string           `json:"user"`
+	Env  map[string]string `json:"env"`
+	// at least one of these is required. see systemd.timer(5)
+	OnCalendar      *string `json:"on_calendar"`
+	OnBootSec       *string `json:"on_boot_sec"`
+	OnUnitActiveSec *string `json:"on_unit_active_sec"`
+	// run missed jobs when the timer starts. only for on_calendar
+	Persistent *bool          `json:"persistent"`
+	State      *string        `json:"state" validate:"omitempty,oneof=present absent"`
+	Notify     notifyResource `json:"notify"`
+	manager    serviceManager
+	reloader   daemonReloader
+	// for testing
+	verifier func(context.Context, string) error
+	unitDir  string
+}
+
+func (t *timerResource) units() (*systemdUnitResource, *systemdUnitResource) {
+	description := "tinyconf timer " + t.Name
+	if t.Description != nil {
+		description = escapeUnitSpecifiers(*t.Description)
+	}
+
+	service := map[string]any{
+		"Type":      "oneshot",
+		"ExecStart": escapeUnitSpecifiers(t.Command),
+	}
+	if t.User != nil {
+		service["User"] = escapeUnitSpecifiers(*t.User)
+	}
+	if len(t.Env) > 0 {
+		var env []any
+		for _, key := range slices.Sorted(maps.Keys(t.Env)) {
+			env = append(env, quoteUnitValue(escapeUnitSpecifiers(key+"="+t.Env[key])))
+		}
+		service["Environment"] = env
+	}
+
+	timer := map[string]any{}
+	for key, value := range map[string]*string{
+		"OnCalendar":      t.OnCalendar,
+		"OnBootSec":       t.OnBootSec,
+		"OnUnitActiveSec": t.OnUnitActiveSec,
+	} {
+		if value != nil {
+			timer[key] = *value
+		}
+	}
+	if t.Persistent != nil {
+		timer["Persistent"] = *t.Persistent
+	}
+
+	unit := func(name string, sections map[string]map[string]any) *systemdUnitResource {
+		return &systemdUnitResource{
+			Name:     name,
+			Sections: sections,
+			State:    t.State,
+			reloader: t.reloader,
+			verifier: t.verifier,
+			unitDir:  t.unitDir,
+		}
+	}
+
+	serviceUnit := unit(t.Name+".service", map[string]map[string]any{
+		"Unit":    {"Description": description},
+		"Service": service,
+	})
+
+	timerUnit := unit(t.Name+".timer", map[string]map[string]any{
+		"Unit":    {"Description": description},
+		"Timer":   timer,
+		"Install": {"WantedBy": "timers.target"},
+	})
+
+	return serviceUnit, timerUnit
+}
+
+// quotes a value for systemd, such as an Environment assignment with spaces
+// systemd expands specifiers such as %n and %h, so a % is written as %%
+func escapeUnitSpecifiers(value string) string {
+	return strings.ReplaceAll(value, "%", "%%")
+}
+
+func quoteUnitValue(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+func (t *timerResource) Run(ctx context.Context) (string, error) {
+	if isNil(t.manager) {
+		t.manager = &systemdServiceManager{}
+	}
+
+	shouldExist := t.State == nil || *t.State == "present"
+
+	if shouldExist && t.OnCalendar == nil && t.OnBootSec == nil && t.OnUnitActiveSec == nil {
+		return "", fmt.Errorf("timer %s requires on_calendar, on_boot_sec, or on_unit_active_sec", t.Name)
+	}
+
+	service, timer := t.units()
+
+	// a running timer is restarted to use its new schedule
+	var timerChanged bool
+
+	var tasks []func() (bool, error)
+	if shouldExist {
+		tasks = []func() (bool, error){
+			func() (bool, error) {
+				return service.apply(ctx)
+			},
+			func() (bool, error) {
+				var err error
+				timerChanged, err = timer.apply(ctx)
+				return timerChanged, err
+			},
+			func() (bool, error) {
+				isEnabled, err := t.manager.IsEnabled(ctx, timer.Name)
+				if err != nil {
+					return false, fmt.Errorf("failed to get enabled status for %s %w", timer.Name, err)
+				}
+
+				if isEnabled {
+					return false, nil
+				}
+
+				slog.Info("enabling timer", "name", timer.Name)
+				return true, t.manager.Enable(ctx, timer.Name)
+			},
+			func() (bool, error) {
+				isRunning, err := t.manager.IsRunning(ctx, timer.Name)
+				if err != nil {
+					return false, fmt.Errorf("failed to get status for %s %w", timer.Name, err)
+				}
+
+				if isRunning && !timerChanged {
+					return false, nil
+				}
+
+				if isRunning {
+					slog.Info("restarting timer", "name", timer.Name)
+					if err := t.manager.Stop(ctx, timer.Name); err != nil {
+						return false, err
+					}
+				} else {
+					slog.Info("starting timer", "name", timer.Name)
+				}
+
+				return true, t.manager.Start(ctx, timer.Name)
+			},
+		}
+	} else {
+		tasks = []func() (bool, error){
+			func() (bool, error) {
+				// nothing to stop or disable if the timer was never installed
+				if _, err := os.Stat(timer.path()); os.IsNotExist(err) {
+					return false, nil
+				}
+
+				changed := false
+
+				isRunning, err := t.manager.IsRunning(ctx, timer.Name)
+				if err != nil {
+					return false, fmt.Errorf("failed to get status for %s %w", timer.Name, err)
+				}
+
+				if isRunning {
+					slog.Info("stopping timer", "name", timer.Name)
+					if err := t.manager.Stop(ctx, timer.Name); err != nil {
+						return false, err
+					}
+					changed = true
+				}
+
+				isEnabled, err := t.manager.IsEnabled(ctx, timer.Name)
+				if err != nil {
+					return changed, fmt.Errorf("failed to get enabled status for %s %w", timer.Name, err)
+				}
+
+				if isEnabled {
+					slog.Info("disabling timer", "name", timer.Name)
+					return true, t.manager.Disable(ctx, timer.Name)
+				}
+
+				return changed, nil
+			},
+			func() (bool, error) {
+				return timer.apply(ctx)
+			},
+			func() (bool, error) {
+				return service.apply(ctx)
+			},
+		}
+	}
+
+	// use runTasks in case we add some debugging/logging/etc
+	changed, err := runTasks(tasks)
+	if err != nil {
+		return "", err
+	}
+
+	if changed {
+		return t.Notify.Service, nil
+	}
+
+	return "", nil
+}
@@ -0,0 +1,140 @@
+package tinyconf
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestTimer(t *testing.T) (*timerResource, *mockServiceManager, *mockDaemonReloader) {
+	t.Helper()
+
+	manager := newMockServiceManager()
+	reloader := &mockDaemonReloader{}
+
+	return &timerResource{
+		Name:       "backup",
+		Command:    "/usr/local/bin/backup",
+		OnCalendar: ptr("daily"),
+		Notify:     notifyResource{Service: "notified"},
+		manager:    manager,
+		reloader:   reloader,
+		verifier:   func(context.Context, string) error { return nil },
+		unitDir:    t.TempDir(),
+	}, manager, reloader
+}
+
+func TestTimerResource_Run(t *testing.T) {
+	timer, manager, reloader := newTestTimer(t)
+	timer.User = ptr("backup")
+	timer.Env = map[string]string{"TARGET": "s3://my bucket"}
+	timer.Persistent = ptr(true)
+
+	service, err := timer.Run(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, "notified", service)
+	require.Equal(t, 2, reloader.needed)
+	require.True(t, manager.enabled["backup.timer"])
+	require.Equal(t, []string{"backup.timer"}, manager.startCalled)
+
+	data, err := os.ReadFile(filepath.Join(timer.unitDir, "backup.service"))
+	require.NoError(t, err)
+	require.Equal(t, `# managed by tinyconf
+[Unit]
+Description=tinyconf timer backup
+
+[Service]
+Environment="TARGET=s3://my bucket"
+ExecStart=/usr/local/bin/backup
+Type=oneshot
+User=backup
+`, string(data))
+
+	data, err = os.ReadFile(filepath.Join(timer.unitDir, "backup.timer"))
+	require.NoError(t, err)
+	require.Equal(t, `# managed by tinyconf
+[Unit]
+Description=tinyconf timer backup
+
+[Timer]
+OnCalendar=daily
+Persistent=true
+
+[Install]
+WantedBy=timers.target
+`, string(data))
+
+	service, err = timer.Run(t.Context())
+	require.NoError(t, err)
+	require.Empty(t, service)
+	require.Equal(t, 2, reloader.needed)
+}
+
+func TestTimerResource_Run_EscapesPercent(t *testing.T) {
+	timer, _, _ := newTestTimer(t)
+	timer.Command = "/usr/local/bin/backup --name etc-%Y"
+	timer.Env = map[string]string{"FORMAT": "+%F"}
+
+	_, err := timer.Run(t.Context())
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(timer.unitDir, "backup.service"))
+	require.NoError(t, err)
+	require.Equal(t, `# managed by tinyconf
+[Unit]
+Description=tinyconf timer backup
+
+[Service]
+Environment="FORMAT=+%%F"
+ExecStart=/usr/local/bin/backup --name etc-%%Y
+Type=oneshot
+`, string(data))
+}
+
+func TestTimerResource_Run_ScheduleChangeRestarts(t *testing.T) {
+	timer, manager, _ := newTestTimer(t)
+
+	_, err := timer.Run(t.Context())
+	require.NoError(t, err)
+
+	timer.OnCalendar = ptr("hourly")
+	service, err := timer.Run(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, "notified", service)
+	require.Equal(t, []string{"backup.timer"}, manager.stopCalled)
+	require.Equal(t, []string{"backup.timer", "backup.timer"}, manager.startCalled)
+}
+
+func TestTimerResource_Run_Absent(t *testing.T) {
+	timer, manager, _ := newTestTimer(t)
+
+	_, err := timer.Run(t.Context())
+	require.NoError(t, err)
+
+	timer.State = ptr("absent")
+	service, err := timer.Run(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, "notified", service)
+	require.False(t, manager.services["backup.timer"])
+	require.False(t, manager.enabled["backup.timer"])
+
+	for _, name := range []string{"backup.service", "backup.timer"} {
+		_, err = os.Stat(filepath.Join(timer.unitDir, name))
+		require.True(t, os.IsNotExist(err))
+	}
+
+	service, err = timer.Run(t.Context())
+	require.NoError(t, err)
+	require.Empty(t, service)
+}
+
+func TestTimerResource_Run_RequiresSchedule(t *testing.T) {
+	timer, _, _ := newTestTimer(t)
+	timer.OnCalendar = nil
+
+	_, err := timer.Run(t.Context())
+	require.Error(t, err)
+}
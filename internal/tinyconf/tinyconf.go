@@ -8,10 +8,13 @@ import (
 	"os"
 	"os/signal"
 	"os/user"
+	"path/filepath"
 	"reflect"
 	"slices"
 	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/alecthomas/kong"
 	"github.com/go-playground/validator/v10"
@@ -21,7 +24,8 @@ import (
 // should be only call in main.go
 func Run() {
 	var cli struct {
-		ConfigFile string `arg:"" type:"existingfile"`
+		ConfigFile  string `arg:"" type:"existingfile"`
+		PurgeDryRun bool   `help:"Log the paths purge would remove, without removing them."`
 	}
 
 	kong.Parse(&cli)
@@ -29,7 +33,7 @@ func Run() {
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
-	if err := run(ctx, cli.ConfigFile); err != nil {
+	if err := run(ctx, cli.ConfigFile, cli.PurgeDryRun); err != nil {
 		cancel()
 
 		slog.Error("configuration failed", "error", err)
@@ -37,11 +41,12 @@ func Run() {
 	}
 }
 
-func run(ctx context.Context, filename string) error {
+func run(ctx context.Context, filename string, purgeDryRun bool) error {
 	cfg, err := configFromFile(filename)
 	if err != nil {
 		return err
 	}
+	cfg.purgeDryRun = purgeDryRun
 
 	runners, err := cfg.getRunners()
 	if err != nil {
@@ -53,19 +58,79 @@ func run(ctx context.Context, filename string) error {
 		return err
 	}
 
-	return notifyServices(ctx, &systemdServiceManager{}, services)
+	if err := notifyServices(ctx, cfg.serviceManager(), services); err != nil {
+		return err
+	}
+
+	// units may have changed without anything being started.
+	// only when systemd is the init system, as otherwise it is not running
+	if manager, ok := cfg.serviceManager().(*systemdServiceManager); ok {
+		return manager.DaemonReload(ctx)
+	}
+
+	return nil
 }
 
 type config struct {
 	Resources []resource `json:"resources"`
+	// if set, directories with state absent must be in one of these paths
+	DirectoryRemoveAllowed []string `json:"directory_remove_allowed"`
+	// always, never, or the max age of the package cache, such as 24h. defaults to always
+	UpdateCache *string `json:"update_cache" validate:"omitempty,update_cache"`
+	// systemd, openrc, runit, s6, or sysv. detected from the host when not set
+	Init *string `json:"init" validate:"omitempty,oneof=systemd openrc runit s6 sysv"`
+	// shared by services, units, and notifications so daemon-reload is batched
+	services initSystem
+	// used by units and timers when the init system is not systemd
+	systemd *systemdServiceManager
+	// purge only logs what it would remove
+	purgeDryRun bool
+}
+
+func (cfg *config) serviceManager() initSystem {
+	if cfg.services == nil {
+		name := detectInitSystem("/")
+		if cfg.Init != nil {
+			name = *cfg.Init
+		}
+		cfg.services = newInitSystem(name)
+	}
+
+	return cfg.services
+}
+
+// units and timers always use systemd. shares the service manager when systemd is the init system
+func (cfg *config) systemdManager() *systemdServiceManager {
+	if manager, ok := cfg.serviceManager().(*systemdServiceManager); ok {
+		return manager
+	}
+
+	if cfg.systemd == nil {
+		cfg.systemd = &systemdServiceManager{}
+	}
+
+	return cfg.systemd
 }
 
 type resource struct {
-	Type      string             `json:"type" validate:"required,oneof=file directory service"`
-	File      *fileResource      `json:",inline"`
-	Directory *directoryResource `json:",inline"`
-	Service   *serviceResource   `json:",inline"`
-	Package   *packageResource   `json:",inline"`
+	Type          string                 `json:"type" validate:"required,oneof=file directory service package line block config_value sync archive user group authorized_key exec apt_repository systemd_unit cron timer"`
+	File          *fileResource          `json:",inline"`
+	Directory     *directoryResource     `json:",inline"`
+	Service       *serviceResource       `json:",inline"`
+	Package       *packageResource       `json:",inline"`
+	Line          *lineResource          `json:",inline"`
+	Block         *blockResource         `json:",inline"`
+	ConfigValue   *configValueResource   `json:",inline"`
+	Sync          *syncResource          `json:",inline"`
+	Archive       *archiveResource       `json:",inline"`
+	User          *userResource          `json:",inline"`
+	Group         *groupResource         `json:",inline"`
+	AuthorizedKey *authorizedKeyResource `json:",inline"`
+	Exec          *execResource          `json:",inline"`
+	AptRepository *aptRepositoryResource `json:",inline"`
+	SystemdUnit   *systemdUnitResource   `json:",inline"`
+	Cron          *cronResource          `json:",inline"`
+	Timer         *timerResource         `json:",inline"`
 }
 
 // handle all the supported types
@@ -80,6 +145,11 @@ func (r *resource) UnmarshalJSON(data []byte) error {
 
 	r.Type = typeOnly.Type
 
+	data, err := stringifyIDs(data)
+	if err != nil {
+		return err
+	}
+
 	switch r.Type {
 	case "file":
 		r.File = &fileResource{}
@@ -93,12 +163,87 @@ func (r *resource) UnmarshalJSON(data []byte) error {
 	case "package":
 		r.Package = &packageResource{}
 		return json.Unmarshal(data, r.Package)
+	case "line":
+		r.Line = &lineResource{}
+		return json.Unmarshal(data, r.Line)
+	case "block":
+		r.Block = &blockResource{}
+		return json.Unmarshal(data, r.Block)
+	case "config_value":
+		r.ConfigValue = &configValueResource{}
+		return json.Unmarshal(data, r.ConfigValue)
+	case "sync":
+		r.Sync = &syncResource{}
+		return json.Unmarshal(data, r.Sync)
+	case "archive":
+		r.Archive = &archiveResource{}
+		return json.Unmarshal(data, r.Archive)
+	case "user":
+		r.User = &userResource{}
+		return json.Unmarshal(data, r.User)
+	case "group":
+		r.Group = &groupResource{}
+		return json.Unmarshal(data, r.Group)
+	case "authorized_key":
+		r.AuthorizedKey = &authorizedKeyResource{}
+		return json.Unmarshal(data, r.AuthorizedKey)
+	case "exec":
+		r.Exec = &execResource{}
+		return json.Unmarshal(data, r.Exec)
+	case "apt_repository":
+		r.AptRepository = &aptRepositoryResource{}
+		return json.Unmarshal(data, r.AptRepository)
+	case "systemd_unit":
+		r.SystemdUnit = &systemdUnitResource{}
+		return json.Unmarshal(data, r.SystemdUnit)
+	case "cron":
+		r.Cron = &cronResource{}
+		return json.Unmarshal(data, r.Cron)
+	case "timer":
+		r.Timer = &timerResource{}
+		return json.Unmarshal(data, r.Timer)
 	default:
 		// should be caught by validation...
 		return fmt.Errorf("unknown resource type: %s", r.Type)
 	}
 }
 
+// owner, group, and user may be numeric ids in the config, but are strings
+// on the resources.
+func stringifyIDs(data []byte) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	changed := false
+	for _, key := range []string{"owner", "group", "user"} {
+		raw, ok := fields[key]
+		if !ok {
+			continue
+		}
+
+		var id json.Number
+		if err := json.Unmarshal(raw, &id); err != nil {
+			// not a number
+			continue
+		}
+
+		quoted, err := json.Marshal(id.String())
+		if err != nil {
+			return nil, err
+		}
+		fields[key] = quoted
+		changed = true
+	}
+
+	if !changed {
+		return data, nil
+	}
+
+	return json.Marshal(fields)
+}
+
 // helper when building out the run tree
 func (r *resource) toRunner() (runner, error) {
 	switch r.Type {
@@ -110,6 +255,32 @@ func (r *resource) toRunner() (runner, error) {
 		return r.Service, nil
 	case "package":
 		return r.Package, nil
+	case "line":
+		return r.Line, nil
+	case "block":
+		return r.Block, nil
+	case "config_value":
+		return r.ConfigValue, nil
+	case "sync":
+		return r.Sync, nil
+	case "archive":
+		return r.Archive, nil
+	case "user":
+		return r.User, nil
+	case "group":
+		return r.Group, nil
+	case "authorized_key":
+		return r.AuthorizedKey, nil
+	case "exec":
+		return r.Exec, nil
+	case "apt_repository":
+		return r.AptRepository, nil
+	case "systemd_unit":
+		return r.SystemdUnit, nil
+	case "cron":
+		return r.Cron, nil
+	case "timer":
+		return r.Timer, nil
 	default:
 		return nil, fmt.Errorf("unknown resource type: %s", r.Type)
 	}
@@ -118,34 +289,247 @@ func (r *resource) toRunner() (runner, error) {
 func (cfg *config) getRunners() ([]runner, error) {
 	var out []runner
 
+	var managed []string
+	for _, r := range cfg.Resources {
+		for _, path := range r.managedPaths() {
+			managed = append(managed, filepath.Clean(path))
+		}
+	}
+
+	// packages share a manager per provider and location so the cache is only updated once per run
+	packages := make(map[[2]string]packageManager)
+	packageManagerFor := func(provider string, location string) (packageManager, error) {
+		key := [2]string{provider, location}
+		if manager, ok := packages[key]; ok {
+			return manager, nil
+		}
+
+		manager, err := newPackageManager(provider, location)
+		if err != nil {
+			return nil, err
+		}
+		packages[key] = manager
+
+		return manager, nil
+	}
+	defaultProvider := detectPackageProvider()
+
 	for _, r := range cfg.Resources {
 		run, err := r.toRunner()
 		if err != nil {
 			return nil, err
 		}
+
+		// purge needs to know about everything in the config, not just
+		// what has ran before it
+		if r.Type == "directory" && r.Directory.Purge {
+			r.Directory.managed = managed
+			r.Directory.purgeDryRun = cfg.purgeDryRun
+		}
+
+		if r.Type == "directory" {
+			r.Directory.removeAllowed = cfg.DirectoryRemoveAllowed
+		}
+
+		if r.Type == "package" {
+			if isNil(r.Package.manager) {
+				provider := defaultProvider
+				if r.Package.Provider != nil {
+					provider = *r.Package.Provider
+				}
+
+				var location string
+				switch {
+				case r.Package.Virtualenv != nil:
+					location = *r.Package.Virtualenv
+				case r.Package.Prefix != nil:
+					location = *r.Package.Prefix
+				}
+
+				if r.Package.manager, err = packageManagerFor(provider, location); err != nil {
+					return nil, err
+				}
+			}
+			if cfg.UpdateCache != nil {
+				r.Package.defaultUpdateCache = *cfg.UpdateCache
+			}
+		}
+
+		if r.Type == "service" && isNil(r.Service.manager) {
+			r.Service.manager = cfg.serviceManager()
+		}
+
+		if r.Type == "systemd_unit" {
+			r.SystemdUnit.reloader = cfg.systemdManager()
+		}
+
+		if r.Type == "timer" {
+			if isNil(r.Timer.manager) {
+				r.Timer.manager = cfg.systemdManager()
+			}
+			r.Timer.reloader = cfg.systemdManager()
+		}
+
+		// changing a repository means the apt cache needs to be updated
+		if r.Type == "apt_repository" {
+			manager, err := packageManagerFor("apt", "")
+			if err != nil {
+				return nil, err
+			}
+
+			if cache, ok := manager.(cacheInvalidator); ok {
+				r.AptRepository.cache = cache
+			}
+		}
+
 		out = append(out, run)
 	}
 
-	return out, nil
+	return batchPackages(out), nil
+}
+
+// adds a packageBatch before each run of consecutive packages with the same
+// manager, so they are installed in a single package manager transaction
+func batchPackages(runners []runner) []runner {
+	var out []runner
+
+	for i := 0; i < len(runners); {
+		var packages []*packageResource
+		for _, r := range runners[i:] {
+			p, ok := r.(*packageResource)
+			if !ok || (len(packages) > 0 && p.manager != packages[0].manager) {
+				break
+			}
+			packages = append(packages, p)
+		}
+
+		if len(packages) > 1 {
+			out = append(out, &packageBatch{packages: packages})
+		}
+
+		if len(packages) == 0 {
+			out = append(out, runners[i])
+			i++
+			continue
+		}
+
+		for _, p := range packages {
+			out = append(out, p)
+		}
+		i += len(packages)
+	}
+
+	return out
+}
+
+// returns the filesystem path the resource manages, if any
+func (r *resource) managedPaths() []string {
+	switch r.Type {
+	case "file":
+		return []string{r.File.Path}
+	case "directory":
+		return []string{r.Directory.Path}
+	case "line":
+		return []string{r.Line.Path}
+	case "block":
+		return []string{r.Block.Path}
+	case "config_value":
+		return []string{r.ConfigValue.Path}
+	case "sync":
+		return []string{r.Sync.Destination}
+	case "archive":
+		return []string{r.Archive.Destination}
+	case "authorized_key":
+		// the user may not exist until it is created by another resource,
+		// in which case there is nothing to purge yet
+		if path, err := r.AuthorizedKey.path(); err == nil {
+			return []string{path}
+		}
+		return nil
+	case "apt_repository":
+		paths := []string{r.AptRepository.sourcesPath()}
+		if r.AptRepository.Key != nil {
+			// the extension depends on the key, so keep either
+			paths = append(paths, r.AptRepository.keyPath(true), r.AptRepository.keyPath(false))
+		}
+		return paths
+	case "systemd_unit":
+		return []string{r.SystemdUnit.path()}
+	case "cron":
+		return []string{r.Cron.path()}
+	case "timer":
+		service, timer := r.Timer.units()
+		return []string{service.path(), timer.path()}
+	default:
+		return nil
+	}
 }
 
 // poorly named, but it does run the runners
 // returns services to notify
-func runRunners(ctx context.Context, runners []runner) ([]string, error) {
-	var out []string
+func runRunners(ctx context.Context, runners []runner) ([]notifyResource, error) {
+	var out []notifyResource
+
+	consume := func(service string) bool {
+		i := slices.IndexFunc(out, func(n notifyResource) bool { return sameService(n.Service, service) })
+		if i == -1 {
+			return false
+		}
+		out = slices.Delete(out, i, i+1)
+		return true
+	}
 
 	for _, r := range runners {
-		service, err := r.Run(ctx)
+		run := r.Run
+
+		// refresh only commands are notified using their name.
+		// the notification is consumed rather than restarting a service
+		if e, ok := r.(*execResource); ok && e.RefreshOnly {
+			if consume(*e.Name) {
+				run = e.Refresh
+			}
+		}
+
+		service, err := run(ctx)
 		if err != nil {
 			return nil, err
 		}
 
-		if service != "" {
-			// we want order to somewhat matter (sure, why not)
-			// otherwise we could use a map, but this is fine for now
-			if !slices.Contains(out, service) {
-				out = append(out, service)
-			}
+		// a service started or stopped after it was notified does not need
+		// to be restarted, as it already has the change
+		if s, ok := r.(*serviceResource); ok && s.transitioned {
+			consume(s.Name)
+		}
+
+		if service == "" {
+			continue
+		}
+
+		// the service may differ from the notify, such as a systemd_unit notifying itself
+		notification := runnerNotify(r)
+		notification.Service = service
+
+		// a unit notifying itself is only restarted if it is running, so changing
+		// an inactive or oneshot unit does not start it
+		if u, ok := r.(*systemdUnitResource); ok && u.Notify.Service == "" && notification.Action == "" {
+			notification.Action = "try-restart"
+		}
+
+		// we want order to somewhat matter (sure, why not)
+		// otherwise we could use a map, but this is fine for now
+		i := slices.IndexFunc(out, func(n notifyResource) bool { return sameService(n.Service, service) })
+		if i == -1 {
+			out = append(out, notification)
+			continue
+		}
+
+		// a restart is needed if anything asked for one
+		if notifyActionRank(notification.Action) > notifyActionRank(out[i].Action) {
+			out[i].Action = notification.Action
+		}
+
+		if out[i].Healthcheck == nil {
+			out[i].Healthcheck = notification.Healthcheck
 		}
 	}
 
@@ -159,6 +543,25 @@ func configFromBytes(input []byte) (*config, error) {
 	}
 
 	v := validator.New(validator.WithRequiredStructEnabled())
+	if err := v.RegisterValidation("update_cache", func(fl validator.FieldLevel) bool {
+		return validUpdateCache(fl.Field().String())
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := v.RegisterValidation("cron_schedule", func(fl validator.FieldLevel) bool {
+		return validCronSchedule(fl.Field().String())
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := v.RegisterValidation("duration", func(fl validator.FieldLevel) bool {
+		_, err := time.ParseDuration(fl.Field().String())
+		return err == nil
+	}); err != nil {
+		return nil, err
+	}
+
 	if err := v.Struct(&cfg); err != nil {
 		return nil, err
 	}
@@ -175,6 +578,32 @@ func configFromBytes(input []byte) (*config, error) {
 			err = v.Struct(res.Service)
 		case "package":
 			err = v.Struct(res.Package)
+		case "line":
+			err = v.Struct(res.Line)
+		case "block":
+			err = v.Struct(res.Block)
+		case "config_value":
+			err = v.Struct(res.ConfigValue)
+		case "sync":
+			err = v.Struct(res.Sync)
+		case "archive":
+			err = v.Struct(res.Archive)
+		case "user":
+			err = v.Struct(res.User)
+		case "group":
+			err = v.Struct(res.Group)
+		case "authorized_key":
+			err = v.Struct(res.AuthorizedKey)
+		case "exec":
+			err = v.Struct(res.Exec)
+		case "apt_repository":
+			err = v.Struct(res.AptRepository)
+		case "systemd_unit":
+			err = v.Struct(res.SystemdUnit)
+		case "cron":
+			err = v.Struct(res.Cron)
+		case "timer":
+			err = v.Struct(res.Timer)
 		}
 		if err != nil {
 			return nil, fmt.Errorf("resource %d validation failed: %w", i, err)
@@ -194,11 +623,55 @@ func configFromFile(filename string) (*config, error) {
 }
 
 // for now, we only support notifying a service
-// to restart. The service does not need to be defined
+// to restart or reload. The service does not need to be defined
 // as a resource. For now, we assume, for better or worse, the caller
 // knows what they are doing.
 type notifyResource struct {
 	Service string
+	// restart, try-restart, reload, or reload-or-restart. defaults to restart
+	Action string `validate:"omitempty,oneof=restart try-restart reload reload-or-restart"`
+	// checked after the service is restarted or reloaded
+	Healthcheck *healthcheck
+}
+
+// returns the notify of a resource. all resources have a Notify field
+func runnerNotify(r runner) notifyResource {
+	v := reflect.ValueOf(r)
+	if v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		return notifyResource{}
+	}
+
+	field := v.FieldByName("Notify")
+	if !field.IsValid() {
+		return notifyResource{}
+	}
+
+	notify, _ := field.Interface().(notifyResource)
+	return notify
+}
+
+// higher ranks include the lower ones
+// systemd accepts services with or without the .service suffix, so a
+// systemd_unit named app.service and a service named app are the same
+func sameService(a string, b string) bool {
+	return strings.TrimSuffix(a, ".service") == strings.TrimSuffix(b, ".service")
+}
+
+func notifyActionRank(action string) int {
+	switch action {
+	case "reload":
+		return 0
+	case "try-restart":
+		return 1
+	case "reload-or-restart":
+		return 2
+	default:
+		return 3
+	}
 }
 
 type runner interface {
@@ -206,38 +679,65 @@ type runner interface {
 	Run(ctx context.Context) (string, error)
 }
 
-func getUserAndGroup(username *string, groupname *string) (int, int, error) {
+// usernames and group names that are all digits are treated as numeric ids.
+// names are looked up when the resource runs, so users and groups created
+// earlier in the same run can be used.
+// resource is used in errors to identify what needed the user or group.
+func getUserAndGroup(resource string, username *string, groupname *string) (int, int, error) {
 	userID := -1
 	if username != nil && *username != "" {
-		u, err := user.Lookup(*username)
-		if err != nil {
-			return 0, 0, fmt.Errorf("unble to determine uid for user %s %w", *username, err)
-		}
-		id, err := strconv.Atoi(u.Uid)
-		// should never happen, but just in case
-		if err != nil {
-			return 0, 0, fmt.Errorf("unexpected uid for user %s %s %w", *username, u.Uid, err)
+		if id, ok := numericID(*username); ok {
+			userID = id
+		} else {
+			u, err := user.Lookup(*username)
+			if err != nil {
+				return 0, 0, fmt.Errorf("unable to determine uid for user %s needed by %s %w", *username, resource, err)
+			}
+			id, err := strconv.Atoi(u.Uid)
+			// should never happen, but just in case
+			if err != nil {
+				return 0, 0, fmt.Errorf("unexpected uid for user %s %s %w", *username, u.Uid, err)
+			}
+			userID = id
 		}
-		userID = id
 	}
 
 	groupID := -1
 	if groupname != nil && *groupname != "" {
-		g, err := user.LookupGroup(*groupname)
-		if err != nil {
-			return 0, 0, fmt.Errorf("unble to determine gid for group %s %w", *groupname, err)
-		}
-		id, err := strconv.Atoi(g.Gid)
-		// should never happen, but just in case
-		if err != nil {
-			return 0, 0, fmt.Errorf("unexpected gid for group %s %s %w", *groupname, g.Gid, err)
+		if id, ok := numericID(*groupname); ok {
+			groupID = id
+		} else {
+			g, err := user.LookupGroup(*groupname)
+			if err != nil {
+				return 0, 0, fmt.Errorf("unable to determine gid for group %s needed by %s %w", *groupname, resource, err)
+			}
+			id, err := strconv.Atoi(g.Gid)
+			// should never happen, but just in case
+			if err != nil {
+				return 0, 0, fmt.Errorf("unexpected gid for group %s %s %w", *groupname, g.Gid, err)
+			}
+			groupID = id
 		}
-		groupID = id
 	}
 
 	return userID, groupID, nil
 }
 
+func numericID(name string) (int, bool) {
+	for _, c := range name {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+	}
+
+	id, err := strconv.Atoi(name)
+	if err != nil {
+		return 0, false
+	}
+
+	return id, true
+}
+
 func runTasks(tasks []func() (bool, error)) (bool, error) {
 	changed := false
 	for _, task := range tasks {
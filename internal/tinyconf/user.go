@@ -0,0 +1,400 @@
+package tinyconf
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type userResource struct {
+	Name string `json:"name" validate:"required"`
+	UID  *int   `json:"uid"`
+	// primary group
+	Group *string `json:"group"`
+	// supplementary groups. the user is removed from any groups not listed
+	Groups []string `json:"groups"`
+	Home   *string  `json:"home"`
+	// create the home directory when creating the user
+	CreateHome bool    `json:"create_home"`
+	Shell      *string `json:"shell"`
+	// only used when creating the user
+	System bool `json:"system"`
+	// password hash, as in /etc/shadow
+	Password *string        `json:"password"`
+	Locked   *bool          `json:"locked"`
+	State    *string        `json:"state" validate:"omitempty,oneof=present absent"`
+	Notify   notifyResource `json:"notify"`
+	manager  userManager
+}
+
+// current state of a user
+type userInfo struct {
+	UID      int
+	GID      int
+	Group    string
+	Groups   []string
+	Home     string
+	Shell    string
+	Password string
+	Locked   bool
+	// the account never had a password, such as * or a bare !.
+	// locking does not apply, as password logins are already impossible
+	NoPassword bool
+}
+
+// attributes to set when creating or modifying a user.
+// nil means leave it alone.
+type userAttributes struct {
+	UID        *int
+	Group      *string
+	Groups     []string
+	Home       *string
+	CreateHome bool
+	Shell      *string
+	System     bool
+	Password   *string
+	Locked     *bool
+}
+
+// for testing
+type userManager interface {
+	// returns nil if the user does not exist
+	Get(context.Context, string) (*userInfo, error)
+	Create(context.Context, string, userAttributes) error
+	Modify(context.Context, string, userAttributes) error
+	Delete(context.Context, string) error
+}
+
+func (u *userResource) Run(ctx context.Context) (string, error) {
+	if isNil(u.manager) {
+		u.manager = &shadowUserManager{}
+	}
+
+	shouldExist := u.State == nil || *u.State == "present"
+
+	tasks := []func() (bool, error){
+		func() (bool, error) {
+			info, err := u.manager.Get(ctx, u.Name)
+			if err != nil {
+				return false, fmt.Errorf("failed to get user %s %w", u.Name, err)
+			}
+
+			if !shouldExist {
+				if info == nil {
+					return false, nil
+				}
+
+				slog.Info("removing user", "name", u.Name)
+				return true, u.manager.Delete(ctx, u.Name)
+			}
+
+			if info == nil {
+				slog.Info("creating user", "name", u.Name)
+				return true, u.manager.Create(ctx, u.Name, userAttributes{
+					UID:        u.UID,
+					Group:      u.Group,
+					Groups:     u.Groups,
+					Home:       u.Home,
+					CreateHome: u.CreateHome,
+					Shell:      u.Shell,
+					System:     u.System,
+					Password:   u.Password,
+					Locked:     u.Locked,
+				})
+			}
+
+			changes, changed := u.changes(info)
+			if !changed {
+				return false, nil
+			}
+
+			slog.Info("modifying user", "name", u.Name)
+			return true, u.manager.Modify(ctx, u.Name, changes)
+		},
+	}
+
+	// use runTasks in case we add some debugging/logging/etc
+	changed, err := runTasks(tasks)
+	if err != nil {
+		return "", err
+	}
+
+	if changed {
+		return u.Notify.Service, nil
+	}
+
+	return "", nil
+}
+
+// returns only the attributes that differ from the current user
+func (u *userResource) changes(info *userInfo) (userAttributes, bool) {
+	var out userAttributes
+	changed := false
+
+	if u.UID != nil && *u.UID != info.UID {
+		out.UID = u.UID
+		changed = true
+	}
+
+	if u.Group != nil {
+		same := *u.Group == info.Group
+		if gid, ok := numericID(*u.Group); ok {
+			same = gid == info.GID
+		}
+		if !same {
+			out.Group = u.Group
+			changed = true
+		}
+	}
+
+	if u.Groups != nil {
+		want := slices.Sorted(slices.Values(u.Groups))
+		have := slices.Sorted(slices.Values(info.Groups))
+		if !slices.Equal(slices.Compact(want), have) {
+			out.Groups = u.Groups
+			changed = true
+		}
+	}
+
+	if u.Home != nil && *u.Home != info.Home {
+		out.Home = u.Home
+		changed = true
+	}
+
+	if u.Shell != nil && *u.Shell != info.Shell {
+		out.Shell = u.Shell
+		changed = true
+	}
+
+	if u.Password != nil && *u.Password != info.Password {
+		out.Password = u.Password
+		// setting the password clears the lock
+		out.Locked = u.Locked
+		if out.Locked == nil && info.Locked {
+			out.Locked = &info.Locked
+		}
+		changed = true
+	}
+
+	// usermod refuses to unlock an account without a password
+	if u.Locked != nil && *u.Locked != info.Locked && !info.NoPassword {
+		out.Locked = u.Locked
+		changed = true
+	}
+
+	return out, changed
+}
+
+type shadowUserManager struct{}
+
+func (s *shadowUserManager) Get(ctx context.Context, name string) (*userInfo, error) {
+	cmd := exec.CommandContext(ctx, "getent", "passwd", name)
+	output, err := cmd.Output()
+	if err != nil {
+		// getent returns exit code 2 when the key is not found
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 2 {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get user %s: %w", name, err)
+	}
+
+	// name:password:uid:gid:gecos:home:shell
+	fields := strings.Split(strings.TrimSpace(string(output)), ":")
+	if len(fields) != 7 {
+		return nil, fmt.Errorf("unexpected passwd entry for %s: %s", name, string(output))
+	}
+
+	uid, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return nil, fmt.Errorf("unexpected uid for %s: %w", name, err)
+	}
+
+	gid, err := strconv.Atoi(fields[3])
+	if err != nil {
+		return nil, fmt.Errorf("unexpected gid for %s: %w", name, err)
+	}
+
+	info := &userInfo{
+		UID:   uid,
+		GID:   gid,
+		Group: fields[3],
+		Home:  fields[5],
+		Shell: fields[6],
+	}
+
+	// prefer the group name, as that is what is usually in the config
+	if output, err := exec.CommandContext(ctx, "getent", "group", fields[3]).Output(); err == nil {
+		info.Group = strings.SplitN(string(output), ":", 2)[0]
+	}
+
+	groups, err := s.supplementaryGroups(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	info.Groups = groups
+
+	// name:password:...
+	output, err = exec.CommandContext(ctx, "getent", "shadow", name).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get shadow entry for %s: %w", name, err)
+	}
+
+	shadow := strings.Split(strings.TrimSpace(string(output)), ":")
+	if len(shadow) < 2 {
+		return nil, fmt.Errorf("unexpected shadow entry for %s", name)
+	}
+
+	info.Password, info.Locked, info.NoPassword = parseShadowPassword(shadow[1])
+
+	return info, nil
+}
+
+// returns the hash, whether it is locked, and whether there is no password at all.
+// usermod -L prefixes the hash with !, while * and a bare ! or !! are accounts that never had a password.
+func parseShadowPassword(field string) (string, bool, bool) {
+	hash := strings.TrimLeft(field, "!")
+	if field != "" && (hash == "" || hash == "*") {
+		return "", false, true
+	}
+
+	return hash, hash != field, false
+}
+
+func (s *shadowUserManager) supplementaryGroups(ctx context.Context, name string) ([]string, error) {
+	output, err := exec.CommandContext(ctx, "getent", "group").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list groups: %w", err)
+	}
+
+	return parseSupplementaryGroups(string(output), name), nil
+}
+
+// returns the groups from group file formatted input that list name as a member
+func parseSupplementaryGroups(input string, name string) []string {
+	var out []string
+	for line := range strings.Lines(input) {
+		info, err := parseGroupEntry(line)
+		if err != nil {
+			continue
+		}
+
+		if slices.Contains(info.Members, name) {
+			out = append(out, strings.SplitN(line, ":", 2)[0])
+		}
+	}
+
+	slices.Sort(out)
+
+	return out
+}
+
+func useraddArgs(name string, attrs userAttributes) []string {
+	var args []string
+
+	if attrs.UID != nil {
+		args = append(args, "-u", strconv.Itoa(*attrs.UID))
+	}
+	if attrs.Group != nil {
+		args = append(args, "-g", *attrs.Group)
+	}
+	if attrs.Groups != nil {
+		args = append(args, "-G", strings.Join(attrs.Groups, ","))
+	}
+	if attrs.Home != nil {
+		args = append(args, "-d", *attrs.Home)
+	}
+	if attrs.CreateHome {
+		args = append(args, "-m")
+	} else {
+		args = append(args, "-M")
+	}
+	if attrs.Shell != nil {
+		args = append(args, "-s", *attrs.Shell)
+	}
+	if attrs.System {
+		args = append(args, "-r")
+	}
+	if attrs.Password != nil {
+		args = append(args, "-p", *attrs.Password)
+	}
+
+	return append(args, name)
+}
+
+func usermodArgs(name string, attrs userAttributes) []string {
+	var args []string
+
+	if attrs.UID != nil {
+		args = append(args, "-u", strconv.Itoa(*attrs.UID))
+	}
+	if attrs.Group != nil {
+		args = append(args, "-g", *attrs.Group)
+	}
+	if attrs.Groups != nil {
+		args = append(args, "-G", strings.Join(attrs.Groups, ","))
+	}
+	if attrs.Home != nil {
+		args = append(args, "-d", *attrs.Home)
+	}
+	if attrs.Shell != nil {
+		args = append(args, "-s", *attrs.Shell)
+	}
+	if attrs.Password != nil {
+		args = append(args, "-p", *attrs.Password)
+	}
+	if attrs.Locked != nil {
+		if *attrs.Locked {
+			args = append(args, "-L")
+		} else {
+			args = append(args, "-U")
+		}
+	}
+
+	return append(args, name)
+}
+
+func (s *shadowUserManager) Create(ctx context.Context, name string, attrs userAttributes) error {
+	cmd := exec.CommandContext(ctx, "useradd", useraddArgs(name, attrs)...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to create user %s (output: %s): %w", name, string(output), err)
+	}
+
+	// useradd can't lock
+	if attrs.Locked != nil && *attrs.Locked {
+		return s.Modify(ctx, name, userAttributes{Locked: attrs.Locked})
+	}
+
+	return nil
+}
+
+func (s *shadowUserManager) Modify(ctx context.Context, name string, attrs userAttributes) error {
+	// usermod can't set the password and lock at the same time
+	locked := attrs.Locked
+	if attrs.Password != nil {
+		attrs.Locked = nil
+	}
+
+	cmd := exec.CommandContext(ctx, "usermod", usermodArgs(name, attrs)...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to modify user %s (output: %s): %w", name, string(output), err)
+	}
+
+	if attrs.Password != nil && locked != nil {
+		return s.Modify(ctx, name, userAttributes{Locked: locked})
+	}
+
+	return nil
+}
+
+func (s *shadowUserManager) Delete(ctx context.Context, name string) error {
+	cmd := exec.CommandContext(ctx, "userdel", name)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to remove user %s (output: %s): %w", name, string(output), err)
+	}
+
+	return nil
+}
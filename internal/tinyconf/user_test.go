@@ -0,0 +1,323 @@
+package tinyconf
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type mockUserManager struct {
+	users         map[string]*userInfo
+	getErr        error
+	createErr     error
+	createCalled  map[string]userAttributes
+	modifyCalled  map[string]userAttributes
+	deleteCalled  []string
+	getCalledWith []string
+}
+
+func newMockUserManager() *mockUserManager {
+	return &mockUserManager{
+		users:        make(map[string]*userInfo),
+		createCalled: make(map[string]userAttributes),
+		modifyCalled: make(map[string]userAttributes),
+	}
+}
+
+func (m *mockUserManager) Get(ctx context.Context, name string) (*userInfo, error) {
+	m.getCalledWith = append(m.getCalledWith, name)
+	if m.getErr != nil {
+		return nil, m.getErr
+	}
+	return m.users[name], nil
+}
+
+func (m *mockUserManager) Create(ctx context.Context, name string, attrs userAttributes) error {
+	m.createCalled[name] = attrs
+	if m.createErr != nil {
+		return m.createErr
+	}
+	m.users[name] = &userInfo{}
+	return nil
+}
+
+func (m *mockUserManager) Modify(ctx context.Context, name string, attrs userAttributes) error {
+	m.modifyCalled[name] = attrs
+	return nil
+}
+
+func (m *mockUserManager) Delete(ctx context.Context, name string) error {
+	m.deleteCalled = append(m.deleteCalled, name)
+	delete(m.users, name)
+	return nil
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestUserResource_Run_CreateUser(t *testing.T) {
+	mock := newMockUserManager()
+
+	u := &userResource{
+		Name:       "deploy",
+		UID:        ptr(1500),
+		Groups:     []string{"www-data"},
+		Shell:      ptr("/bin/bash"),
+		CreateHome: true,
+		Notify: notifyResource{
+			Service: "test-service",
+		},
+		manager: mock,
+	}
+
+	service, err := u.Run(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, "test-service", service)
+
+	attrs, ok := mock.createCalled["deploy"]
+	require.True(t, ok)
+	require.Equal(t, 1500, *attrs.UID)
+	require.Equal(t, []string{"www-data"}, attrs.Groups)
+	require.True(t, attrs.CreateHome)
+}
+
+func TestUserResource_Run_UserUpToDate(t *testing.T) {
+	mock := newMockUserManager()
+	mock.users["deploy"] = &userInfo{
+		UID:    1500,
+		GID:    1500,
+		Group:  "deploy",
+		Groups: []string{"adm", "www-data"},
+		Shell:  "/bin/bash",
+		Home:   "/home/deploy",
+	}
+
+	u := &userResource{
+		Name:   "deploy",
+		UID:    ptr(1500),
+		Group:  ptr("deploy"),
+		Groups: []string{"www-data", "adm"},
+		Shell:  ptr("/bin/bash"),
+		Notify: notifyResource{
+			Service: "test-service",
+		},
+		manager: mock,
+	}
+
+	service, err := u.Run(t.Context())
+	require.NoError(t, err)
+	require.Empty(t, service)
+	require.Empty(t, mock.createCalled)
+	require.Empty(t, mock.modifyCalled)
+}
+
+func TestUserResource_Run_ModifyOnlyChangedAttributes(t *testing.T) {
+	mock := newMockUserManager()
+	mock.users["deploy"] = &userInfo{
+		UID:    1500,
+		GID:    1500,
+		Group:  "deploy",
+		Groups: []string{"adm"},
+		Shell:  "/bin/sh",
+	}
+
+	u := &userResource{
+		Name:    "deploy",
+		UID:     ptr(1500),
+		Group:   ptr("1500"),
+		Groups:  []string{"adm", "docker"},
+		Shell:   ptr("/bin/bash"),
+		manager: mock,
+	}
+
+	_, err := u.Run(t.Context())
+	require.NoError(t, err)
+
+	attrs, ok := mock.modifyCalled["deploy"]
+	require.True(t, ok)
+	require.Nil(t, attrs.UID)
+	require.Nil(t, attrs.Group)
+	require.Equal(t, []string{"adm", "docker"}, attrs.Groups)
+	require.Equal(t, "/bin/bash", *attrs.Shell)
+}
+
+func TestUserResource_Run_Lock(t *testing.T) {
+	mock := newMockUserManager()
+	mock.users["deploy"] = &userInfo{Password: "$6$hash"}
+
+	u := &userResource{
+		Name:    "deploy",
+		Locked:  ptr(true),
+		manager: mock,
+	}
+
+	_, err := u.Run(t.Context())
+	require.NoError(t, err)
+
+	attrs := mock.modifyCalled["deploy"]
+	require.True(t, *attrs.Locked)
+	require.Nil(t, attrs.Password)
+}
+
+func TestUserResource_Run_UnlockWithoutPassword(t *testing.T) {
+	mock := newMockUserManager()
+	mock.users["deploy"] = &userInfo{NoPassword: true}
+
+	u := &userResource{
+		Name:    "deploy",
+		Locked:  ptr(false),
+		manager: mock,
+	}
+
+	service, err := u.Run(t.Context())
+	require.NoError(t, err)
+	require.Empty(t, service)
+	require.NotContains(t, mock.modifyCalled, "deploy")
+}
+
+func TestParseShadowPassword(t *testing.T) {
+	for field, expected := range map[string]struct {
+		hash       string
+		locked     bool
+		noPassword bool
+	}{
+		"$6$hash":  {hash: "$6$hash"},
+		"!$6$hash": {hash: "$6$hash", locked: true},
+		"!":        {noPassword: true},
+		"!!":       {noPassword: true},
+		"!*":       {noPassword: true},
+		"*":        {noPassword: true},
+		"":         {},
+	} {
+		hash, locked, noPassword := parseShadowPassword(field)
+		require.Equal(t, expected.hash, hash, field)
+		require.Equal(t, expected.locked, locked, field)
+		require.Equal(t, expected.noPassword, noPassword, field)
+	}
+}
+
+func TestUserResource_Run_PasswordChangeKeepsLock(t *testing.T) {
+	mock := newMockUserManager()
+	mock.users["deploy"] = &userInfo{Password: "$6$old", Locked: true}
+
+	u := &userResource{
+		Name:     "deploy",
+		Password: ptr("$6$new"),
+		manager:  mock,
+	}
+
+	_, err := u.Run(t.Context())
+	require.NoError(t, err)
+
+	attrs := mock.modifyCalled["deploy"]
+	require.Equal(t, "$6$new", *attrs.Password)
+	require.True(t, *attrs.Locked)
+}
+
+func TestUserResource_Run_RemoveUser(t *testing.T) {
+	mock := newMockUserManager()
+	mock.users["olduser"] = &userInfo{}
+
+	u := &userResource{
+		Name:    "olduser",
+		State:   ptr("absent"),
+		manager: mock,
+		Notify: notifyResource{
+			Service: "test-service",
+		},
+	}
+
+	ctx := t.Context()
+
+	service, err := u.Run(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "test-service", service)
+	require.Equal(t, []string{"olduser"}, mock.deleteCalled)
+
+	service, err = u.Run(ctx)
+	require.NoError(t, err)
+	require.Empty(t, service)
+	require.Len(t, mock.deleteCalled, 1)
+}
+
+func TestUserResource_Run_ErrorOnGet(t *testing.T) {
+	mock := newMockUserManager()
+	mock.getErr = errors.New("getent failed")
+
+	u := &userResource{
+		Name:    "deploy",
+		manager: mock,
+	}
+
+	_, err := u.Run(t.Context())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "getent failed")
+}
+
+func TestUserResource_Run_ErrorOnCreate(t *testing.T) {
+	mock := newMockUserManager()
+	mock.createErr = errors.New("useradd failed")
+
+	u := &userResource{
+		Name:    "deploy",
+		manager: mock,
+	}
+
+	_, err := u.Run(t.Context())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "useradd failed")
+}
+
+func TestUseraddArgs(t *testing.T) {
+	args := useraddArgs("deploy", userAttributes{
+		UID:        ptr(1500),
+		Group:      ptr("deploy"),
+		Groups:     []string{"adm", "www-data"},
+		Home:       ptr("/srv/deploy"),
+		CreateHome: true,
+		Shell:      ptr("/bin/bash"),
+		System:     true,
+		Password:   ptr("$6$hash"),
+	})
+
+	require.Equal(t, []string{
+		"-u", "1500",
+		"-g", "deploy",
+		"-G", "adm,www-data",
+		"-d", "/srv/deploy",
+		"-m",
+		"-s", "/bin/bash",
+		"-r",
+		"-p", "$6$hash",
+		"deploy",
+	}, args)
+
+	require.Equal(t, []string{"-M", "app"}, useraddArgs("app", userAttributes{}))
+}
+
+func TestUsermodArgs(t *testing.T) {
+	require.Equal(t, []string{"-s", "/bin/sh", "-L", "deploy"}, usermodArgs("deploy", userAttributes{
+		Shell:  ptr("/bin/sh"),
+		Locked: ptr(true),
+	}))
+
+	require.Equal(t, []string{"-G", "", "-U", "deploy"}, usermodArgs("deploy", userAttributes{
+		Groups: []string{},
+		Locked: ptr(false),
+	}))
+}
+
+func TestParseSupplementaryGroups(t *testing.T) {
+	input := `root:x:0:
+adm:x:4:syslog,deploy
+www-data:x:33:deploy
+deployers:x:1001:deployer
+sudo:x:27:
+`
+
+	require.Equal(t, []string{"adm", "www-data"}, parseSupplementaryGroups(input, "deploy"))
+	require.Empty(t, parseSupplementaryGroups(input, "nobody"))
+}